@@ -0,0 +1,220 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// procDirectory is where the kernel exposes the status of processes.
+var procDirectory = "/proc"
+
+const (
+	polkitBusName            = "org.freedesktop.PolicyKit1"
+	polkitObjectPath         = "/org/freedesktop/PolicyKit1/Authority"
+	polkitCheckAuthorization = "org.freedesktop.PolicyKit1.Authority.CheckAuthorization"
+
+	// polkitAllowUserInteraction lets polkit ask the user to authenticate.
+	polkitAllowUserInteraction uint32 = 1
+
+	// manageAction is the polkit action required to install, uninstall,
+	// refresh or cancel packages.
+	manageAction = "com.canonical.applications.package-manage"
+
+	// manageOthersAction is the polkit action required to cancel operations
+	// requested by other callers.
+	manageOthersAction = "com.canonical.applications.package-manage-others"
+)
+
+// authority is an interface to be implemented by any struct that can decide
+// whether a caller is allowed to perform an action.
+type authority interface {
+	checkAuthorization(sender dbus.Sender, action string, interactive bool) (bool, bool, error)
+}
+
+// polkitSubject is the subject of a polkit authorization check.
+type polkitSubject struct {
+	Kind    string
+	Details map[string]dbus.Variant
+}
+
+// polkitResult is the result of a polkit authorization check.
+type polkitResult struct {
+	IsAuthorized bool
+	IsChallenge  bool
+	Details      map[string]string
+}
+
+// polkitAuthority checks authorizations against polkit on the system bus,
+// identifying callers on the session bus by their process.
+type polkitAuthority struct {
+	dbusConnection DbusWrapper
+
+	lock      sync.Mutex
+	systemBus *dbus.Conn
+}
+
+// newPolkitAuthority creates a new polkitAuthority.
+//
+// Parameters:
+// dbusConnection: Connection to the bus the callers are on.
+//
+// Returns:
+// - Pointer to new polkitAuthority.
+func newPolkitAuthority(dbusConnection DbusWrapper) *polkitAuthority {
+	return &polkitAuthority{dbusConnection: dbusConnection}
+}
+
+// checkAuthorization asks polkit whether a caller is allowed to perform an
+// action. Interactive checks may ask the user to authenticate, which blocks
+// until they're done.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// action: ID of the polkit action.
+// interactive: Whether or not the user may be asked to authenticate.
+//
+// Returns:
+// - Whether or not the caller is authorized.
+// - Whether or not the caller would be authorized once the user authenticates.
+// - Error (nil if none)
+func (authority *polkitAuthority) checkAuthorization(sender dbus.Sender, action string, interactive bool) (bool, bool, error) {
+	subject, err := authority.subject(sender)
+	if err != nil {
+		return false, false, fmt.Errorf(`Unable to identify caller "%s": %s`, sender, err)
+	}
+
+	systemBus, err := authority.connect()
+	if err != nil {
+		return false, false, fmt.Errorf("Unable to connect to the system bus: %s", err)
+	}
+
+	var flags uint32
+	if interactive {
+		flags = polkitAllowUserInteraction
+	}
+
+	var result polkitResult
+	err = systemBus.Object(polkitBusName, polkitObjectPath).Call(
+		polkitCheckAuthorization, 0, subject, action, map[string]string{},
+		flags, "").Store(&result)
+	if err != nil {
+		return false, false, fmt.Errorf("Unable to check authorization: %s", err)
+	}
+
+	return result.IsAuthorized, result.IsChallenge, nil
+}
+
+// subject identifies a caller as a process for polkit. Callers are on the
+// session bus, so polkit can't look up their bus name itself. The process ID
+// alone could be recycled by another process before polkit checks it
+// (CVE-2013-4288), so the subject also carries the process start time and the
+// user the bus vouches for, which polkit checks against the process.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - Subject of the authorization check.
+// - Error (nil if none)
+func (authority *polkitAuthority) subject(sender dbus.Sender) (polkitSubject, error) {
+	pid, err := authority.dbusConnection.GetConnectionUnixProcessID(string(sender))
+	if err != nil {
+		return polkitSubject{}, err
+	}
+
+	uid, err := authority.dbusConnection.GetConnectionUnixUser(string(sender))
+	if err != nil {
+		return polkitSubject{}, err
+	}
+
+	startTime, err := processStartTime(pid)
+	if err != nil {
+		return polkitSubject{}, err
+	}
+
+	return polkitSubject{
+		Kind: "unix-process",
+		Details: map[string]dbus.Variant{
+			"pid":        dbus.MakeVariant(pid),
+			"start-time": dbus.MakeVariant(startTime),
+			"uid":        dbus.MakeVariant(int32(uid)),
+		},
+	}, nil
+}
+
+// processStartTime reads when a process started, in clock ticks since boot,
+// as polkit expects it.
+//
+// Parameters:
+// pid: ID of the process.
+//
+// Returns:
+// - Start time of the process.
+// - Error (nil if none)
+func processStartTime(pid uint32) (uint64, error) {
+	path := filepath.Join(procDirectory, strconv.FormatUint(uint64(pid), 10), "stat")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read process status: %s", err)
+	}
+
+	// The command name may contain anything, including spaces and
+	// parentheses, so the fields are counted from the last parenthesis,
+	// which ends it. The start time is the 22nd field, the 20th after it.
+	stat := string(contents)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf(`Unable to parse process status "%s"`, path)
+	}
+
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`Unable to parse process start time "%s": %s`, fields[19], err)
+	}
+
+	return startTime, nil
+}
+
+// connect connects to the system bus, unless already connected.
+//
+// Returns:
+// - Connection to the system bus.
+// - Error (nil if none)
+func (authority *polkitAuthority) connect() (*dbus.Conn, error) {
+	authority.lock.Lock()
+	defer authority.lock.Unlock()
+
+	if authority.systemBus == nil {
+		systemBus, err := dbus.SystemBus()
+		if err != nil {
+			return nil, err
+		}
+
+		authority.systemBus = systemBus
+	}
+
+	return authority.systemBus, nil
+}
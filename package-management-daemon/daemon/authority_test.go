@@ -0,0 +1,132 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"github.com/godbus/dbus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeProcDirectory creates a temporary directory holding the status of
+// process 42, and makes it the one processes are looked up in.
+func fakeProcDirectory(t *testing.T, stat string) func() {
+	directory, err := ioutil.TempDir("", "authority_test")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %s", err)
+	}
+
+	err = os.Mkdir(filepath.Join(directory, "42"), 0700)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(directory, "42", "stat"), []byte(stat), 0600)
+	}
+	if err != nil {
+		os.RemoveAll(directory)
+		t.Fatalf("Unable to write process status: %s", err)
+	}
+
+	originalDirectory := procDirectory
+	procDirectory = directory
+
+	return func() {
+		procDirectory = originalDirectory
+		os.RemoveAll(directory)
+	}
+}
+
+// Test that failing to identify the caller results in an error.
+func TestPolkitAuthority_unknownCaller(t *testing.T) {
+	server := &FakeDbusServer{failGetProcessID: true}
+	authority := newPolkitAuthority(server)
+
+	authorized, _, err := authority.checkAuthorization(":1.42", manageAction, false)
+	if err == nil {
+		t.Error("Expected an error due to failure to identify the caller")
+	}
+
+	if authorized {
+		t.Error("Expected caller not to be authorized")
+	}
+
+	if !server.getProcessIDCalled {
+		t.Error("Expected authority to identify the caller")
+	}
+}
+
+// Test that callers are identified by process ID, start time and user, so a
+// recycled process ID can't be mistaken for them.
+func TestPolkitAuthority_subject(t *testing.T) {
+	// The command name can't be trusted to be free of spaces and parentheses
+	cleanup := fakeProcDirectory(t, "42 (a) b (c) S 1 42 42 0 -1 4194560 "+
+		"100 0 0 0 1 2 0 0 20 0 1 0 123456 1000 100 18446744073709551615")
+	defer cleanup()
+
+	authority := newPolkitAuthority(new(FakeDbusServer))
+
+	subject, err := authority.subject(":1.42")
+	if err != nil {
+		t.Fatalf("Unexpected error identifying the caller: %s", err)
+	}
+
+	expected := polkitSubject{
+		Kind: "unix-process",
+		Details: map[string]dbus.Variant{
+			"pid":        dbus.MakeVariant(uint32(42)),
+			"start-time": dbus.MakeVariant(uint64(123456)),
+			"uid":        dbus.MakeVariant(int32(1000)),
+		},
+	}
+	if !reflect.DeepEqual(subject, expected) {
+		t.Errorf("Subject was %#v, expected %#v", subject, expected)
+	}
+}
+
+// Test that failing to determine the caller's user results in an error.
+func TestPolkitAuthority_unknownUser(t *testing.T) {
+	cleanup := fakeProcDirectory(t, "42 (a) S 1 42 42 0 -1 4194560 "+
+		"100 0 0 0 1 2 0 0 20 0 1 0 123456 1000 100")
+	defer cleanup()
+
+	authority := newPolkitAuthority(&FakeDbusServer{failGetUser: true})
+
+	_, err := authority.subject(":1.42")
+	if err == nil {
+		t.Error("Expected an error due to failure to determine the user")
+	}
+}
+
+// Test that a process status that can't be parsed results in an error rather
+// than a zero start time.
+func TestProcessStartTime_unparsable(t *testing.T) {
+	cleanup := fakeProcDirectory(t, "42 (a) S 1 42")
+	defer cleanup()
+
+	_, err := processStartTime(42)
+	if err == nil {
+		t.Error("Expected an error due to the process status being truncated")
+	}
+
+	_, err = processStartTime(43)
+	if err == nil {
+		t.Error("Expected an error due to the process not existing")
+	}
+}
@@ -0,0 +1,307 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+	"sync"
+	"time"
+)
+
+// changeGetter is the subset of the snapd client used by the change monitor.
+type changeGetter interface {
+	Change(id string) (*client.Change, error)
+}
+
+// changeUpdate is the latest state of a change, as delivered to subscribers.
+type changeUpdate struct {
+	change *client.Change // Latest state of the change (nil upon error)
+	err    error          // Error retrieving the change (nil if none)
+}
+
+// changeSubscription receives the updates of a single change.
+type changeSubscription struct {
+	changeID string
+
+	// updates holds the latest update not yet received by the subscriber.
+	// Newer updates replace older ones, so slow subscribers never block the
+	// monitor.
+	updates chan changeUpdate
+}
+
+// changeMonitor polls snapd for all the changes being followed in a single
+// loop, and fans their updates out to subscribers. It polls faster while
+// downloads are in progress, and backs off while nothing moves.
+type changeMonitor struct {
+	getter changeGetter
+
+	period     time.Duration // Poll period while changes are moving
+	fastPeriod time.Duration // Poll period while downloads are in progress
+	maxPeriod  time.Duration // Poll period when nothing moved for a while
+
+	lock sync.Mutex
+
+	// Key: Change ID
+	// Value: Subscriptions to that change
+	subscriptions map[string][]*changeSubscription
+
+	// Key: Change ID
+	// Value: Summary of the change's state as of the last poll
+	lastStates map[string]string
+
+	running bool
+	wake    chan struct{}
+}
+
+// newChangeMonitor creates a new changeMonitor.
+//
+// Parameters:
+// getter: Client used to retrieve changes from snapd.
+// period: Poll period while changes are moving.
+//
+// Returns:
+// - Pointer to new changeMonitor
+func newChangeMonitor(getter changeGetter, period time.Duration) *changeMonitor {
+	return &changeMonitor{
+		getter:        getter,
+		period:        period,
+		fastPeriod:    period / 4,
+		maxPeriod:     period * 8,
+		subscriptions: make(map[string][]*changeSubscription),
+		lastStates:    make(map[string]string),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// subscribe begins following a change. Its state is polled right away.
+//
+// Parameters:
+// changeID: ID of the change to follow.
+//
+// Returns:
+// - Subscription receiving the change's updates.
+func (monitor *changeMonitor) subscribe(changeID string) *changeSubscription {
+	subscription := &changeSubscription{
+		changeID: changeID,
+		updates:  make(chan changeUpdate, 1),
+	}
+
+	monitor.lock.Lock()
+	monitor.subscriptions[changeID] = append(monitor.subscriptions[changeID], subscription)
+	if !monitor.running {
+		monitor.running = true
+		go monitor.run()
+	}
+	monitor.lock.Unlock()
+
+	monitor.poke()
+
+	return subscription
+}
+
+// unsubscribe stops following a change.
+//
+// Parameters:
+// subscription: Subscription to cancel.
+func (monitor *changeMonitor) unsubscribe(subscription *changeSubscription) {
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+
+	subscriptions := monitor.subscriptions[subscription.changeID]
+	for i, existing := range subscriptions {
+		if existing == subscription {
+			subscriptions = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+
+	if len(subscriptions) == 0 {
+		delete(monitor.subscriptions, subscription.changeID)
+		delete(monitor.lastStates, subscription.changeID)
+	} else {
+		monitor.subscriptions[subscription.changeID] = subscriptions
+	}
+}
+
+// poke makes the monitor poll right away, e.g. because a change was just
+// aborted.
+func (monitor *changeMonitor) poke() {
+	select {
+	case monitor.wake <- struct{}{}:
+	default:
+		// A wake up is already pending
+	}
+}
+
+// run polls the changes being followed until there are none left.
+func (monitor *changeMonitor) run() {
+	period := monitor.period
+
+	for {
+		monitor.lock.Lock()
+		if len(monitor.subscriptions) == 0 {
+			monitor.running = false
+			monitor.lock.Unlock()
+			return
+		}
+
+		changeIDs := make([]string, 0, len(monitor.subscriptions))
+		for changeID := range monitor.subscriptions {
+			changeIDs = append(changeIDs, changeID)
+		}
+		monitor.lock.Unlock()
+
+		moved, downloading := monitor.poll(changeIDs)
+		period = monitor.nextPeriod(period, moved, downloading)
+
+		// note this very purposely is not a ticker; we want to sleep
+		// between calls, not call once every period.
+		select {
+		case <-time.After(period):
+		case <-monitor.wake:
+		}
+	}
+}
+
+// nextPeriod determines how long to wait before polling again.
+//
+// Parameters:
+// period: Period waited before the last poll.
+// moved: Whether or not any change moved during the last poll.
+// downloading: Whether or not any change is downloading.
+//
+// Returns:
+// - Period to wait before the next poll.
+func (monitor *changeMonitor) nextPeriod(period time.Duration, moved bool, downloading bool) time.Duration {
+	switch {
+	case downloading:
+		return monitor.fastPeriod
+	case moved:
+		return monitor.period
+	}
+
+	// Nothing moved: back off
+	period *= 2
+	if period > monitor.maxPeriod {
+		period = monitor.maxPeriod
+	}
+
+	return period
+}
+
+// poll retrieves the state of the given changes, and delivers it to their
+// subscribers.
+//
+// Parameters:
+// changeIDs: IDs of the changes to poll.
+//
+// Returns:
+// - Whether or not any change moved (or couldn't be retrieved) since last poll.
+// - Whether or not any change is downloading.
+func (monitor *changeMonitor) poll(changeIDs []string) (bool, bool) {
+	moved := false
+	downloading := false
+
+	for _, changeID := range changeIDs {
+		change, err := monitor.getter.Change(changeID)
+
+		state := ""
+		if err != nil {
+			// Keep polling at the regular pace while snapd is unreachable
+			moved = true
+		} else {
+			state = changeState(change)
+			downloading = downloading || isDownloading(change)
+		}
+
+		monitor.lock.Lock()
+		if state != monitor.lastStates[changeID] {
+			moved = true
+		}
+
+		if _, ok := monitor.subscriptions[changeID]; ok {
+			monitor.lastStates[changeID] = state
+		}
+
+		for _, subscription := range monitor.subscriptions[changeID] {
+			subscription.deliver(changeUpdate{change: change, err: err})
+		}
+		monitor.lock.Unlock()
+	}
+
+	return moved, downloading
+}
+
+// deliver hands an update to the subscriber, replacing any update it hasn't
+// received yet.
+//
+// Parameters:
+// update: Update to deliver.
+func (subscription *changeSubscription) deliver(update changeUpdate) {
+	for {
+		select {
+		case subscription.updates <- update:
+			return
+		default:
+		}
+
+		// Drop the stale update to make room for the new one
+		select {
+		case <-subscription.updates:
+		default:
+		}
+	}
+}
+
+// changeState summarizes the state of a change, so that polls can tell whether
+// it moved.
+//
+// Parameters:
+// change: Change to summarize.
+//
+// Returns:
+// - Summary of the change's state
+func changeState(change *client.Change) string {
+	state := fmt.Sprintf("%s %t", change.Status, change.Ready)
+	for _, task := range change.Tasks {
+		state += fmt.Sprintf(" %s:%s:%d/%d", task.ID, task.Status,
+			task.Progress.Done, task.Progress.Total)
+	}
+
+	return state
+}
+
+// isDownloading checks whether a change has a download in progress, i.e. a
+// task being done with byte progress.
+//
+// Parameters:
+// change: Change to check.
+//
+// Returns:
+// - Whether or not the change is downloading
+func isDownloading(change *client.Change) bool {
+	for _, task := range change.Tasks {
+		if task.Status == "Doing" && task.Progress.Total > 1 {
+			return true
+		}
+	}
+
+	return false
+}
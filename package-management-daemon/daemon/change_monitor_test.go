@@ -0,0 +1,211 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingChangeGetter is a changeGetter counting how many times each change
+// was retrieved.
+type countingChangeGetter struct {
+	lock   sync.Mutex
+	calls  map[string]int
+	status string
+	fail   bool
+}
+
+func (getter *countingChangeGetter) Change(id string) (*client.Change, error) {
+	getter.lock.Lock()
+	defer getter.lock.Unlock()
+
+	if getter.calls == nil {
+		getter.calls = make(map[string]int)
+	}
+	getter.calls[id]++
+
+	if getter.fail {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	return &client.Change{ID: id, Status: getter.status}, nil
+}
+
+func (getter *countingChangeGetter) callCount(id string) int {
+	getter.lock.Lock()
+	defer getter.lock.Unlock()
+
+	return getter.calls[id]
+}
+
+// receiveUpdate waits for an update on a subscription.
+func receiveUpdate(t *testing.T, subscription *changeSubscription) changeUpdate {
+	select {
+	case update := <-subscription.updates:
+		return update
+	case <-time.After(time.Second):
+		t.Fatal("Expected an update for the subscription")
+	}
+
+	return changeUpdate{}
+}
+
+// Test that subscribers receive the updates of their change.
+func TestChangeMonitor_subscribe(t *testing.T) {
+	getter := &countingChangeGetter{status: "Doing"}
+	monitor := newChangeMonitor(getter, time.Millisecond)
+
+	subscription := monitor.subscribe("1")
+	defer monitor.unsubscribe(subscription)
+
+	update := receiveUpdate(t, subscription)
+	if update.err != nil {
+		t.Fatalf("Unexpected error in update: %s", update.err)
+	}
+
+	if update.change.ID != "1" {
+		t.Errorf(`Change ID was "%s", expected "1"`, update.change.ID)
+	}
+}
+
+// Test that errors retrieving the change are delivered to subscribers.
+func TestChangeMonitor_error(t *testing.T) {
+	getter := &countingChangeGetter{fail: true}
+	monitor := newChangeMonitor(getter, time.Millisecond)
+
+	subscription := monitor.subscribe("1")
+	defer monitor.unsubscribe(subscription)
+
+	update := receiveUpdate(t, subscription)
+	if update.err == nil {
+		t.Error("Expected an error in update")
+	}
+}
+
+// Test that subscribers of the same change share a single poll.
+func TestChangeMonitor_fanOut(t *testing.T) {
+	getter := &countingChangeGetter{status: "Doing"}
+	monitor := newChangeMonitor(getter, time.Hour)
+
+	// Register both before the monitor polls for the first time
+	monitor.lock.Lock()
+	monitor.running = true
+	monitor.lock.Unlock()
+
+	subscription1 := monitor.subscribe("1")
+	subscription2 := monitor.subscribe("1")
+
+	monitor.poll([]string{"1"})
+
+	receiveUpdate(t, subscription1)
+	receiveUpdate(t, subscription2)
+
+	if count := getter.callCount("1"); count != 1 {
+		t.Errorf("Change was retrieved %d times, expected 1", count)
+	}
+}
+
+// Test that undelivered updates are replaced by newer ones.
+func TestChangeSubscription_deliver(t *testing.T) {
+	subscription := &changeSubscription{changeID: "1", updates: make(chan changeUpdate, 1)}
+
+	subscription.deliver(changeUpdate{change: &client.Change{Status: "Doing"}})
+	subscription.deliver(changeUpdate{change: &client.Change{Status: "Done"}})
+
+	update := receiveUpdate(t, subscription)
+	if update.change.Status != "Done" {
+		t.Errorf(`Status was "%s", expected the latest one ("Done")`, update.change.Status)
+	}
+
+	select {
+	case <-subscription.updates:
+		t.Error("Expected stale update to be dropped")
+	default:
+	}
+}
+
+// Test that the monitor stops once there are no subscriptions left.
+func TestChangeMonitor_stop(t *testing.T) {
+	getter := &countingChangeGetter{status: "Doing"}
+	monitor := newChangeMonitor(getter, time.Millisecond)
+
+	subscription := monitor.subscribe("1")
+	receiveUpdate(t, subscription)
+	monitor.unsubscribe(subscription)
+
+	waitUntil(t, func() bool {
+		monitor.lock.Lock()
+		defer monitor.lock.Unlock()
+
+		return !monitor.running
+	}, "Expected monitor to stop")
+}
+
+// Data for TestChangeMonitor_nextPeriod
+var nextPeriodTests = []struct {
+	period      time.Duration
+	moved       bool
+	downloading bool
+	expected    time.Duration
+}{
+	{time.Second, true, true, 250 * time.Millisecond},
+	{8 * time.Second, false, true, 250 * time.Millisecond},
+	{8 * time.Second, true, false, time.Second},
+	{time.Second, false, false, 2 * time.Second},
+	{4 * time.Second, false, false, 8 * time.Second},
+	{8 * time.Second, false, false, 8 * time.Second},
+}
+
+// Test that the monitor speeds up during downloads, and backs off when nothing
+// moves.
+func TestChangeMonitor_nextPeriod(t *testing.T) {
+	monitor := newChangeMonitor(new(countingChangeGetter), time.Second)
+
+	for i, test := range nextPeriodTests {
+		period := monitor.nextPeriod(test.period, test.moved, test.downloading)
+		if period != test.expected {
+			t.Errorf("Test case %d: Period was %s, expected %s", i, period, test.expected)
+		}
+	}
+}
+
+// Test that a change's state only differs when it moves.
+func TestChangeState(t *testing.T) {
+	change := &client.Change{Status: "Doing", Tasks: []*client.Task{
+		{ID: "1", Status: "Doing", Progress: client.TaskProgress{Done: 1, Total: 10}},
+	}}
+
+	before := changeState(change)
+	if changeState(change) != before {
+		t.Error("Expected state to be stable")
+	}
+
+	if isDownloading(change) != true {
+		t.Error("Expected change to be downloading")
+	}
+
+	change.Tasks[0].Progress.Done = 2
+	if changeState(change) == before {
+		t.Error("Expected state to change with progress")
+	}
+}
@@ -0,0 +1,108 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultInvalidateScopes are the scopes whose results are invalidated once
+// operations finish, unless configured otherwise.
+var defaultInvalidateScopes = []string{"clickscope", "snappy-store"}
+
+// Config holds the daemon settings read from its configuration file.
+type Config struct {
+	// IDs of the scopes whose results are invalidated once operations
+	// finish.
+	InvalidateScopes []string `json:"invalidate-scopes"`
+
+	// Whether queued operations are dropped once all the callers that
+	// requested them leave the bus (off by default, as callers may simply
+	// have been closed while the user waits for the operations).
+	AbortOnDisconnect bool `json:"abort-on-disconnect"`
+
+	// Number of finished operations kept in the history journal.
+	HistoryLimit int `json:"history-limit"`
+
+	// Whether desktop notifications are posted when operations finish or
+	// fail.
+	Notifications bool `json:"notifications"`
+}
+
+// DefaultConfig creates the configuration used when no configuration file
+// exists.
+//
+// Returns:
+// - Pointer to new default Config.
+func DefaultConfig() *Config {
+	config := new(Config)
+	config.InvalidateScopes = append([]string(nil), defaultInvalidateScopes...)
+	config.HistoryLimit = defaultHistoryLimit
+	config.Notifications = true
+
+	return config
+}
+
+// DefaultConfigPath determines where the configuration file lives, following
+// the XDG base directory specification.
+//
+// Returns:
+// - Path to the configuration file.
+func DefaultConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+
+	return filepath.Join(configHome, "unity-scope-snappy",
+		"package-management-daemon.json")
+}
+
+// LoadConfig reads the configuration file at the given path. Settings missing
+// from the file (or the file missing altogether) keep their default value.
+//
+// Parameters:
+// path: Path to the configuration file.
+//
+// Returns:
+// - Pointer to the loaded Config (nil if error)
+// - Error (nil if none)
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+
+		return nil, fmt.Errorf(`Unable to open config file "%s": %s`, path, err)
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to parse config file "%s": %s`, path, err)
+	}
+
+	return config, nil
+}
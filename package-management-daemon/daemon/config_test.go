@@ -0,0 +1,128 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeConfig writes a configuration file into a temporary directory.
+func writeConfig(t *testing.T, contents string) (string, func()) {
+	directory, err := ioutil.TempDir("", "config_test")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %s", err)
+	}
+
+	path := filepath.Join(directory, "config.json")
+	err = ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write config file: %s", err)
+	}
+
+	return path, func() { os.RemoveAll(directory) }
+}
+
+// Data for TestLoadConfig
+var loadConfigTests = []struct {
+	contents                  string
+	expectedInvalidateScopes  []string
+	expectedAbortOnDisconnect bool
+	expectedNotifications     bool
+}{
+	{`{}`, []string{"clickscope", "snappy-store"}, false, true},
+	{`{"invalidate-scopes": []}`, []string{}, false, true},
+	{`{"invalidate-scopes": ["clickscope", "launcher"]}`, []string{"clickscope", "launcher"}, false, true},
+	{`{"abort-on-disconnect": true}`, []string{"clickscope", "snappy-store"}, true, true},
+	{`{"notifications": false}`, []string{"clickscope", "snappy-store"}, false, false},
+}
+
+// Test typical LoadConfig usage.
+func TestLoadConfig(t *testing.T) {
+	for i, test := range loadConfigTests {
+		path, cleanup := writeConfig(t, test.contents)
+		defer cleanup()
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error loading config: %s", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(config.InvalidateScopes, test.expectedInvalidateScopes) {
+			t.Errorf("Test case %d: Scopes to invalidate were %v, expected %v", i,
+				config.InvalidateScopes, test.expectedInvalidateScopes)
+		}
+
+		if config.HistoryLimit != defaultHistoryLimit {
+			t.Errorf("Test case %d: History limit was %d, expected %d", i,
+				config.HistoryLimit, defaultHistoryLimit)
+		}
+
+		if config.AbortOnDisconnect != test.expectedAbortOnDisconnect {
+			t.Errorf("Test case %d: Abort on disconnect was %t, expected %t", i,
+				config.AbortOnDisconnect, test.expectedAbortOnDisconnect)
+		}
+
+		if config.Notifications != test.expectedNotifications {
+			t.Errorf("Test case %d: Notifications was %t, expected %t", i,
+				config.Notifications, test.expectedNotifications)
+		}
+	}
+}
+
+// Test that a missing configuration file results in the defaults.
+func TestLoadConfig_missingFile(t *testing.T) {
+	config, err := LoadConfig("/does/not/exist.json")
+	if err != nil {
+		t.Fatalf("Unexpected error loading config: %s", err)
+	}
+
+	if !reflect.DeepEqual(config, DefaultConfig()) {
+		t.Errorf("Config was %v, expected the default one", config)
+	}
+}
+
+// Test that an invalid configuration file results in an error.
+func TestLoadConfig_invalidFile(t *testing.T) {
+	path, cleanup := writeConfig(t, "invalid")
+	defer cleanup()
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Error("Expected an error due to invalid config file")
+	}
+}
+
+// Test that the configuration file follows the XDG base directory
+// specification.
+func TestDefaultConfigPath(t *testing.T) {
+	oldConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", oldConfigHome)
+
+	os.Setenv("XDG_CONFIG_HOME", "/foo")
+
+	expected := "/foo/unity-scope-snappy/package-management-daemon.json"
+	if path := DefaultConfigPath(); path != expected {
+		t.Errorf(`Config path was "%s", expected "%s"`, path, expected)
+	}
+}
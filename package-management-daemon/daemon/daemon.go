@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/godbus/dbus"
 	"github.com/godbus/dbus/introspect"
+	"launchpad.net/unity-scope-snappy/policy"
+	"log"
+	"time"
 )
 
 const (
@@ -25,48 +28,105 @@ const (
 			<interface name="` + interfaceName + `">
 				<method name="Install">
 					<arg name="packageId" type="s" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="InstallMany">
+					<arg name="packageIds" type="as" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
 				</method>
 				<method name="Uninstall">
 					<arg name="packageId" type="s" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="UninstallMany">
+					<arg name="packageIds" type="as" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="Refresh">
+					<arg name="packageId" type="s" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="RefreshAll">
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="Cancel">
+					<arg name="packageId" type="s" direction="in"/>
+				</method>
+				<method name="ListOperations">
+					<arg name="operations" type="ao" direction="out"/>
+				</method>
+				<method name="GetOperationForPackage">
+					<arg name="packageId" type="s" direction="in"/>
+					<arg name="operation" type="o" direction="out"/>
+				</method>
+				<method name="GetHistory">
+					<arg name="limit" type="u" direction="in"/>
+					<arg name="entries" type="a(ssasa{ss}a{ss}xxss)" direction="out"/>
 				</method>
 				<signal name="progress">
 					<arg name="received" type="t" />
 					<arg name="total" type="t" />
 				</signal>
+				<signal name="downloadRate">
+					<arg name="rate" type="t" />
+					<arg name="eta" type="x" />
+				</signal>
 				<signal name="finished">
 					<arg name="path" type="s" />
 				</signal>
 				<signal name="error">
 					<arg name="error" type="s" />
 				</signal>
+				<signal name="canceled">
+					<arg name="success" type="b" />
+				</signal>
 			</interface>` +
 		introspect.IntrospectDataString +
 		`</node>`
 )
 
+// Options holds what a Daemon is set up with when created.
+type Options struct {
+	// Settings read from the configuration file (nil for the defaults)
+	Config *Config
+
+	// Policy deciding which snaps may be installed (nil to allow every snap)
+	Policy *policy.Policy
+
+	// Path to the journal finished operations are recorded in (empty to
+	// record nothing)
+	HistoryPath string
+
+	// Path to the journal remembering the object paths of queued operations
+	// (empty to remember nothing)
+	OperationsPath string
+
+	// How long the daemon may sit unused before Idle reports it (zero to
+	// never report it)
+	IdleTimeout time.Duration
+}
+
 // Daemon represents the actual progress daemon.
 type Daemon struct {
 	server         DbusWrapper
-	packageManager PackageManager
+	packageManager *SnapdPackageManagerInterface
+	idleTimeout    time.Duration
 }
 
-// New creates a new Daemon setup to poll WebDM at a specific URL.
+// New creates a new Daemon managing packages via snapd. The journals are only
+// informational, so failing to read them is logged rather than fatal.
 //
 // Parameters:
-// webdmApiUrl: WebDM API URL to poll.
+// options: What the daemon is set up with.
 //
 // Returns:
 // - New daemon
 // - Error (nil if none)
-func New() (*Daemon, error) {
+func New(options Options) (*Daemon, error) {
 	daemon := new(Daemon)
 
 	daemon.server = new(DbusServer)
-
-	// dbusConnection: Connection to the dbus bus.
-	// interfaceName: DBus interface name to implement.
-	// baseObjectPath: Base object path to use for signals.
-	// apiUrl: WebDM API URL.
+	daemon.idleTimeout = options.IdleTimeout
 
 	var err error
 	daemon.packageManager, err = NewSnapdPackageManagerInterface(daemon.server,
@@ -75,6 +135,36 @@ func New() (*Daemon, error) {
 		return nil, fmt.Errorf(`Unable to create package manager interface:"`, err)
 	}
 
+	config := options.Config
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	manager := daemon.packageManager
+	manager.configure(config)
+	manager.policy = options.Policy
+	manager.notifier.setEnabled(config.Notifications)
+
+	if options.HistoryPath != "" {
+		limit := config.HistoryLimit
+		if limit <= 0 {
+			limit = defaultHistoryLimit
+		}
+
+		manager.history, err = loadHistory(options.HistoryPath, limit)
+		if err != nil {
+			log.Printf("package-management-daemon: Not recording history: %s", err)
+		}
+	}
+
+	// Without it queued operations only lose their object path on restart
+	if options.OperationsPath != "" {
+		manager.paths, err = loadOperationPaths(options.OperationsPath)
+		if err != nil {
+			log.Printf("package-management-daemon: Not remembering queued operations: %s", err)
+		}
+	}
+
 	return daemon, nil
 }
 
@@ -99,6 +189,20 @@ func (daemon *Daemon) Run() error {
 		return fmt.Errorf("Unable to export package manager interface: %s", err)
 	}
 
+	// Pick up where a previous instance left off. Failing to do so isn't
+	// fatal: snapd may simply not be running yet.
+	err = daemon.packageManager.resumeOperations()
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to resume operations: %s", err)
+	}
+
+	// Failing to watch callers only means their queued operations outlive
+	// them.
+	err = daemon.packageManager.watchCallers()
+	if err != nil {
+		log.Printf("package-management-daemon: %s", err)
+	}
+
 	// Now that all interfaces are exported and ready, request our name. Things
 	// are done in this order so that our interfaces aren't called before
 	// they're exported.
@@ -113,3 +217,49 @@ func (daemon *Daemon) Run() error {
 
 	return nil
 }
+
+// Idle waits for the daemon to become idle, i.e. to have no operations in
+// progress and to receive no calls, for the idle timeout it was created with.
+//
+// Returns:
+// - Channel closed once the daemon has been idle for the idle timeout (never
+// if there's none).
+func (daemon *Daemon) Idle() <-chan struct{} {
+	idle := make(chan struct{})
+
+	period := daemon.idleTimeout
+	if period <= 0 {
+		return idle
+	}
+
+	go func() {
+		for {
+			wait := period
+			if since, isIdle := daemon.packageManager.idleSince(); isIdle {
+				wait = since.Add(period).Sub(time.Now())
+				if wait <= 0 {
+					close(idle)
+					return
+				}
+			}
+
+			time.Sleep(wait)
+		}
+	}()
+
+	return idle
+}
+
+// Stop releases the bus name, so that the next call activates a new instance
+// of the daemon instead of reaching this one.
+//
+// Returns:
+// - Error (nil if none)
+func (daemon *Daemon) Stop() error {
+	_, err := daemon.server.ReleaseName(busName)
+	if err != nil {
+		return fmt.Errorf(`Unable to release name "%s": %s`, busName, err)
+	}
+
+	return nil
+}
@@ -1,12 +1,18 @@
 package daemon
 
 import (
+	"encoding/xml"
+	"github.com/godbus/dbus/introspect"
+	"launchpad.net/unity-scope-snappy/policy"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // Test typical New usage.
 func TestNew(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -22,7 +28,7 @@ func TestNew(t *testing.T) {
 
 // Test typical Run usage.
 func TestDaemonRunStop(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -52,7 +58,7 @@ func TestDaemonRunStop(t *testing.T) {
 
 // Test dbus connection failure
 func TestRun_connectionFailure(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -68,7 +74,7 @@ func TestRun_connectionFailure(t *testing.T) {
 
 // Test dbus name request failure
 func TestRun_nameRequestFailure(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -84,7 +90,7 @@ func TestRun_nameRequestFailure(t *testing.T) {
 
 // Test dbus name already taken
 func TestRun_nameTaken(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -100,7 +106,7 @@ func TestRun_nameTaken(t *testing.T) {
 
 // Test dbus introspection export failure
 func TestRun_introspectionExportFailure(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -119,7 +125,7 @@ func TestRun_introspectionExportFailure(t *testing.T) {
 
 // Test dbus package manager export failure
 func TestRun_packageManagerExportFailure(t *testing.T) {
-	daemon, err := New()
+	daemon, err := New(Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error when creating daemon: %s", err)
 	}
@@ -135,3 +141,254 @@ func TestRun_packageManagerExportFailure(t *testing.T) {
 		t.Error("Expected an error due to failure to export")
 	}
 }
+
+// Test that the introspection declares every value the methods return.
+func TestIntrospectionXml(t *testing.T) {
+	var node introspect.Node
+	err := xml.Unmarshal([]byte(introspectionXml), &node)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing introspection: %s", err)
+	}
+
+	managerType := reflect.TypeOf(new(SnapdPackageManagerInterface))
+	for _, method := range node.Interfaces[0].Methods {
+		managerMethod, ok := managerType.MethodByName(method.Name)
+		if !ok {
+			t.Errorf("Introspection declares unknown method %s", method.Name)
+			continue
+		}
+
+		outArgs := 0
+		for _, arg := range method.Args {
+			if arg.Direction == "out" {
+				outArgs++
+			}
+		}
+
+		// The last return value is the *dbus.Error
+		returned := managerMethod.Type.NumOut() - 1
+		if outArgs != returned {
+			t.Errorf("%s declares %d out args, expected %d", method.Name, outArgs, returned)
+		}
+	}
+}
+
+// Test that the daemon reports being idle once the idle timeout expires.
+func TestIdle(t *testing.T) {
+	daemon, err := New(Options{IdleTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	select {
+	case <-daemon.Idle():
+	case <-time.After(time.Second):
+		t.Error("Expected daemon to become idle")
+	}
+}
+
+// Test that the daemon isn't idle while operations are in progress.
+func TestIdle_operationInProgress(t *testing.T) {
+	daemon, err := New(Options{IdleTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	snapd := new(FakeSnapdClient)
+	manager := daemon.packageManager
+	manager.client = snapd
+	manager.monitor = newChangeMonitor(snapd, time.Millisecond)
+	manager.authority = new(FakeAuthority)
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	select {
+	case <-daemon.Idle():
+		t.Error("Expected daemon not to become idle with an operation in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Test that a zero idle timeout disables idling.
+func TestIdle_disabled(t *testing.T) {
+	daemon, err := New(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	select {
+	case <-daemon.Idle():
+		t.Error("Expected daemon never to become idle")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Test that the configuration is applied to the package manager.
+func TestNew_config(t *testing.T) {
+	daemon, err := New(Options{Config: &Config{InvalidateScopes: []string{"foo"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	manager := daemon.packageManager
+	manager.invalidator.lock.Lock()
+	defer manager.invalidator.lock.Unlock()
+
+	if len(manager.invalidator.scopes) != 1 || manager.invalidator.scopes[0] != "foo" {
+		t.Errorf("Scopes to invalidate were %v, expected [foo]", manager.invalidator.scopes)
+	}
+}
+
+// Test that the policy is applied to the package manager.
+func TestNew_policy(t *testing.T) {
+	installPolicy := new(policy.Policy)
+
+	daemon, err := New(Options{Policy: installPolicy})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	if daemon.packageManager.policy != installPolicy {
+		t.Error("Expected package manager to enforce the policy")
+	}
+}
+
+// Test that the history journal is handed to the package manager.
+func TestNew_history(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	daemon, err := New(Options{HistoryPath: filepath.Join(directory, "history.json")})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	manager := daemon.packageManager
+	if manager.history == nil || manager.history.limit != defaultHistoryLimit {
+		t.Error("Expected package manager to record history with the default limit")
+	}
+}
+
+// Test that failing to read the history journal only disables it.
+func TestNew_historyUnreadable(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	// A directory can't be read as a journal
+	daemon, err := New(Options{HistoryPath: directory})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	if daemon.packageManager.history != nil {
+		t.Error("Expected package manager not to record history")
+	}
+}
+
+// Test that the journal of queued operations is handed to the package manager.
+func TestNew_operationPaths(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	daemon, err := New(Options{OperationsPath: filepath.Join(directory, "operations.json")})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	if daemon.packageManager.paths == nil {
+		t.Error("Expected package manager to remember queued operations")
+	}
+}
+
+// Test that failing to read the journal of queued operations only disables
+// it.
+func TestNew_operationPathsUnreadable(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	// A directory can't be read as a journal
+	daemon, err := New(Options{OperationsPath: directory})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	if daemon.packageManager.paths != nil {
+		t.Error("Expected package manager not to remember queued operations")
+	}
+}
+
+// Test that notifications are only posted when enabled.
+func TestNew_notifications(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		daemon, err := New(Options{Config: &Config{Notifications: enabled}})
+		if err != nil {
+			t.Fatalf("Unexpected error when creating daemon: %s", err)
+		}
+
+		if daemon.packageManager.notifier.isEnabled() != enabled {
+			t.Errorf("Notifications enabled was %t, expected %t", !enabled, enabled)
+		}
+	}
+}
+
+// Test typical Stop usage.
+func TestStop(t *testing.T) {
+	daemon, err := New(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	server := new(FakeDbusServer)
+	daemon.server = server
+
+	err = daemon.Stop()
+	if err != nil {
+		t.Errorf("Unexpected error while stopping daemon: %s", err)
+	}
+
+	if !server.releaseNameCalled {
+		t.Error("Expected daemon to release its name")
+	}
+}
+
+// Test that failing to release the name results in an error.
+func TestStop_releaseNameFailure(t *testing.T) {
+	daemon, err := New(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating daemon: %s", err)
+	}
+
+	daemon.server = &FakeDbusServer{failReleaseName: true}
+
+	err = daemon.Stop()
+	if err == nil {
+		t.Error("Expected an error due to failure to release name")
+	}
+}
+
+// Test that the daemon watches for callers leaving the bus, and that failing
+// to do so isn't fatal.
+func TestRun_watchCallers(t *testing.T) {
+	for _, failSubscribe := range []bool{false, true} {
+		daemon, err := New(Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error when creating daemon: %s", err)
+		}
+
+		server := &FakeDbusServer{failSubscribe: failSubscribe}
+		daemon.server = server
+		daemon.packageManager.dbusConnection = server
+
+		err = daemon.Run()
+		if err != nil {
+			t.Errorf("Unexpected error while running daemon: %s", err)
+		}
+
+		if !server.subscribeCalled {
+			t.Error("Expected daemon to watch for callers leaving the bus")
+		}
+	}
+}
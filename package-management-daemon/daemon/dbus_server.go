@@ -51,6 +51,22 @@ func (server *DbusServer) RequestName(name string, flags dbus.RequestNameFlags)
 	return server.connection.RequestName(name, flags)
 }
 
+// ReleaseName releases a name previously requested for this connection.
+//
+// Parameters:
+// name: Name to release.
+//
+// Returns:
+// - dbus.ReleaseNameReply to inform caller of release result
+// - Error (nil if none)
+func (server *DbusServer) ReleaseName(name string) (dbus.ReleaseNameReply, error) {
+	if server.connection == nil {
+		return 0, fmt.Errorf("Server is not connected")
+	}
+
+	return server.connection.ReleaseName(name)
+}
+
 // GetNameOwner requests the unique name on the bus that owns a specific name.
 //
 // Parameters:
@@ -70,6 +86,46 @@ func (server *DbusServer) GetNameOwner(name string) (string, error) {
 	return owner, err
 }
 
+// GetConnectionUnixProcessID requests the process ID of the connection owning a
+// specific name on the bus.
+//
+// Parameters:
+// name: Name for which to query.
+//
+// Returns:
+// - Process ID of the owner connection.
+// - Error (nil if none)
+func (server *DbusServer) GetConnectionUnixProcessID(name string) (uint32, error) {
+	var pid uint32
+	if server.connection == nil {
+		return pid, fmt.Errorf("Server is not connected")
+	}
+
+	object := server.connection.BusObject()
+	err := object.Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, name).Store(&pid)
+	return pid, err
+}
+
+// GetConnectionUnixUser requests the user ID of the connection owning a
+// specific name on the bus.
+//
+// Parameters:
+// name: Name for which to query.
+//
+// Returns:
+// - User ID of the owner connection.
+// - Error (nil if none)
+func (server *DbusServer) GetConnectionUnixUser(name string) (uint32, error) {
+	var uid uint32
+	if server.connection == nil {
+		return uid, fmt.Errorf("Server is not connected")
+	}
+
+	object := server.connection.BusObject()
+	err := object.Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, name).Store(&uid)
+	return uid, err
+}
+
 // Export exports a given interface to handle incoming requests.
 //
 // Parameters:
@@ -103,3 +159,72 @@ func (server *DbusServer) Emit(path dbus.ObjectPath, name string, values ...inte
 
 	return server.connection.Emit(path, name, values...)
 }
+
+// Call calls a method on a remote object, ignoring its reply. No reply is even
+// requested, so a slow or unresponsive peer never blocks the caller.
+//
+// Parameters:
+// destination: Bus name owning the remote object.
+// path: Object path of the remote object.
+// method: Name of the method to call, including its interface.
+// args...: Method arguments.
+//
+// Returns:
+// - Error (nil if none)
+func (server *DbusServer) Call(destination string, path dbus.ObjectPath, method string, args ...interface{}) error {
+	if server.connection == nil {
+		return fmt.Errorf("Server is not connected")
+	}
+
+	return server.connection.Object(destination, path).Go(method,
+		dbus.FlagNoReplyExpected, nil, args...).Err
+}
+
+// Subscribe delivers the signals the bus sends to a channel. Which signals
+// are sent is decided by the rules added via AddMatch. The channel is written
+// to by the goroutine reading from the bus, so it must be drained promptly.
+//
+// Parameters:
+// ch: Channel to which the signals are delivered.
+//
+// Returns:
+// - Error (nil if none)
+func (server *DbusServer) Subscribe(ch chan<- *dbus.Signal) error {
+	if server.connection == nil {
+		return fmt.Errorf("Server is not connected")
+	}
+
+	server.connection.Signal(ch)
+
+	return nil
+}
+
+// AddMatch asks the bus for the signals matching a rule.
+//
+// Parameters:
+// rule: Match rule selecting the signals.
+//
+// Returns:
+// - Error (nil if none)
+func (server *DbusServer) AddMatch(rule string) error {
+	if server.connection == nil {
+		return fmt.Errorf("Server is not connected")
+	}
+
+	return server.connection.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err
+}
+
+// RemoveMatch tells the bus the signals matching a rule are no longer wanted.
+//
+// Parameters:
+// rule: Match rule previously added via AddMatch.
+//
+// Returns:
+// - Error (nil if none)
+func (server *DbusServer) RemoveMatch(rule string) error {
+	if server.connection == nil {
+		return fmt.Errorf("Server is not connected")
+	}
+
+	return server.connection.BusObject().Call("org.freedesktop.DBus.RemoveMatch", 0, rule).Err
+}
@@ -3,6 +3,7 @@ package daemon
 import (
 	"fmt"
 	"github.com/godbus/dbus"
+	"os"
 	"testing"
 )
 
@@ -75,6 +76,39 @@ func TestRequestName_beforeConnect(t *testing.T) {
 	}
 }
 
+// Test typical ReleaseName usage.
+func TestReleaseName(t *testing.T) {
+	server := new(DbusServer)
+	err := server.Connect()
+	if err != nil {
+		t.Errorf("Unexpected error while connecting: %s", err)
+	}
+
+	_, err = server.RequestName("com.example.DbusReleaseTest", dbus.NameFlagDoNotQueue)
+	if err != nil {
+		t.Errorf("Unable to request name: %s", err)
+	}
+
+	reply, err := server.ReleaseName("com.example.DbusReleaseTest")
+	if err != nil {
+		t.Errorf("Unable to release name: %s", err)
+	}
+
+	if reply != dbus.ReleaseNameReplyReleased {
+		t.Error("Reply implies that name was unexpectedly not released")
+	}
+}
+
+// Test that a name release before the server is connected results in an
+// error.
+func TestReleaseName_beforeConnect(t *testing.T) {
+	server := new(DbusServer)
+	_, err := server.ReleaseName("foo")
+	if err == nil {
+		t.Error("Expected an error due to name release before server was connected")
+	}
+}
+
 // Test that a name owner request before the server is connected results in an
 // error.
 func TestGetNameOwner_beforeConnect(t *testing.T) {
@@ -85,6 +119,34 @@ func TestGetNameOwner_beforeConnect(t *testing.T) {
 	}
 }
 
+// Test typical GetConnectionUnixProcessID usage.
+func TestGetConnectionUnixProcessID(t *testing.T) {
+	server := new(DbusServer)
+	err := server.Connect()
+	if err != nil {
+		t.Errorf("Unexpected error while connecting: %s", err)
+	}
+
+	pid, err := server.GetConnectionUnixProcessID(server.Names()[0])
+	if err != nil {
+		t.Errorf("Unexpected error while requesting process ID: %s", err)
+	}
+
+	if pid != uint32(os.Getpid()) {
+		t.Errorf("Process ID was %d, expected %d", pid, os.Getpid())
+	}
+}
+
+// Test that a process ID request before the server is connected results in an
+// error.
+func TestGetConnectionUnixProcessID_beforeConnect(t *testing.T) {
+	server := new(DbusServer)
+	_, err := server.GetConnectionUnixProcessID("foo")
+	if err == nil {
+		t.Error("Expected an error due to process ID request before server was connected")
+	}
+}
+
 // Test typical Export usage.
 func TestExport(t *testing.T) {
 	server := new(DbusServer)
@@ -158,3 +220,27 @@ func TestEmit_beforeConnect(t *testing.T) {
 		t.Error("Expected an error due to emit before server was connected")
 	}
 }
+
+// Test typical Call usage.
+func TestCall(t *testing.T) {
+	server := new(DbusServer)
+	err := server.Connect()
+	if err != nil {
+		t.Errorf("Unexpected error while connecting: %s", err)
+	}
+
+	err = server.Call("org.freedesktop.DBus", "/org/freedesktop/DBus",
+		"org.freedesktop.DBus.GetId")
+	if err != nil {
+		t.Errorf("Unexpected error while calling method: %s", err)
+	}
+}
+
+// Test that a call before the server is connected results in an error.
+func TestCall_beforeConnect(t *testing.T) {
+	server := new(DbusServer)
+	err := server.Call("foo", "/foo", "foo.Bar")
+	if err == nil {
+		t.Error("Expected an error due to call before server was connected")
+	}
+}
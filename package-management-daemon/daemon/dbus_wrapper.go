@@ -8,7 +8,14 @@ type DbusWrapper interface {
 	Connect() error
 	Names() []string
 	RequestName(name string, flags dbus.RequestNameFlags) (dbus.RequestNameReply, error)
+	ReleaseName(name string) (dbus.ReleaseNameReply, error)
 	GetNameOwner(name string) (string, error)
+	GetConnectionUnixProcessID(name string) (uint32, error)
+	GetConnectionUnixUser(name string) (uint32, error)
 	Export(object interface{}, path dbus.ObjectPath, iface string) error
 	Emit(path dbus.ObjectPath, name string, values ...interface{}) error
+	Call(destination string, path dbus.ObjectPath, method string, args ...interface{}) error
+	Subscribe(ch chan<- *dbus.Signal) error
+	AddMatch(rule string) error
+	RemoveMatch(rule string) error
 }
@@ -0,0 +1,66 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"sync"
+)
+
+// FakeAuthority is a fake implementation of the authority interface, for use
+// within tests.
+type FakeAuthority struct {
+	denied           bool
+	fail             bool
+	deniedAction     string // Action denied even if others are allowed
+	challengedAction string // Action only allowed once the user authenticates
+
+	lock    sync.Mutex
+	checked []string // "<sender> <action>" for each check, "(interactive)" appended if so
+}
+
+func (authority *FakeAuthority) checkAuthorization(sender dbus.Sender, action string, interactive bool) (bool, bool, error) {
+	authority.lock.Lock()
+	defer authority.lock.Unlock()
+
+	check := fmt.Sprintf("%s %s", sender, action)
+	if interactive {
+		check += " (interactive)"
+	}
+	authority.checked = append(authority.checked, check)
+
+	if authority.fail {
+		return false, false, fmt.Errorf("Failed at user request")
+	}
+
+	if action == authority.challengedAction && !interactive {
+		return false, true, nil
+	}
+
+	return !authority.denied && action != authority.deniedAction, false, nil
+}
+
+// checks returns the authorization checks made so far.
+func (authority *FakeAuthority) checks() []string {
+	authority.lock.Lock()
+	defer authority.lock.Unlock()
+
+	return append([]string(nil), authority.checked...)
+}
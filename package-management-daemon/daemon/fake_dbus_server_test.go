@@ -3,6 +3,7 @@ package daemon
 import (
 	"fmt"
 	"github.com/godbus/dbus"
+	"sync"
 )
 
 // FakeDbusServer is a fake implementation of the DbusWrapper interface,
@@ -11,20 +12,61 @@ type FakeDbusServer struct {
 	connectCalled      bool
 	namesCalled        bool
 	requestNameCalled  bool
+	releaseNameCalled  bool
 	getNameOwnerCalled bool
+	getProcessIDCalled bool
 	exportCalled       bool
 	emitCalled         bool
+	callCalled         bool
+	subscribeCalled    bool
 
 	failConnect      bool
 	failNames        bool
 	failRequestName  bool
+	failReleaseName  bool
 	failGetNameOwner bool
+	failGetProcessID bool
+	failGetUser      bool
 	failExport       bool
 	failEmit         bool
+	failCall         bool
+	failSubscribe    bool
+	failAddMatch     bool
 
 	nameAlreadyTaken            bool
 	failSpecificExportInterface string
 	signals                     chan *dbus.Signal
+
+	// Key: Object path
+	// Value: Map of interface names to exported objects
+	exported     map[dbus.ObjectPath]map[string]interface{}
+	exportedLock sync.Mutex
+
+	// Operations emit signals concurrently
+	emitLock sync.Mutex
+
+	calls     []fakeDbusCall
+	callsLock sync.Mutex
+
+	// Called before each method call is made, if set
+	beforeCall func()
+
+	subscribers []chan<- *dbus.Signal
+
+	// Match rules currently added
+	matches     []string
+	matchesLock sync.Mutex
+
+	// Names that left the bus, as far as GetNameOwner is concerned
+	leftBus []string
+}
+
+// fakeDbusCall records a method call made via the FakeDbusServer.
+type fakeDbusCall struct {
+	destination string
+	path        dbus.ObjectPath
+	method      string
+	args        []interface{}
 }
 
 func (server *FakeDbusServer) InitializeSignals() {
@@ -65,6 +107,16 @@ func (server *FakeDbusServer) RequestName(name string, flags dbus.RequestNameFla
 	return dbus.RequestNameReplyPrimaryOwner, nil
 }
 
+func (server *FakeDbusServer) ReleaseName(name string) (dbus.ReleaseNameReply, error) {
+	server.releaseNameCalled = true
+
+	if server.failReleaseName {
+		return 0, fmt.Errorf("Failed at user request")
+	}
+
+	return dbus.ReleaseNameReplyReleased, nil
+}
+
 func (server *FakeDbusServer) GetNameOwner(name string) (string, error) {
 	server.getNameOwnerCalled = true
 
@@ -72,9 +124,31 @@ func (server *FakeDbusServer) GetNameOwner(name string) (string, error) {
 		return "", fmt.Errorf("Failed at user request")
 	}
 
+	if contains(server.leftBus, name) {
+		return "", dbus.Error{Name: "org.freedesktop.DBus.Error.NameHasNoOwner"}
+	}
+
 	return ":1.42", nil
 }
 
+func (server *FakeDbusServer) GetConnectionUnixUser(name string) (uint32, error) {
+	if server.failGetUser {
+		return 0, fmt.Errorf("Failed at user request")
+	}
+
+	return 1000, nil
+}
+
+func (server *FakeDbusServer) GetConnectionUnixProcessID(name string) (uint32, error) {
+	server.getProcessIDCalled = true
+
+	if server.failGetProcessID {
+		return 0, fmt.Errorf("Failed at user request")
+	}
+
+	return 42, nil
+}
+
 func (server *FakeDbusServer) Export(object interface{}, path dbus.ObjectPath, iface string) error {
 	server.exportCalled = true
 
@@ -84,11 +158,38 @@ func (server *FakeDbusServer) Export(object interface{}, path dbus.ObjectPath, i
 		}
 	}
 
+	server.exportedLock.Lock()
+	defer server.exportedLock.Unlock()
+
+	if server.exported == nil {
+		server.exported = make(map[dbus.ObjectPath]map[string]interface{})
+	}
+
+	if object == nil {
+		delete(server.exported[path], iface)
+		return nil
+	}
+
+	if server.exported[path] == nil {
+		server.exported[path] = make(map[string]interface{})
+	}
+
+	server.exported[path][iface] = object
+
 	return nil
 }
 
+func (server *FakeDbusServer) ExportedObject(path dbus.ObjectPath, iface string) interface{} {
+	server.exportedLock.Lock()
+	defer server.exportedLock.Unlock()
+
+	return server.exported[path][iface]
+}
+
 func (server *FakeDbusServer) Emit(path dbus.ObjectPath, name string, values ...interface{}) error {
+	server.emitLock.Lock()
 	server.emitCalled = true
+	server.emitLock.Unlock()
 
 	if server.failEmit {
 		return fmt.Errorf("Failed at user request")
@@ -100,3 +201,84 @@ func (server *FakeDbusServer) Emit(path dbus.ObjectPath, name string, values ...
 
 	return nil
 }
+
+func (server *FakeDbusServer) Call(destination string, path dbus.ObjectPath, method string, args ...interface{}) error {
+	if server.beforeCall != nil {
+		server.beforeCall()
+	}
+
+	server.callsLock.Lock()
+	defer server.callsLock.Unlock()
+
+	server.callCalled = true
+
+	if server.failCall {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	server.calls = append(server.calls, fakeDbusCall{destination, path, method, args})
+
+	return nil
+}
+
+// madeCalls returns the method calls made so far.
+func (server *FakeDbusServer) madeCalls() []fakeDbusCall {
+	server.callsLock.Lock()
+	defer server.callsLock.Unlock()
+
+	return append([]fakeDbusCall(nil), server.calls...)
+}
+
+func (server *FakeDbusServer) Subscribe(ch chan<- *dbus.Signal) error {
+	server.subscribeCalled = true
+
+	if server.failSubscribe {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	server.subscribers = append(server.subscribers, ch)
+
+	return nil
+}
+
+func (server *FakeDbusServer) AddMatch(rule string) error {
+	server.matchesLock.Lock()
+	defer server.matchesLock.Unlock()
+
+	if server.failAddMatch {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	server.matches = append(server.matches, rule)
+
+	return nil
+}
+
+func (server *FakeDbusServer) RemoveMatch(rule string) error {
+	server.matchesLock.Lock()
+	defer server.matchesLock.Unlock()
+
+	for i, match := range server.matches {
+		if match == rule {
+			server.matches = append(server.matches[:i], server.matches[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown match rule %s", rule)
+}
+
+// addedMatches returns a copy of the match rules currently added.
+func (server *FakeDbusServer) addedMatches() []string {
+	server.matchesLock.Lock()
+	defer server.matchesLock.Unlock()
+
+	return append([]string(nil), server.matches...)
+}
+
+// receive delivers a signal from the bus to the subscribers.
+func (server *FakeDbusServer) receive(signal *dbus.Signal) {
+	for _, subscriber := range server.subscribers {
+		subscriber <- signal
+	}
+}
@@ -0,0 +1,260 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeSnapdClient is a fake implementation of the snapdClient interface, for
+// use within tests. Changes it creates stay in progress until finished via
+// finishChange.
+type FakeSnapdClient struct {
+	lock sync.Mutex
+
+	failInstall bool
+	failRemove  bool
+	failRefresh bool
+	failAbort   bool
+	failChange  bool
+	failChanges bool
+	failFindOne bool
+	failList    bool
+
+	// Key: Snap name
+	// Value: Publisher of the snap
+	publishers map[string]string
+
+	// Key: Snap name
+	// Value: Revision of the installed snap
+	installed map[string]int
+
+	// Operations requested, e.g. "install foo"
+	requests []string
+
+	// Called before each change is created, if set
+	beforeChange func()
+
+	// Key: Change ID
+	// Value: Change
+	changes      map[string]*client.Change
+	lastChangeID int
+}
+
+func (snapd *FakeSnapdClient) Install(name string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("install", name, snapd.failInstall)
+}
+
+func (snapd *FakeSnapdClient) Remove(name string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("remove", name, snapd.failRemove)
+}
+
+func (snapd *FakeSnapdClient) Refresh(name string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("refresh", name, snapd.failRefresh)
+}
+
+func (snapd *FakeSnapdClient) RefreshMany(names []string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("refresh-many", "", snapd.failRefresh)
+}
+
+func (snapd *FakeSnapdClient) InstallMany(names []string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("install-many", strings.Join(names, ","), snapd.failInstall)
+}
+
+func (snapd *FakeSnapdClient) RemoveMany(names []string, options *client.SnapOptions) (string, error) {
+	return snapd.newChange("remove-many", strings.Join(names, ","), snapd.failRemove)
+}
+
+func (snapd *FakeSnapdClient) FindOne(name string) (*client.Snap, *client.ResultInfo, error) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.failFindOne {
+		return nil, nil, fmt.Errorf("Failed at user request")
+	}
+
+	return &client.Snap{Name: name, Developer: snapd.publishers[name]}, nil, nil
+}
+
+func (snapd *FakeSnapdClient) List(names []string) ([]*client.Snap, error) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.failList {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	var snaps []*client.Snap
+	for name, revision := range snapd.installed {
+		if len(names) == 0 || contains(names, name) {
+			snaps = append(snaps, &client.Snap{Name: name, Revision: snap.R(revision)})
+		}
+	}
+
+	return snaps, nil
+}
+
+// setInstalled changes the revision of an installed snap (zero if not
+// installed).
+func (snapd *FakeSnapdClient) setInstalled(name string, revision int) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.installed == nil {
+		snapd.installed = make(map[string]int)
+	}
+
+	if revision == 0 {
+		delete(snapd.installed, name)
+	} else {
+		snapd.installed[name] = revision
+	}
+}
+
+func (snapd *FakeSnapdClient) Abort(id string) (*client.Change, error) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	snapd.requests = append(snapd.requests, "abort "+id)
+
+	if snapd.failAbort {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	change, ok := snapd.changes[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown change %s", id)
+	}
+
+	copy := *change
+	return &copy, nil
+}
+
+func (snapd *FakeSnapdClient) Change(id string) (*client.Change, error) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.failChange {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	change, ok := snapd.changes[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown change %s", id)
+	}
+
+	copy := *change
+	return &copy, nil
+}
+
+func (snapd *FakeSnapdClient) Changes(options *client.ChangesOptions) ([]*client.Change, error) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.failChanges {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	var changes []*client.Change
+	for _, change := range snapd.changes {
+		if !change.Ready {
+			copy := *change
+			changes = append(changes, &copy)
+		}
+	}
+
+	return changes, nil
+}
+
+// addChange adds a change as if it was already in progress in snapd.
+func (snapd *FakeSnapdClient) addChange(change *client.Change) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	if snapd.changes == nil {
+		snapd.changes = make(map[string]*client.Change)
+	}
+
+	snapd.changes[change.ID] = change
+}
+
+// setTasks replaces the tasks of a change.
+func (snapd *FakeSnapdClient) setTasks(id string, tasks []*client.Task) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	snapd.changes[id].Tasks = tasks
+}
+
+// finishChange makes a change ready with the given status.
+func (snapd *FakeSnapdClient) finishChange(id string, status string) {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	snapd.changes[id].Ready = true
+	snapd.changes[id].Status = status
+}
+
+// requested returns a copy of the operations requested so far.
+func (snapd *FakeSnapdClient) requested() []string {
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	return append([]string(nil), snapd.requests...)
+}
+
+func (snapd *FakeSnapdClient) newChange(kind string, name string, fail bool) (string, error) {
+	if snapd.beforeChange != nil {
+		snapd.beforeChange()
+	}
+
+	snapd.lock.Lock()
+	defer snapd.lock.Unlock()
+
+	snapd.requests = append(snapd.requests, fmt.Sprintf("%s %s", kind, name))
+
+	if fail {
+		return "", fmt.Errorf("Failed at user request")
+	}
+
+	if snapd.changes == nil {
+		snapd.changes = make(map[string]*client.Change)
+	}
+
+	snapd.lastChangeID++
+	id := strconv.Itoa(snapd.lastChangeID)
+	snapd.changes[id] = &client.Change{ID: id, Kind: kind, Status: "Doing"}
+
+	return id, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+
+	return false
+}
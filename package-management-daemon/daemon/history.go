@@ -0,0 +1,188 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultHistoryLimit is the number of entries kept in the history journal,
+// unless configured otherwise.
+const defaultHistoryLimit = 500
+
+// historyEntry records how an operation went, as exposed via `GetHistory` and
+// stored in the history journal.
+type historyEntry struct {
+	Id         string   `json:"id"`
+	Kind       string   `json:"kind"`
+	PackageIds []string `json:"package-ids"`
+
+	// Key: Package ID
+	// Value: Revision of the package (absent if not installed)
+	RevisionsBefore map[string]string `json:"revisions-before"`
+	RevisionsAfter  map[string]string `json:"revisions-after"`
+
+	StartTime int64  `json:"start-time"` // Unix time the operation was requested
+	EndTime   int64  `json:"end-time"`   // Unix time the operation finished
+	Status    string `json:"status"`     // Final status ("done", "error", "canceled")
+	Error     string `json:"error"`      // Reason for the failure (empty if none)
+}
+
+// history is a bounded journal of finished operations, kept on disk so it
+// outlives the daemon.
+type history struct {
+	path string
+
+	lock    sync.Mutex
+	limit   int
+	entries []historyEntry // Oldest first
+}
+
+// DefaultHistoryPath determines where the history journal lives, following
+// the XDG base directory specification.
+//
+// Returns:
+// - Path to the history journal.
+func DefaultHistoryPath() string {
+	return filepath.Join(stateDirectory(), "package-management-daemon-history.json")
+}
+
+// loadHistory reads the history journal at the given path. A missing journal
+// simply results in an empty history.
+//
+// Parameters:
+// path: Path to the history journal.
+// limit: Maximum number of entries to keep.
+//
+// Returns:
+// - Pointer to the loaded history (nil if error)
+// - Error (nil if none)
+func loadHistory(path string, limit int) (*history, error) {
+	journal := &history{path: path, limit: limit}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return journal, nil
+		}
+
+		return nil, fmt.Errorf(`Unable to open history "%s": %s`, path, err)
+	}
+	defer file.Close()
+
+	// One entry per line, so a truncated last line only loses that entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry historyEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			journal.entries = append(journal.entries, entry)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to read history "%s": %s`, path, err)
+	}
+
+	journal.trim()
+
+	return journal, nil
+}
+
+// record adds an entry to the history, and saves the journal.
+//
+// Parameters:
+// entry: Entry to add.
+//
+// Returns:
+// - Error (nil if none)
+func (journal *history) record(entry historyEntry) error {
+	if journal == nil {
+		return nil
+	}
+
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	journal.entries = append(journal.entries, entry)
+	journal.trim()
+
+	return journal.save()
+}
+
+// recent lists the latest entries of the history.
+//
+// Parameters:
+// limit: Maximum number of entries to list (zero for all).
+//
+// Returns:
+// - Entries, newest first.
+func (journal *history) recent(limit int) []historyEntry {
+	entries := []historyEntry{}
+	if journal == nil {
+		return entries
+	}
+
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	for i := len(journal.entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+
+		entries = append(entries, journal.entries[i])
+	}
+
+	return entries
+}
+
+// trim drops the oldest entries beyond the limit. Must be called with the lock
+// held.
+func (journal *history) trim() {
+	if journal.limit > 0 && len(journal.entries) > journal.limit {
+		journal.entries = append([]historyEntry(nil),
+			journal.entries[len(journal.entries)-journal.limit:]...)
+	}
+}
+
+// save writes the journal to disk, replacing it atomically so a crash never
+// leaves it half written. Must be called with the lock held.
+//
+// Returns:
+// - Error (nil if none)
+func (journal *history) save() error {
+	return writeFileAtomically(journal.path, func(writer io.Writer) error {
+		encoder := json.NewEncoder(writer)
+		for _, entry := range journal.entries {
+			err := encoder.Encode(entry)
+			if err != nil {
+				return fmt.Errorf("Unable to encode history entry: %s", err)
+			}
+		}
+
+		return nil
+	})
+}
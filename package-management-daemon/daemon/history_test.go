@@ -0,0 +1,159 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// historyDirectory creates a temporary directory for a history journal.
+func historyDirectory(t *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "history_test")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %s", err)
+	}
+
+	return directory, func() { os.RemoveAll(directory) }
+}
+
+// Test that entries survive reloading the journal.
+func TestHistory_recordLoad(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	// The directory holding the journal is created as needed
+	path := filepath.Join(directory, "state", "history.json")
+
+	journal, err := loadHistory(path, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing history: %s", err)
+	}
+
+	first := historyEntry{Id: "1", Kind: "install", PackageIds: []string{"foo"},
+		RevisionsBefore: map[string]string{}, RevisionsAfter: map[string]string{"foo": "3"},
+		StartTime: 10, EndTime: 20, Status: operationStatusDone}
+	second := historyEntry{Id: "2", Kind: "remove", PackageIds: []string{"foo"},
+		RevisionsBefore: map[string]string{"foo": "3"}, RevisionsAfter: map[string]string{"foo": "3"},
+		StartTime: 30, EndTime: 40, Status: operationStatusError, Error: "Failed"}
+
+	for _, entry := range []historyEntry{first, second} {
+		err = journal.record(entry)
+		if err != nil {
+			t.Fatalf("Unexpected error recording entry: %s", err)
+		}
+	}
+
+	journal, err = loadHistory(path, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error loading history: %s", err)
+	}
+
+	entries := journal.recent(0)
+	if !reflect.DeepEqual(entries, []historyEntry{second, first}) {
+		t.Errorf("Entries were %#v, expected the newest first", entries)
+	}
+
+	entries = journal.recent(1)
+	if !reflect.DeepEqual(entries, []historyEntry{second}) {
+		t.Errorf("Entries were %#v, expected only the newest", entries)
+	}
+}
+
+// Test that the journal only keeps the latest entries.
+func TestHistory_limit(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	path := filepath.Join(directory, "history.json")
+
+	journal, _ := loadHistory(path, 2)
+	for _, id := range []string{"1", "2", "3"} {
+		journal.record(historyEntry{Id: id})
+	}
+
+	journal, err := loadHistory(path, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error loading history: %s", err)
+	}
+
+	entries := journal.recent(0)
+	if len(entries) != 2 || entries[0].Id != "3" || entries[1].Id != "2" {
+		t.Errorf("Entries were %#v, expected only the 2 latest", entries)
+	}
+
+	// Lowering the limit drops entries right away
+	journal, _ = loadHistory(path, 1)
+	if entries := journal.recent(0); len(entries) != 1 || entries[0].Id != "3" {
+		t.Errorf("Entries were %#v, expected only the latest", entries)
+	}
+}
+
+// Test that a damaged entry doesn't prevent loading the others.
+func TestHistory_damagedEntry(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	path := filepath.Join(directory, "history.json")
+	err := ioutil.WriteFile(path, []byte(`{"id": "1"}
+{"id": "2", "ki`), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write history: %s", err)
+	}
+
+	journal, err := loadHistory(path, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error loading history: %s", err)
+	}
+
+	if entries := journal.recent(0); len(entries) != 1 || entries[0].Id != "1" {
+		t.Errorf("Entries were %#v, expected only the intact one", entries)
+	}
+}
+
+// Test that a nil history records nothing.
+func TestHistory_nil(t *testing.T) {
+	var journal *history
+
+	err := journal.record(historyEntry{Id: "1"})
+	if err != nil {
+		t.Errorf("Unexpected error recording entry: %s", err)
+	}
+
+	if entries := journal.recent(0); entries == nil || len(entries) != 0 {
+		t.Errorf("Entries were %#v, expected none", entries)
+	}
+}
+
+// Test that the history journal follows the XDG base directory
+// specification.
+func TestDefaultHistoryPath(t *testing.T) {
+	oldStateHome := os.Getenv("XDG_STATE_HOME")
+	defer os.Setenv("XDG_STATE_HOME", oldStateHome)
+
+	os.Setenv("XDG_STATE_HOME", "/foo")
+
+	expected := "/foo/unity-scope-snappy/package-management-daemon-history.json"
+	if path := DefaultHistoryPath(); path != expected {
+		t.Errorf(`History path was "%s", expected "%s"`, path, expected)
+	}
+}
@@ -0,0 +1,185 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"log"
+	"net/url"
+	"sync"
+)
+
+const (
+	notificationsBusName    = "org.freedesktop.Notifications"
+	notificationsObjectPath = "/org/freedesktop/Notifications"
+	notificationsMethod     = notificationsBusName + ".Notify"
+
+	// storeScopeId is the ID of the scope the notifications lead back to.
+	storeScopeId = "snappy-store"
+
+	// packageQueryPrefix prefixes the store scope queries showing a single
+	// package, whose preview can be opened right away, rather than searching
+	// for it.
+	packageQueryPrefix = "pkgname:"
+)
+
+// notifier posts desktop notifications when operations finish or fail, since
+// the user may have left the preview following them long ago.
+type notifier struct {
+	dbusConnection DbusWrapper
+
+	lock    sync.Mutex
+	enabled bool
+}
+
+// newNotifier creates a new notifier, enabled by default.
+//
+// Parameters:
+// dbusConnection: Connection to the dbus bus.
+//
+// Returns:
+// - Pointer to new notifier.
+func newNotifier(dbusConnection DbusWrapper) *notifier {
+	return &notifier{dbusConnection: dbusConnection, enabled: true}
+}
+
+// setEnabled enables or disables the notifications.
+//
+// Parameters:
+// enabled: Whether or not notifications should be posted.
+func (notifier *notifier) setEnabled(enabled bool) {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+
+	notifier.enabled = enabled
+}
+
+// isEnabled checks whether notifications should be posted.
+//
+// Returns:
+// - Whether or not notifications are enabled.
+func (notifier *notifier) isEnabled() bool {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+
+	return notifier.enabled
+}
+
+// notifyFinished posts a notification for an operation that finished
+// successfully, e.g. "foo installed".
+//
+// Parameters:
+// op: Operation that just finished.
+func (notifier *notifier) notifyFinished(op *operation) {
+	done, _ := operationVerbs(op.kind)
+	notifier.notify(fmt.Sprintf("%s %s", operationSubject(op), done), "", op)
+}
+
+// notifyError posts a notification for an operation that failed, e.g.
+// "foo failed to install".
+//
+// Parameters:
+// op: Operation that failed.
+// message: Reason for the failure.
+func (notifier *notifier) notifyError(op *operation, message string) {
+	_, do := operationVerbs(op.kind)
+	notifier.notify(fmt.Sprintf("%s failed to %s", operationSubject(op), do), message, op)
+}
+
+// notify posts a notification with an action opening the store on the
+// packages being operated upon.
+//
+// Parameters:
+// summary: Summary of the notification.
+// body: Body of the notification (may be empty).
+// op: Operation the notification is about.
+func (notifier *notifier) notify(summary string, body string, op *operation) {
+	if !notifier.isEnabled() {
+		return
+	}
+
+	// Unity8 hands the ID of the action over to the URL dispatcher
+	actions := []string{operationURI(op), "Open"}
+	hints := map[string]dbus.Variant{
+		"x-canonical-switch-to-application": dbus.MakeVariant(true),
+	}
+
+	err := notifier.dbusConnection.Call(notificationsBusName,
+		notificationsObjectPath, notificationsMethod, storeScopeId, uint32(0),
+		"", summary, body, actions, hints, int32(-1))
+	if err != nil {
+		log.Printf(`package-management-daemon: Unable to post notification "%s": %s`, summary, err)
+	}
+}
+
+// operationSubject describes the packages being operated upon.
+//
+// Parameters:
+// op: Operation to describe.
+//
+// Returns:
+// - Description of the packages, e.g. "foo" or "3 snaps".
+func operationSubject(op *operation) string {
+	switch {
+	case op.packageId != "":
+		return op.packageId
+	case len(op.packageIds) > 1:
+		return fmt.Sprintf("%d snaps", len(op.packageIds))
+	default:
+		return "All snaps"
+	}
+}
+
+// operationVerbs returns the verbs used to describe a kind of operation.
+//
+// Parameters:
+// kind: Kind of operation ("install", "remove", "refresh").
+//
+// Returns:
+// - Past participle, e.g. "installed".
+// - Infinitive, e.g. "install".
+func operationVerbs(kind string) (string, string) {
+	switch kind {
+	case operationKindInstall:
+		return "installed", "install"
+	case operationKindRemove:
+		return "removed", "remove"
+	default:
+		return "updated", "update"
+	}
+}
+
+// operationURI creates the URI opening the store on the packages being
+// operated upon.
+//
+// Parameters:
+// op: Operation to open.
+//
+// Returns:
+// - scope:// URI showing the package in the store, or simply opening the
+// store if the operation involves several packages.
+func operationURI(op *operation) string {
+	if op.packageId == "" {
+		return "scope://" + storeScopeId
+	}
+
+	return "scope://" + storeScopeId + "?q=" +
+		url.QueryEscape(packageQueryPrefix+op.packageId)
+}
@@ -0,0 +1,112 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"testing"
+)
+
+// Data for TestNotifier_notifyFinished
+var notifyFinishedTests = []struct {
+	op              *operation
+	expectedSummary string
+	expectedURI     string
+}{
+	{newOperation("1", operationKindInstall, "foo"), "foo installed", "scope://snappy-store?q=pkgname%3Afoo"},
+	{newOperation("1", operationKindRemove, "foo"), "foo removed", "scope://snappy-store?q=pkgname%3Afoo"},
+	{newOperation("1", operationKindRefresh, "foo bar"), "foo bar updated", "scope://snappy-store?q=pkgname%3Afoo+bar"},
+	{newOperation("1", operationKindInstall, "foo", "bar"), "2 snaps installed", "scope://snappy-store"},
+	{newOperation("1", operationKindRefresh), "All snaps updated", "scope://snappy-store"},
+}
+
+// Test that finished operations result in a notification opening the store.
+func TestNotifier_notifyFinished(t *testing.T) {
+	for i, test := range notifyFinishedTests {
+		server := new(FakeDbusServer)
+		newNotifier(server).notifyFinished(test.op)
+
+		calls := server.madeCalls()
+		if len(calls) != 1 {
+			t.Errorf("Test case %d: Got %d calls, expected 1", i, len(calls))
+			continue
+		}
+
+		call := calls[0]
+		if call.destination != notificationsBusName || call.method != notificationsMethod {
+			t.Errorf(`Test case %d: Called "%s" on "%s", expected "%s" on "%s"`, i,
+				call.method, call.destination, notificationsMethod, notificationsBusName)
+		}
+
+		if len(call.args) != 8 {
+			t.Errorf("Test case %d: Got %d arguments, expected 8", i, len(call.args))
+			continue
+		}
+
+		if call.args[3] != test.expectedSummary {
+			t.Errorf(`Test case %d: Summary was "%s", expected "%s"`, i, call.args[3], test.expectedSummary)
+		}
+
+		actions := call.args[5].([]string)
+		if len(actions) != 2 || actions[0] != test.expectedURI {
+			t.Errorf(`Test case %d: Actions were %v, expected to open "%s"`, i, actions, test.expectedURI)
+		}
+	}
+}
+
+// Test that failed operations result in a notification including the reason.
+func TestNotifier_notifyError(t *testing.T) {
+	server := new(FakeDbusServer)
+	newNotifier(server).notifyError(newOperation("1", operationKindInstall, "foo"), "reason")
+
+	calls := server.madeCalls()
+	if len(calls) != 1 {
+		t.Fatalf("Got %d calls, expected 1", len(calls))
+	}
+
+	if calls[0].args[3] != "foo failed to install" {
+		t.Errorf(`Summary was "%s", expected "foo failed to install"`, calls[0].args[3])
+	}
+
+	if calls[0].args[4] != "reason" {
+		t.Errorf(`Body was "%s", expected "reason"`, calls[0].args[4])
+	}
+}
+
+// Test that no notifications are posted once disabled.
+func TestNotifier_disabled(t *testing.T) {
+	server := new(FakeDbusServer)
+	notifier := newNotifier(server)
+	notifier.setEnabled(false)
+
+	notifier.notifyFinished(newOperation("1", operationKindInstall, "foo"))
+
+	if server.callCalled {
+		t.Error("Expected no notification to be posted")
+	}
+}
+
+// Test that failing to post a notification isn't fatal.
+func TestNotifier_failure(t *testing.T) {
+	server := &FakeDbusServer{failCall: true}
+	newNotifier(server).notifyFinished(newOperation("1", operationKindInstall, "foo"))
+
+	if !server.callCalled {
+		t.Error("Expected notifier to attempt posting the notification")
+	}
+}
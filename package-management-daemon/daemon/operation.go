@@ -0,0 +1,494 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"github.com/godbus/dbus"
+	"math"
+	"sync"
+	"time"
+)
+
+// Kinds of operations, as exposed via the `Kind` property.
+const (
+	operationKindInstall = "install"
+	operationKindRemove  = "remove"
+	operationKindRefresh = "refresh"
+)
+
+// Statuses of operations, as exposed via the `Status` property.
+const (
+	operationStatusQueued   = "queued"
+	operationStatusRunning  = "running"
+	operationStatusDone     = "done"
+	operationStatusError    = "error"
+	operationStatusCanceled = "canceled"
+)
+
+// rateSmoothing is the weight given to the latest sample when smoothing the
+// download rate.
+const rateSmoothing = 0.3
+
+// operationProgress is the value of the `Progress` property, as well as of
+// each package's entry in the `PackageProgress` property.
+type operationProgress struct {
+	Received uint64
+	Total    uint64
+}
+
+// operation represents a request made to the daemon, and the snapd change
+// carrying it out once it's no longer queued.
+type operation struct {
+	id         string    // ID of the operation, as used in its object path
+	kind       string    // Kind of operation ("install", "remove", "refresh")
+	packageId  string    // ID of the package being operated upon (empty if several)
+	packageIds []string  // IDs of all packages being operated upon (may be empty)
+	startTime  time.Time // Time at which the operation was requested
+	seq        uint64    // Order in which the operation was queued
+
+	lock         sync.Mutex
+	changeID     string // ID of the snapd change (empty while queued)
+	canceled     bool
+	status       string
+	endTime      time.Time
+	received     uint64
+	total        uint64
+	errorMessage string
+
+	// Key: Package ID
+	// Value: Revision installed before the operation started
+	revisionsBefore map[string]string
+
+	// Unique bus names of the callers that requested the operation (empty if
+	// it was resumed from a previous instance of the daemon)
+	owners map[dbus.Sender]bool
+
+	// Key: Package ID
+	// Value: Progress of the package's download
+	packageProgress map[string]operationProgress
+	packagesDone    uint32
+
+	// Smoothed download rate (bytes per second) and estimated time remaining
+	// (seconds, -1 if unknown), along with the last sample they're based on.
+	downloadRate    float64
+	eta             int64
+	lastSampleTime  time.Time
+	lastSampleBytes uint64
+
+	// cancelRequested wakes up the polling job when a cancellation is
+	// requested, so it doesn't need to wait for the next poll.
+	cancelRequested chan struct{}
+}
+
+// newOperation creates a new operation.
+//
+// Parameters:
+// id: ID of the operation.
+// kind: Kind of operation ("install", "remove", "refresh").
+// packageIds: IDs of the packages being operated upon (none for all packages).
+//
+// Returns:
+// - Pointer to new operation, queued until started.
+func newOperation(id string, kind string, packageIds ...string) *operation {
+	op := &operation{
+		id:              id,
+		kind:            kind,
+		packageIds:      packageIds,
+		startTime:       time.Now(),
+		status:          operationStatusQueued,
+		packageProgress: make(map[string]operationProgress),
+		eta:             -1,
+		cancelRequested: make(chan struct{}, 1),
+	}
+
+	if len(packageIds) == 1 {
+		op.packageId = packageIds[0]
+	}
+
+	return op
+}
+
+// queueKeys returns the keys of the queues the operation must wait in: one
+// per package, or the empty key for operations on all packages.
+//
+// Returns:
+// - Queue keys
+func (op *operation) queueKeys() []string {
+	if len(op.packageIds) == 0 {
+		return []string{""}
+	}
+
+	return op.packageIds
+}
+
+// isBatch checks whether the operation is operating on several packages at
+// once.
+//
+// Returns:
+// - Whether or not the operation is a batch
+func (op *operation) isBatch() bool {
+	return len(op.packageIds) > 1
+}
+
+// begin marks the operation as no longer queued, while snapd is asked to
+// start it.
+func (op *operation) begin() {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.status = operationStatusRunning
+}
+
+// start records the snapd change carrying out the operation.
+//
+// Parameters:
+// changeID: ID of the snapd change.
+//
+// Returns:
+// - Whether or not the operation was canceled while snapd was starting it, in
+// which case the change must be aborted.
+func (op *operation) start(changeID string) bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.changeID = changeID
+	op.status = operationStatusRunning
+
+	return op.canceled
+}
+
+// change returns the ID of the snapd change carrying out the operation.
+//
+// Returns:
+// - ID of the snapd change (empty if still queued)
+func (op *operation) change() string {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.changeID
+}
+
+// isQueued checks whether the operation is still waiting to be started.
+//
+// Returns:
+// - Whether or not the operation is queued
+func (op *operation) isQueued() bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.status == operationStatusQueued
+}
+
+// addOwner records a caller as one of those that requested the operation.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+func (op *operation) addOwner(sender dbus.Sender) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if sender == "" {
+		return
+	}
+
+	if op.owners == nil {
+		op.owners = make(map[dbus.Sender]bool)
+	}
+
+	op.owners[sender] = true
+}
+
+// removeOwner forgets about a caller that requested the operation, e.g. because
+// it left the bus.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - Whether or not the caller was the last one owning the operation.
+func (op *operation) removeOwner(sender dbus.Sender) bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if !op.owners[sender] {
+		return false
+	}
+
+	delete(op.owners, sender)
+
+	return len(op.owners) == 0
+}
+
+// isOwnedBy checks whether a caller may control the operation as one of
+// those that requested it. Operations without owners are owned by everyone.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - Whether or not the caller owns the operation
+func (op *operation) isOwnedBy(sender dbus.Sender) bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return len(op.owners) == 0 || op.owners[sender]
+}
+
+// cancel marks the operation as canceled, and wakes up the polling job.
+func (op *operation) cancel() {
+	op.lock.Lock()
+	op.canceled = true
+	op.lock.Unlock()
+
+	select {
+	case op.cancelRequested <- struct{}{}:
+	default:
+		// A wake up is already pending
+	}
+}
+
+// cancelStarting marks the operation as canceled if snapd is still being asked
+// to start it, leaving its change to be aborted once started.
+//
+// Returns:
+// - Whether or not the operation was still being started
+func (op *operation) cancelStarting() bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if op.changeID != "" || op.status != operationStatusRunning {
+		return false
+	}
+
+	op.canceled = true
+
+	return true
+}
+
+// isCanceled checks whether the operation was canceled.
+//
+// Returns:
+// - Whether or not the operation was canceled
+func (op *operation) isCanceled() bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.canceled
+}
+
+// advanceProgress records the latest progress of the operation, unless it
+// would move the progress backwards.
+//
+// Parameters:
+// received: Received count.
+// total: Total count.
+//
+// Returns:
+// - Recorded received count.
+// - Recorded total count.
+func (op *operation) advanceProgress(received uint64, total uint64) (uint64, uint64) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	// Compare received/total against op.received/op.total without dividing
+	if total > 0 && (op.total == 0 || received*op.total >= op.received*total) {
+		op.received = received
+		op.total = total
+	}
+
+	return op.received, op.total
+}
+
+// setPackageProgress records the latest progress of each package.
+//
+// Parameters:
+// progress: Progress of each package's download.
+// done: Number of packages done.
+func (op *operation) setPackageProgress(progress map[string]operationProgress, done uint32) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.packageProgress = progress
+	op.packagesDone = done
+}
+
+// sampleDownload records a sample of the download progress, updating the
+// smoothed download rate and the estimated time remaining.
+//
+// Parameters:
+// downloaded: Number of bytes downloaded so far.
+// total: Total number of bytes to download.
+// now: Time at which the sample was taken.
+//
+// Returns:
+// - Download rate in bytes per second (0 if not downloading).
+// - Estimated time remaining in seconds (-1 if unknown).
+func (op *operation) sampleDownload(downloaded uint64, total uint64, now time.Time) (uint64, int64) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if total == 0 || downloaded >= total {
+		// Not downloading (anymore)
+		op.downloadRate = 0
+		op.eta = -1
+		op.lastSampleTime = time.Time{}
+		return 0, -1
+	}
+
+	// A download going backwards (e.g. snapd retrying it) starts over
+	if op.lastSampleTime.IsZero() || downloaded < op.lastSampleBytes {
+		op.lastSampleTime = now
+		op.lastSampleBytes = downloaded
+		return uint64(op.downloadRate), op.eta
+	}
+
+	elapsed := now.Sub(op.lastSampleTime).Seconds()
+	if elapsed <= 0 {
+		return uint64(op.downloadRate), op.eta
+	}
+
+	rate := float64(downloaded-op.lastSampleBytes) / elapsed
+	if op.downloadRate == 0 {
+		op.downloadRate = rate
+	} else {
+		op.downloadRate = rateSmoothing*rate + (1-rateSmoothing)*op.downloadRate
+	}
+
+	op.lastSampleTime = now
+	op.lastSampleBytes = downloaded
+
+	if op.downloadRate < 1 {
+		op.eta = -1
+	} else {
+		op.eta = int64(math.Ceil(float64(total-downloaded) / op.downloadRate))
+	}
+
+	return uint64(op.downloadRate), op.eta
+}
+
+// finish records the final status of the operation.
+//
+// Parameters:
+// status: Final status ("done", "error", "canceled").
+// errorMessage: Reason for the failure (empty if none).
+func (op *operation) finish(status string, errorMessage string) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.status = status
+	op.errorMessage = errorMessage
+	op.endTime = time.Now()
+}
+
+// setRevisionsBefore records the revisions of the packages installed before
+// the operation started.
+//
+// Parameters:
+// revisions: Revision of each installed package.
+func (op *operation) setRevisionsBefore(revisions map[string]string) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.revisionsBefore = revisions
+}
+
+// historyEntry describes how the operation went, for the history journal.
+//
+// Parameters:
+// revisionsAfter: Revision of each package installed once it finished.
+//
+// Returns:
+// - History entry.
+func (op *operation) historyEntry(revisionsAfter map[string]string) historyEntry {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	entry := historyEntry{
+		Id:              op.id,
+		Kind:            op.kind,
+		PackageIds:      append([]string{}, op.packageIds...),
+		RevisionsBefore: make(map[string]string),
+		RevisionsAfter:  make(map[string]string),
+		StartTime:       op.startTime.Unix(),
+		EndTime:         op.endTime.Unix(),
+		Status:          op.status,
+		Error:           op.errorMessage,
+	}
+
+	for packageId, revision := range op.revisionsBefore {
+		entry.RevisionsBefore[packageId] = revision
+	}
+
+	for packageId, revision := range revisionsAfter {
+		entry.RevisionsAfter[packageId] = revision
+	}
+
+	return entry
+}
+
+// failure returns the reason the operation failed.
+//
+// Returns:
+// - Reason for the failure (empty if none).
+func (op *operation) failure() string {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.errorMessage
+}
+
+// isFinished checks whether the operation has reached a final status.
+//
+// Returns:
+// - Whether or not the operation is finished
+func (op *operation) isFinished() bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.status != operationStatusQueued &&
+		op.status != operationStatusRunning
+}
+
+// properties takes a snapshot of the operation's DBus properties.
+//
+// Returns:
+// - Map of property names to their values
+func (op *operation) properties() map[string]dbus.Variant {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	packageIds := op.packageIds
+	if packageIds == nil {
+		packageIds = []string{}
+	}
+
+	return map[string]dbus.Variant{
+		"PackageId":       dbus.MakeVariant(op.packageId),
+		"PackageIds":      dbus.MakeVariant(packageIds),
+		"Kind":            dbus.MakeVariant(op.kind),
+		"Status":          dbus.MakeVariant(op.status),
+		"Progress":        dbus.MakeVariant(operationProgress{op.received, op.total}),
+		"PackagesDone":    dbus.MakeVariant(op.packagesDone),
+		"PackageProgress": dbus.MakeVariant(op.packageProgress),
+		"Error":           dbus.MakeVariant(op.errorMessage),
+		"StartTime":       dbus.MakeVariant(op.startTime.Unix()),
+		"DownloadRate":    dbus.MakeVariant(uint64(op.downloadRate)),
+		"Eta":             dbus.MakeVariant(op.eta),
+	}
+}
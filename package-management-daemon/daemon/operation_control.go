@@ -0,0 +1,60 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"github.com/godbus/dbus"
+)
+
+// operationControl implements the methods of a single operation, allowing
+// clients to act upon it via its object path.
+type operationControl struct {
+	manager *SnapdPackageManagerInterface
+	op      *operation
+}
+
+// newOperationControl creates a new operationControl.
+//
+// Parameters:
+// manager: Package manager carrying out the operation.
+// op: Operation to be acted upon.
+//
+// Returns:
+// - Pointer to new operationControl
+func newOperationControl(manager *SnapdPackageManagerInterface, op *operation) *operationControl {
+	return &operationControl{manager: manager, op: op}
+}
+
+// Cancel cancels the operation, whether it's still queued or already in
+// progress. Its object path will receive the `canceled` signal once it's done.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - DBus error (nil if none)
+func (control *operationControl) Cancel(sender dbus.Sender) *dbus.Error {
+	control.manager.touch()
+
+	if dbusErr := control.manager.authorize(sender); dbusErr != nil {
+		return dbusErr
+	}
+
+	return control.manager.cancelOperation(sender, control.op)
+}
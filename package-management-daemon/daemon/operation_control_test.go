@@ -0,0 +1,64 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test typical Cancel usage.
+func TestOperationControl_cancel(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	control := newOperationControl(manager, manager.operationForPackage("foo"))
+
+	dbusErr := control.Cancel(":1.42")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	if !control.op.isCanceled() {
+		t.Error("Expected operation to be canceled")
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the install to be aborted`, requests)
+	}
+}
+
+// Test that callers not allowed to manage packages can't cancel operations.
+func TestOperationControl_cancel_unauthorized(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	control := newOperationControl(manager, manager.operationForPackage("foo"))
+
+	manager.authority = &FakeAuthority{denied: true}
+
+	dbusErr := control.Cancel(":1.42")
+	if dbusErr == nil || dbusErr.Name != "org.freedesktop.DBus.Error.AccessDenied" {
+		t.Errorf("Expected access to be denied, got %v", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected the install not to be aborted`, requests)
+	}
+}
@@ -0,0 +1,183 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// operationPaths remembers which operations that had to wait in a queue are
+// carried out by which snapd changes, so they keep their object paths should
+// the daemon be restarted while they're in progress.
+type operationPaths struct {
+	path string
+
+	lock sync.Mutex
+
+	// Key: Change ID
+	// Value: ID of the operation carried out by the change
+	ids map[string]string
+}
+
+// DefaultOperationsPath determines where the object paths of operations in
+// progress are kept, following the XDG base directory specification.
+//
+// Returns:
+// - Path to the operations journal.
+func DefaultOperationsPath() string {
+	return filepath.Join(stateDirectory(), "package-management-daemon-operations.json")
+}
+
+// loadOperationPaths reads the operations journal at the given path. A
+// missing journal simply results in no operations being remembered.
+//
+// Parameters:
+// path: Path to the operations journal.
+//
+// Returns:
+// - Pointer to the loaded journal (nil if error)
+// - Error (nil if none)
+func loadOperationPaths(path string) (*operationPaths, error) {
+	paths := &operationPaths{path: path, ids: make(map[string]string)}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return paths, nil
+		}
+
+		return nil, fmt.Errorf(`Unable to read operations "%s": %s`, path, err)
+	}
+
+	err = json.Unmarshal(contents, &paths.ids)
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to parse operations "%s": %s`, path, err)
+	}
+
+	return paths, nil
+}
+
+// remember records the operation carried out by a change, and saves the
+// journal.
+//
+// Parameters:
+// changeID: ID of the snapd change.
+// operationId: ID of the operation.
+//
+// Returns:
+// - Error (nil if none)
+func (paths *operationPaths) remember(changeID string, operationId string) error {
+	if paths == nil {
+		return nil
+	}
+
+	paths.lock.Lock()
+	defer paths.lock.Unlock()
+
+	paths.ids[changeID] = operationId
+
+	return paths.save()
+}
+
+// forget drops the operations carried out by the given changes, saving the
+// journal if any of them was remembered.
+//
+// Parameters:
+// changeIDs: IDs of the snapd changes.
+//
+// Returns:
+// - Error (nil if none)
+func (paths *operationPaths) forget(changeIDs ...string) error {
+	if paths == nil {
+		return nil
+	}
+
+	paths.lock.Lock()
+	defer paths.lock.Unlock()
+
+	changed := false
+	for _, changeID := range changeIDs {
+		if _, ok := paths.ids[changeID]; ok {
+			delete(paths.ids, changeID)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return paths.save()
+}
+
+// lookup finds the operation carried out by a change.
+//
+// Parameters:
+// changeID: ID of the snapd change.
+//
+// Returns:
+// - ID of the operation.
+// - Whether or not the change was remembered.
+func (paths *operationPaths) lookup(changeID string) (string, bool) {
+	if paths == nil {
+		return "", false
+	}
+
+	paths.lock.Lock()
+	defer paths.lock.Unlock()
+
+	operationId, ok := paths.ids[changeID]
+	return operationId, ok
+}
+
+// changes lists the changes whose operations are remembered.
+//
+// Returns:
+// - IDs of the snapd changes.
+func (paths *operationPaths) changes() []string {
+	if paths == nil {
+		return nil
+	}
+
+	paths.lock.Lock()
+	defer paths.lock.Unlock()
+
+	var changeIDs []string
+	for changeID := range paths.ids {
+		changeIDs = append(changeIDs, changeID)
+	}
+
+	return changeIDs
+}
+
+// save writes the journal to disk. Must be called with the lock held.
+//
+// Returns:
+// - Error (nil if none)
+func (paths *operationPaths) save() error {
+	return writeFileAtomically(paths.path, func(writer io.Writer) error {
+		return json.NewEncoder(writer).Encode(paths.ids)
+	})
+}
@@ -0,0 +1,108 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Test that remembered operations survive reloading the journal.
+func TestOperationPaths_rememberLoad(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	path := filepath.Join(directory, "state", "operations.json")
+
+	paths, err := loadOperationPaths(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing journal: %s", err)
+	}
+
+	for changeID, operationId := range map[string]string{"3": "queued1", "4": "queued2"} {
+		err = paths.remember(changeID, operationId)
+		if err != nil {
+			t.Fatalf("Unexpected error remembering operation: %s", err)
+		}
+	}
+
+	err = paths.forget("3", "5")
+	if err != nil {
+		t.Fatalf("Unexpected error forgetting operations: %s", err)
+	}
+
+	paths, err = loadOperationPaths(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading journal: %s", err)
+	}
+
+	operationId, ok := paths.lookup("4")
+	if !ok || operationId != "queued2" {
+		t.Errorf("Operation was %q, expected queued2", operationId)
+	}
+
+	_, ok = paths.lookup("3")
+	if ok {
+		t.Error("Expected forgotten operation to be gone")
+	}
+
+	changeIDs := paths.changes()
+	sort.Strings(changeIDs)
+	if !reflect.DeepEqual(changeIDs, []string{"4"}) {
+		t.Errorf("Changes were %v, expected only 4", changeIDs)
+	}
+}
+
+// Test that a journal that can't be parsed results in an error.
+func TestOperationPaths_corrupt(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	path := filepath.Join(directory, "operations.json")
+	err := writeFileAtomically(path, func(writer io.Writer) error {
+		_, err := writer.Write([]byte("not json"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error writing journal: %s", err)
+	}
+
+	_, err = loadOperationPaths(path)
+	if err == nil {
+		t.Error("Expected an error due to the journal being corrupt")
+	}
+}
+
+// Test that a missing journal remembers nothing.
+func TestOperationPaths_nil(t *testing.T) {
+	var paths *operationPaths
+
+	err := paths.remember("3", "queued1")
+	if err != nil {
+		t.Errorf("Unexpected error remembering without a journal: %s", err)
+	}
+
+	_, ok := paths.lookup("3")
+	if ok {
+		t.Error("Expected nothing to be remembered without a journal")
+	}
+}
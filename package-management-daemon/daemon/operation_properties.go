@@ -0,0 +1,192 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+)
+
+const (
+	// propertiesInterfaceName is the standard DBus properties interface.
+	propertiesInterfaceName = "org.freedesktop.DBus.Properties"
+
+	// operationIntrospectionXml is the XML to be used for the Introspection
+	// interface of each operation's object path. The placeholder is replaced
+	// by the name of the interface carrying the methods, properties and
+	// signals.
+	operationIntrospectionXml = `
+		<node>
+			<interface name="%s">
+				<method name="Cancel">
+				</method>
+				<property name="PackageId" type="s" access="read"/>
+				<property name="PackageIds" type="as" access="read"/>
+				<property name="Kind" type="s" access="read"/>
+				<property name="Status" type="s" access="read"/>
+				<property name="Progress" type="(tt)" access="read"/>
+				<property name="PackagesDone" type="u" access="read"/>
+				<property name="PackageProgress" type="a{s(tt)}" access="read"/>
+				<property name="Error" type="s" access="read"/>
+				<property name="StartTime" type="x" access="read"/>
+				<property name="DownloadRate" type="t" access="read"/>
+				<property name="Eta" type="x" access="read"/>
+				<signal name="progress">
+					<arg name="received" type="t" />
+					<arg name="total" type="t" />
+				</signal>
+				<signal name="downloadRate">
+					<arg name="rate" type="t" />
+					<arg name="eta" type="x" />
+				</signal>
+				<signal name="finished">
+					<arg name="path" type="s" />
+				</signal>
+				<signal name="error">
+					<arg name="error" type="s" />
+				</signal>
+				<signal name="canceled">
+					<arg name="success" type="b" />
+				</signal>
+			</interface>
+			<interface name="` + propertiesInterfaceName + `">
+				<method name="Get">
+					<arg name="interface" type="s" direction="in"/>
+					<arg name="property" type="s" direction="in"/>
+					<arg name="value" type="v" direction="out"/>
+				</method>
+				<method name="GetAll">
+					<arg name="interface" type="s" direction="in"/>
+					<arg name="properties" type="a{sv}" direction="out"/>
+				</method>
+				<method name="Set">
+					<arg name="interface" type="s" direction="in"/>
+					<arg name="property" type="s" direction="in"/>
+					<arg name="value" type="v" direction="in"/>
+				</method>
+			</interface>` +
+		introspect.IntrospectDataString +
+		`</node>`
+)
+
+// operationProperties implements the org.freedesktop.DBus.Properties
+// interface for a single operation, allowing clients that attach late to query
+// its state.
+type operationProperties struct {
+	op            *operation
+	interfaceName string
+}
+
+// newOperationProperties creates a new operationProperties.
+//
+// Parameters:
+// op: Operation whose properties will be exposed.
+// interfaceName: DBus interface name carrying the properties.
+//
+// Returns:
+// - Pointer to new operationProperties
+func newOperationProperties(op *operation, interfaceName string) *operationProperties {
+	return &operationProperties{op: op, interfaceName: interfaceName}
+}
+
+// Get returns the value of a single property.
+//
+// Parameters:
+// iface: Interface carrying the property.
+// property: Name of the property.
+//
+// Returns:
+// - Value of the property.
+// - DBus error (nil if none)
+func (properties *operationProperties) Get(iface string, property string) (dbus.Variant, *dbus.Error) {
+	all, dbusErr := properties.GetAll(iface)
+	if dbusErr != nil {
+		return dbus.Variant{}, dbusErr
+	}
+
+	value, ok := all[property]
+	if !ok {
+		return dbus.Variant{}, unknownPropertyError(property)
+	}
+
+	return value, nil
+}
+
+// GetAll returns the values of all properties.
+//
+// Parameters:
+// iface: Interface carrying the properties.
+//
+// Returns:
+// - Map of property names to their values.
+// - DBus error (nil if none)
+func (properties *operationProperties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	if iface != properties.interfaceName {
+		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface",
+			[]interface{}{fmt.Sprintf("Unknown interface '%s'", iface)})
+	}
+
+	return properties.op.properties(), nil
+}
+
+// Set always fails, as all operation properties are read-only.
+//
+// Parameters:
+// iface: Interface carrying the property.
+// property: Name of the property.
+// value: New value for the property.
+//
+// Returns:
+// - DBus error
+func (properties *operationProperties) Set(iface string, property string, value dbus.Variant) *dbus.Error {
+	_, dbusErr := properties.Get(iface, property)
+	if dbusErr != nil {
+		return dbusErr
+	}
+
+	return dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly",
+		[]interface{}{fmt.Sprintf("Property '%s' is read-only", property)})
+}
+
+// operationIntrospection returns the introspection data for an operation's
+// object path.
+//
+// Parameters:
+// interfaceName: DBus interface name carrying the methods, properties and
+// signals.
+//
+// Returns:
+// - Introspectable to be exported at the operation's object path
+func operationIntrospection(interfaceName string) introspect.Introspectable {
+	return introspect.Introspectable(fmt.Sprintf(operationIntrospectionXml,
+		interfaceName))
+}
+
+// unknownPropertyError creates the error returned for an unknown property.
+//
+// Parameters:
+// property: Name of the property.
+//
+// Returns:
+// - DBus error
+func unknownPropertyError(property string) *dbus.Error {
+	return dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty",
+		[]interface{}{fmt.Sprintf("Unknown property '%s'", property)})
+}
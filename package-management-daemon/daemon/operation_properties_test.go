@@ -0,0 +1,92 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"github.com/godbus/dbus"
+	"testing"
+)
+
+// Test typical Get usage.
+func TestOperationProperties_get(t *testing.T) {
+	properties := newOperationProperties(newOperation("1", operationKindInstall, "foo"), "iface")
+
+	value, dbusErr := properties.Get("iface", "PackageId")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting property: %s", dbusErr)
+	}
+
+	if value.Value() != "foo" {
+		t.Errorf(`Package ID was %#v, expected "foo"`, value.Value())
+	}
+
+	value, dbusErr = properties.Get("iface", "Status")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting property: %s", dbusErr)
+	}
+
+	if value.Value() != operationStatusQueued {
+		t.Errorf(`Status was %#v, expected "%s"`, value.Value(), operationStatusQueued)
+	}
+}
+
+// Test that Get fails for unknown interfaces and properties.
+func TestOperationProperties_getUnknown(t *testing.T) {
+	properties := newOperationProperties(newOperation("1", operationKindInstall, "foo"), "iface")
+
+	_, dbusErr := properties.Get("other", "PackageId")
+	if dbusErr == nil {
+		t.Error("Expected an error due to an unknown interface")
+	}
+
+	_, dbusErr = properties.Get("iface", "Bogus")
+	if dbusErr == nil {
+		t.Error("Expected an error due to an unknown property")
+	}
+}
+
+// Test typical GetAll usage.
+func TestOperationProperties_getAll(t *testing.T) {
+	properties := newOperationProperties(newOperation("1", operationKindInstall, "foo"), "iface")
+
+	all, dbusErr := properties.GetAll("iface")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting properties: %s", dbusErr)
+	}
+
+	for _, name := range []string{"PackageId", "PackageIds", "Kind", "Status", "Progress", "PackagesDone", "PackageProgress", "Error", "StartTime", "DownloadRate", "Eta"} {
+		if _, ok := all[name]; !ok {
+			t.Errorf(`Expected property "%s" to be present`, name)
+		}
+	}
+}
+
+// Test that all properties are read-only.
+func TestOperationProperties_set(t *testing.T) {
+	properties := newOperationProperties(newOperation("1", operationKindInstall, "foo"), "iface")
+
+	dbusErr := properties.Set("iface", "Status", dbus.MakeVariant("done"))
+	if dbusErr == nil {
+		t.Fatal("Expected an error due to the property being read-only")
+	}
+
+	if dbusErr.Name != "org.freedesktop.DBus.Error.PropertyReadOnly" {
+		t.Errorf(`Error was "%s", expected a read-only error`, dbusErr.Name)
+	}
+}
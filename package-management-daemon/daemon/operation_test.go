@@ -0,0 +1,222 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Test typical newOperation usage.
+func TestNewOperation(t *testing.T) {
+	op := newOperation("1", operationKindInstall, "foo")
+
+	if op.packageId != "foo" {
+		t.Errorf(`Package ID was "%s", expected "foo"`, op.packageId)
+	}
+
+	if op.id != "1" {
+		t.Errorf(`ID was "%s", expected "1"`, op.id)
+	}
+
+	if op.change() != "" {
+		t.Errorf(`Change ID was "%s", expected none while queued`, op.change())
+	}
+
+	if !op.isQueued() {
+		t.Error("Expected new operation to be queued")
+	}
+
+	if op.kind != operationKindInstall {
+		t.Errorf(`Kind was "%s", expected "%s"`, op.kind, operationKindInstall)
+	}
+
+	if op.startTime.IsZero() {
+		t.Error("Expected start time to be set")
+	}
+
+	if op.isCanceled() {
+		t.Error("Expected new operation not to be canceled")
+	}
+
+	if op.isFinished() {
+		t.Error("Expected new operation not to be finished")
+	}
+}
+
+// Test that starting an operation records its change.
+func TestOperation_start(t *testing.T) {
+	op := newOperation("1", operationKindInstall, "foo")
+	op.start("42")
+
+	if op.change() != "42" {
+		t.Errorf(`Change ID was "%s", expected "42"`, op.change())
+	}
+
+	if op.isQueued() {
+		t.Error("Expected started operation not to be queued")
+	}
+
+	if op.isFinished() {
+		t.Error("Expected started operation not to be finished")
+	}
+}
+
+// Test that canceling an operation marks it as canceled and wakes up whoever
+// is waiting on it.
+func TestOperation_cancel(t *testing.T) {
+	op := newOperation("1", operationKindInstall, "foo")
+
+	// Canceling twice must not block
+	op.cancel()
+	op.cancel()
+
+	if !op.isCanceled() {
+		t.Error("Expected operation to be canceled")
+	}
+
+	select {
+	case <-op.cancelRequested:
+	case <-time.After(time.Second):
+		t.Error("Expected cancellation request to wake up the waiter")
+	}
+}
+
+// Test that progress never moves backwards.
+func TestOperation_advanceProgress(t *testing.T) {
+	op := newOperation("1", operationKindInstall, "foo")
+
+	steps := []struct {
+		received, total                 uint64
+		expectedReceived, expectedTotal uint64
+	}{
+		{0, 0, 0, 0},
+		{10, 100, 10, 100},
+		{5, 100, 10, 100},
+		{50, 1000, 10, 100},
+		{200, 1000, 200, 1000},
+		{0, 0, 200, 1000},
+	}
+
+	for i, step := range steps {
+		received, total := op.advanceProgress(step.received, step.total)
+		if received != step.expectedReceived || total != step.expectedTotal {
+			t.Errorf("Step %d: Progress was %d/%d, expected %d/%d", i, received,
+				total, step.expectedReceived, step.expectedTotal)
+		}
+	}
+}
+
+// Test that the download rate is smoothed and used to estimate the time
+// remaining.
+func TestOperation_sampleDownload(t *testing.T) {
+	op := newOperation("1", operationKindInstall, "foo")
+	start := time.Now()
+
+	// A single sample isn't enough to know the rate
+	rate, eta := op.sampleDownload(0, 1000, start)
+	if rate != 0 || eta != -1 {
+		t.Errorf("Got rate %d and ETA %d, expected them to be unknown", rate, eta)
+	}
+
+	rate, eta = op.sampleDownload(100, 1000, start.Add(time.Second))
+	if rate != 100 || eta != 9 {
+		t.Errorf("Got rate %d and ETA %d, expected 100 and 9", rate, eta)
+	}
+
+	// Rate changes are smoothed
+	rate, eta = op.sampleDownload(300, 1000, start.Add(2*time.Second))
+	if rate != 130 || eta != 6 {
+		t.Errorf("Got rate %d and ETA %d, expected 130 and 6", rate, eta)
+	}
+
+	properties := op.properties()
+	if properties["DownloadRate"].Value() != uint64(130) {
+		t.Errorf("DownloadRate property was %v, expected 130", properties["DownloadRate"].Value())
+	}
+	if properties["Eta"].Value() != int64(6) {
+		t.Errorf("Eta property was %v, expected 6", properties["Eta"].Value())
+	}
+
+	// Once the download is complete, there's no rate anymore
+	rate, eta = op.sampleDownload(1000, 1000, start.Add(3*time.Second))
+	if rate != 0 || eta != -1 {
+		t.Errorf("Got rate %d and ETA %d, expected them to be unknown", rate, eta)
+	}
+}
+
+// Test that progress and final status are reflected in the properties.
+func TestOperation_properties(t *testing.T) {
+	op := newOperation("1", operationKindRemove, "foo")
+	op.advanceProgress(42, 100)
+	op.setPackageProgress(map[string]operationProgress{"foo": {42, 100}}, 0)
+	op.finish(operationStatusError, "failed")
+
+	if !op.isFinished() {
+		t.Error("Expected operation to be finished")
+	}
+
+	properties := op.properties()
+
+	expected := map[string]interface{}{
+		"PackageId":       "foo",
+		"PackageIds":      []string{"foo"},
+		"Kind":            operationKindRemove,
+		"Status":          operationStatusError,
+		"Progress":        operationProgress{42, 100},
+		"PackagesDone":    uint32(0),
+		"PackageProgress": map[string]operationProgress{"foo": {42, 100}},
+		"Error":           "failed",
+		"StartTime":       op.startTime.Unix(),
+		"DownloadRate":    uint64(0),
+		"Eta":             int64(-1),
+	}
+
+	if len(properties) != len(expected) {
+		t.Errorf("Got %d properties, expected %d", len(properties), len(expected))
+	}
+
+	for name, value := range expected {
+		if !reflect.DeepEqual(properties[name].Value(), value) {
+			t.Errorf(`Property "%s" was %#v, expected %#v`, name,
+				properties[name].Value(), value)
+		}
+	}
+}
+
+// Test that operations on several packages wait in each package's queue.
+func TestOperation_queueKeys(t *testing.T) {
+	if keys := newOperation("1", operationKindRefresh).queueKeys(); !reflect.DeepEqual(keys, []string{""}) {
+		t.Errorf(`Queue keys were %v, expected [""]`, keys)
+	}
+
+	op := newOperation("1", operationKindInstall, "foo", "bar")
+	if keys := op.queueKeys(); !reflect.DeepEqual(keys, []string{"foo", "bar"}) {
+		t.Errorf(`Queue keys were %v, expected [foo bar]`, keys)
+	}
+
+	if !op.isBatch() {
+		t.Error("Expected operation on several packages to be a batch")
+	}
+
+	if op.packageId != "" {
+		t.Errorf(`Package ID was "%s", expected none for a batch`, op.packageId)
+	}
+}
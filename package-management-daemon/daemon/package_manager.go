@@ -7,6 +7,14 @@ import (
 // PackageManager is an interface to be implemented by any struct that supports
 // the type of package management needed by this daemon.
 type PackageManager interface {
-	Install(packageId string) (dbus.ObjectPath, *dbus.Error)
-	Uninstall(packageId string) (dbus.ObjectPath, *dbus.Error)
+	Install(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error)
+	InstallMany(sender dbus.Sender, packageIds []string) (dbus.ObjectPath, *dbus.Error)
+	Uninstall(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error)
+	UninstallMany(sender dbus.Sender, packageIds []string) (dbus.ObjectPath, *dbus.Error)
+	Refresh(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error)
+	RefreshAll(sender dbus.Sender) (dbus.ObjectPath, *dbus.Error)
+	Cancel(sender dbus.Sender, packageId string) *dbus.Error
+	ListOperations() ([]dbus.ObjectPath, *dbus.Error)
+	GetOperationForPackage(packageId string) (dbus.ObjectPath, *dbus.Error)
+	GetHistory(limit uint32) ([]historyEntry, *dbus.Error)
 }
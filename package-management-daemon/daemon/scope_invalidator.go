@@ -0,0 +1,95 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultInvalidationDelay is how long to wait for other operations to finish
+// before invalidating the results of the scopes.
+const defaultInvalidationDelay = time.Second
+
+// scopeInvalidator invalidates the results of scopes once operations finish,
+// so they reflect the packages installed. Operations finishing close together
+// only result in a single invalidation.
+type scopeInvalidator struct {
+	dbusConnection DbusWrapper
+	delay          time.Duration
+
+	lock    sync.Mutex
+	scopes  []string
+	pending bool
+}
+
+// newScopeInvalidator creates a new scopeInvalidator.
+//
+// Parameters:
+// dbusConnection: Connection to the dbus bus.
+// scopes: IDs of the scopes to invalidate.
+// delay: How long to wait for other operations before invalidating.
+//
+// Returns:
+// - Pointer to new scopeInvalidator.
+func newScopeInvalidator(dbusConnection DbusWrapper, scopes []string, delay time.Duration) *scopeInvalidator {
+	return &scopeInvalidator{
+		dbusConnection: dbusConnection,
+		delay:          delay,
+		scopes:         scopes,
+	}
+}
+
+// setScopes changes the scopes to invalidate.
+//
+// Parameters:
+// scopes: IDs of the scopes to invalidate.
+func (invalidator *scopeInvalidator) setScopes(scopes []string) {
+	invalidator.lock.Lock()
+	defer invalidator.lock.Unlock()
+
+	invalidator.scopes = scopes
+}
+
+// invalidate schedules an invalidation of the scopes, unless one is already
+// pending.
+func (invalidator *scopeInvalidator) invalidate() {
+	invalidator.lock.Lock()
+	defer invalidator.lock.Unlock()
+
+	if invalidator.pending {
+		return
+	}
+
+	invalidator.pending = true
+	time.AfterFunc(invalidator.delay, invalidator.flush)
+}
+
+// flush invalidates the scopes right away.
+func (invalidator *scopeInvalidator) flush() {
+	invalidator.lock.Lock()
+	scopes := invalidator.scopes
+	invalidator.pending = false
+	invalidator.lock.Unlock()
+
+	for _, scope := range scopes {
+		invalidator.dbusConnection.Emit("/com/canonical/unity/scopes",
+			"com.canonical.unity.scopes.InvalidateResults", scope)
+	}
+}
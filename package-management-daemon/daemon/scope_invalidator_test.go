@@ -0,0 +1,96 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"github.com/godbus/dbus"
+	"testing"
+	"time"
+)
+
+// receiveSignal waits for a signal to be emitted.
+func receiveSignal(t *testing.T, server *FakeDbusServer) *dbus.Signal {
+	select {
+	case signal := <-server.signals:
+		return signal
+	case <-time.After(time.Second):
+		t.Fatal("Expected a signal to be emitted")
+	}
+
+	return nil
+}
+
+// Test that every configured scope is invalidated.
+func TestScopeInvalidator_invalidate(t *testing.T) {
+	server := new(FakeDbusServer)
+	server.InitializeSignals()
+
+	invalidator := newScopeInvalidator(server, []string{"foo", "bar"}, time.Millisecond)
+	invalidator.invalidate()
+
+	for _, expected := range []string{"foo", "bar"} {
+		signal := receiveSignal(t, server)
+		if signal.Name != "com.canonical.unity.scopes.InvalidateResults" {
+			t.Errorf(`Signal was "%s", expected "com.canonical.unity.scopes.InvalidateResults"`, signal.Name)
+		}
+
+		if len(signal.Body) != 1 || signal.Body[0] != expected {
+			t.Errorf(`Invalidated %v, expected "%s"`, signal.Body, expected)
+		}
+	}
+}
+
+// Test that operations finishing close together result in a single
+// invalidation.
+func TestScopeInvalidator_coalesce(t *testing.T) {
+	server := new(FakeDbusServer)
+	server.InitializeSignals()
+
+	invalidator := newScopeInvalidator(server, []string{"foo"}, 20*time.Millisecond)
+	invalidator.invalidate()
+	invalidator.invalidate()
+	invalidator.invalidate()
+
+	receiveSignal(t, server)
+
+	select {
+	case signal := <-server.signals:
+		t.Errorf("Unexpected additional invalidation: %v", signal.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Later operations result in another invalidation
+	invalidator.invalidate()
+	receiveSignal(t, server)
+}
+
+// Test that the scopes to invalidate can be changed.
+func TestScopeInvalidator_setScopes(t *testing.T) {
+	server := new(FakeDbusServer)
+	server.InitializeSignals()
+
+	invalidator := newScopeInvalidator(server, []string{"foo"}, time.Millisecond)
+	invalidator.setScopes([]string{"bar"})
+	invalidator.invalidate()
+
+	signal := receiveSignal(t, server)
+	if len(signal.Body) != 1 || signal.Body[0] != "bar" {
+		t.Errorf(`Invalidated %v, expected "bar"`, signal.Body)
+	}
+}
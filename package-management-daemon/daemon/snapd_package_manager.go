@@ -22,26 +22,108 @@ import (
 	"fmt"
 	"github.com/godbus/dbus"
 	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/policy"
+	"log"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// defaultOperationLifetime is how long a finished operation remains exported
+// over DBus, so clients can still query how it ended.
+const defaultOperationLifetime = 5 * time.Minute
+
+// progressScale is the total reported for the overall progress of a change,
+// of which downloads make up progressDownloadShare.
+const (
+	progressScale         = 1000
+	progressDownloadShare = 800
+)
+
+// nameOwnerChangedRule matches the signal the bus emits when a name changes
+// owner, such as a caller's unique name when it leaves the bus. It's narrowed
+// down to each caller being watched, see callerRule.
+const (
+	nameOwnerChangedSignal = "org.freedesktop.DBus.NameOwnerChanged"
+	nameOwnerChangedRule   = "type='signal',sender='org.freedesktop.DBus'," +
+		"interface='org.freedesktop.DBus',member='NameOwnerChanged'"
+)
+
+// snapdClient is the subset of the snapd client used by the package manager.
+type snapdClient interface {
+	Install(name string, options *client.SnapOptions) (string, error)
+	Remove(name string, options *client.SnapOptions) (string, error)
+	Refresh(name string, options *client.SnapOptions) (string, error)
+	RefreshMany(names []string, options *client.SnapOptions) (string, error)
+	InstallMany(names []string, options *client.SnapOptions) (string, error)
+	RemoveMany(names []string, options *client.SnapOptions) (string, error)
+	Abort(id string) (*client.Change, error)
+	Change(id string) (*client.Change, error)
+	Changes(options *client.ChangesOptions) ([]*client.Change, error)
+	FindOne(name string) (*client.Snap, *client.ResultInfo, error)
+	List(names []string) ([]*client.Snap, error)
+}
+
 // SnapdPackageManagerInterface implements a DBus interface for managing
 // packages in snapd.
 type SnapdPackageManagerInterface struct {
 	dbusConnection DbusWrapper
 	operationId    uint64
+	queueSeq       uint64 // Operations queued so far (guarded by queuesLock)
 
-	pollPeriod time.Duration
+	monitor           *changeMonitor
+	notifier          *notifier
+	authority         authority
+	policy            *policy.Policy
+	invalidator       *scopeInvalidator
+	history           *history
+	paths             *operationPaths
+	operationLifetime time.Duration
 
+	interfaceName  string
 	baseObjectPath dbus.ObjectPath
 
 	clientConfig client.Config
-	client *client.Client
+	client snapdClient
+
+	// Key: Operation ID
+	// Value: Operation
+	operations     map[string]*operation
+	operationsLock sync.Mutex
+
+	// Key: Package ID (empty for operations on all packages, which wait for
+	// every queue)
+	// Value: Unfinished operations on that package, in the order they were
+	// requested. Only the first one is normally running. Modifications are
+	// guarded by both queuesLock and operationsLock, so lookups only need the
+	// latter.
+	queues     map[string][]*operation
+	queuesLock sync.Mutex
+
+	// Whether queued operations are dropped once all the callers that requested
+	// them leave the bus (guarded by queuesLock)
+	abortOnDisconnect bool
+
+	// Callers whose leaving the bus is being watched (nil until watchCallers
+	// is called)
+	callers     map[dbus.Sender]bool
+	callersLock sync.Mutex
+
+	// Time of the last call or finished operation (guarded by operationsLock)
+	lastActivity time.Time
 
 	processingSignalName string
 	progressSignalName string
+	downloadRateSignalName string
 	finishedSignalName string
 	errorSignalName    string
+	canceledSignalName string
+
+	noOperationErrorName  string
+	policyDeniedErrorName string
 }
 
 // SnapdPackageManagerInterface creates a new SnapdPackageManagerInterface.
@@ -63,187 +145,1728 @@ func NewSnapdPackageManagerInterface(dbusConnection DbusWrapper,
 		return nil, fmt.Errorf(`Invalid base object path: "%s"`, baseObjectPath)
 	}
 
-	manager.pollPeriod = time.Second
+	manager.operationLifetime = defaultOperationLifetime
 
+	manager.interfaceName = interfaceName
 	manager.baseObjectPath = baseObjectPath
 
 	manager.client = client.New(&manager.clientConfig)
+	manager.monitor = newChangeMonitor(manager.client, time.Second)
+	manager.notifier = newNotifier(dbusConnection)
+	manager.authority = newPolkitAuthority(dbusConnection)
+	manager.invalidator = newScopeInvalidator(dbusConnection,
+		defaultInvalidateScopes, defaultInvalidationDelay)
+
+	manager.operations = make(map[string]*operation)
+	manager.queues = make(map[string][]*operation)
+	manager.lastActivity = time.Now()
 
 	manager.processingSignalName = interfaceName + ".processing"
 	manager.progressSignalName = interfaceName + ".progress"
+	manager.downloadRateSignalName = interfaceName + ".downloadRate"
 	manager.finishedSignalName = interfaceName + ".finished"
 	manager.errorSignalName = interfaceName + ".error"
+	manager.canceledSignalName = interfaceName + ".canceled"
+
+	manager.noOperationErrorName = interfaceName + ".Error.NoOperation"
+	manager.policyDeniedErrorName = interfaceName + ".Error.PolicyDenied"
 
 	return manager, nil
 }
 
-// Install requests that snapd begin installation of a specific package, and
-// then begins a polling job to provide progress feedback via the dbus
-// connection.
+// Install queues the installation of a specific package by snapd, and then
+// begins a polling job to provide progress feedback via the dbus connection
+// once it's started.
 //
 // Parameters:
+// sender: Unique bus name of the caller.
 // packageId: ID of the package to be installed by snapd.
 //
 // Returns:
 // - Object path over which the progress feedback will be provided.
 // - DBus error (nil if none)
-func (manager *SnapdPackageManagerInterface) Install(packageId string) (dbus.ObjectPath, *dbus.Error) {
-	opts := &client.SnapOptions{}
+func (manager *SnapdPackageManagerInterface) Install(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
 
-	var err error
-	var changeID string
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
 
-	changeID, err = manager.client.Install(packageId, opts)
-	if err != nil {
-		return "", dbus.NewError("org.freedesktop.DBus.Error.Failed",
-			[]interface{}{fmt.Sprintf("Error installing package '%s': %s",
-				packageId, err)})
+	if dbusErr := manager.checkPolicy(sender, packageId); dbusErr != nil {
+		return "", dbusErr
 	}
 
-	go manager.wait(changeID)
-	return manager.getObjectPath(changeID), nil
+	return manager.queueOperation(sender, operationKindInstall, packageId)
+}
+
+// InstallMany queues the installation of several packages by snapd as a single
+// change, and then begins a polling job to provide aggregate progress feedback
+// via the dbus connection once it's started.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// packageIds: IDs of the packages to be installed by snapd.
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) InstallMany(sender dbus.Sender, packageIds []string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	if len(packageIds) == 0 {
+		return "", dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs",
+			[]interface{}{"No packages to install"})
+	}
+
+	if dbusErr := manager.checkPolicy(sender, packageIds...); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	return manager.queueOperation(sender, operationKindInstall, packageIds...)
 }
 
-// Uninstall requests that Snapd begin uninstallation of a specific package, and
-// then begins a polling job to provide progress feedback via the dbus
-// connection.
+// Uninstall queues the uninstallation of a specific package by snapd, and then
+// begins a polling job to provide progress feedback via the dbus connection
+// once it's started.
 //
 // Parameters:
+// sender: Unique bus name of the caller.
 // packageId: ID of the package to be uninstalled by snapd.
 //
 // Returns:
 // - Object path over which the progress feedback will be provided.
 // - DBus error (nil if none)
-func (manager *SnapdPackageManagerInterface) Uninstall(packageId string) (dbus.ObjectPath, *dbus.Error) {
-	opts := &client.SnapOptions{}
+func (manager *SnapdPackageManagerInterface) Uninstall(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
 
-	var err error
-	var changeID string
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	return manager.queueOperation(sender, operationKindRemove, packageId)
+}
+
+// UninstallMany queues the uninstallation of several packages by snapd as a
+// single change, and then begins a polling job to provide aggregate progress
+// feedback via the dbus connection once it's started.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// packageIds: IDs of the packages to be uninstalled by snapd.
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) UninstallMany(sender dbus.Sender, packageIds []string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	if len(packageIds) == 0 {
+		return "", dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs",
+			[]interface{}{"No packages to uninstall"})
+	}
+
+	return manager.queueOperation(sender, operationKindRemove, packageIds...)
+}
+
+// Refresh queues the update of a specific package to its latest revision by
+// snapd, and then begins a polling job to provide progress feedback via the
+// dbus connection once it's started.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// packageId: ID of the package to be refreshed by snapd.
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) Refresh(sender dbus.Sender, packageId string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	return manager.queueOperation(sender, operationKindRefresh, packageId)
+}
+
+// RefreshAll queues the update of every installed package that has a newer
+// revision available, and then begins a polling job to provide progress
+// feedback via the dbus connection once it's started.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) RefreshAll(sender dbus.Sender) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	return manager.queueOperation(sender, operationKindRefresh)
+}
+
+// Cancel cancels the operation snapd is currently carrying out on a specific
+// package, by asking it to abort the change. Queued operations, as well as
+// those operating on several packages, are left alone: they're canceled via
+// their own object path. The operation's object path will receive the
+// `canceled` signal once it's done. Callers may only cancel the operations they
+// requested, unless they're allowed to manage those of others.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// packageId: ID of the package whose operation should be canceled.
+//
+// Returns:
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) Cancel(sender dbus.Sender, packageId string) *dbus.Error {
+	manager.touch()
+
+	if dbusErr := manager.authorize(sender); dbusErr != nil {
+		return dbusErr
+	}
+
+	op := manager.operationInFlight(packageId)
+	if op == nil {
+		return dbus.NewError(manager.noOperationErrorName,
+			[]interface{}{fmt.Sprintf("No operation in progress for package '%s'",
+				packageId)})
+	}
+
+	return manager.cancelOperation(sender, op)
+}
+
+// cancelOperation cancels a single operation. A queued operation is dropped
+// right away, while snapd is asked to abort the change of one in progress. The
+// caller must already be authorized to manage packages.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// op: Operation to cancel.
+//
+// Returns:
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) cancelOperation(sender dbus.Sender, op *operation) *dbus.Error {
+	challenge, dbusErr := manager.authorizeCancel(sender, op)
+	if challenge {
+		// Authenticating may take the user longer than the caller waits for a
+		// reply, so the operation is canceled once they're done
+		go manager.cancelAuthenticated(sender, op)
+		return nil
+	}
+
+	if dbusErr != nil {
+		return dbusErr
+	}
+
+	return manager.abortOperation(op)
+}
+
+// cancelAuthenticated asks the user to authenticate before canceling an
+// operation requested by others, logging failures since the caller has
+// already been replied to.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// op: Operation to cancel.
+func (manager *SnapdPackageManagerInterface) cancelAuthenticated(sender dbus.Sender, op *operation) {
+	_, dbusErr := manager.checkAuthorization(sender, manageOthersAction, true,
+		fmt.Sprintf(`Caller "%s" is not authorized to cancel operations requested by others`, sender))
+	if dbusErr == nil {
+		dbusErr = manager.abortOperation(op)
+	}
+
+	if dbusErr != nil {
+		log.Printf(`package-management-daemon: Unable to cancel operation %s for "%s": %s`, op.id, sender, dbusErr)
+	}
+}
+
+// abortOperation cancels a single operation once the caller is authorized.
+//
+// Parameters:
+// op: Operation to cancel.
+//
+// Returns:
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) abortOperation(op *operation) *dbus.Error {
+	// Authorizing takes a while, so the operation may have been started or
+	// finished in the meantime
+	manager.queuesLock.Lock()
+	if op.isQueued() {
+		manager.dropOperation(op)
+
+		// Dropping batches may unblock other packages' queues
+		next := manager.startNext(op.queueKeys()...)
+		manager.queuesLock.Unlock()
+
+		manager.launch(next)
+		return nil
+	}
+	manager.queuesLock.Unlock()
+
+	// Its change gets aborted once snapd has started it
+	if op.cancelStarting() {
+		return nil
+	}
+
+	if op.isFinished() {
+		return dbus.NewError("org.freedesktop.DBus.Error.Failed",
+			[]interface{}{fmt.Sprintf("Operation %s is already finished", op.id)})
+	}
+
+	if op.isCanceled() {
+		return nil
+	}
 
-	changeID, err = manager.client.Remove(packageId, opts)
+	_, err := manager.client.Abort(op.change())
 	if err != nil {
-		return "", dbus.NewError("org.freedesktop.DBus.Error.Failed",
-			[]interface{}{fmt.Sprintf("Error installing package '%s': %s",
-				packageId, err)})
+		return dbus.NewError("org.freedesktop.DBus.Error.Failed",
+			[]interface{}{fmt.Sprintf("Error canceling operation %s: %s", op.id, err)})
 	}
 
-	go manager.wait(changeID)
-	return manager.getObjectPath(changeID), nil
+	op.cancel()
+
+	return nil
 }
 
+// ListOperations lists the operations currently queued or in progress.
+//
+// Returns:
+// - Object paths of the operations.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) ListOperations() ([]dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
 
-// operationObjectPath is used to generate an object path for a given operation.
+	ids := make([]string, 0, len(manager.operations))
+	for id, op := range manager.operations {
+		if !op.isFinished() {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	objectPaths := make([]dbus.ObjectPath, len(ids))
+	for i, id := range ids {
+		objectPaths[i] = manager.getObjectPath(id)
+	}
+
+	return objectPaths, nil
+}
+
+// GetOperationForPackage finds the operation currently in progress (or next in
+// line) on a specific package, allowing clients to follow it without having
+// started it.
 //
 // Parameters:
-// operationId: ID of the operation to be represented by this object path.
+// packageId: ID of the package being operated upon.
 //
 // Returns:
-// - New object path.
-func (manager *SnapdPackageManagerInterface) getObjectPath(changeID string) dbus.ObjectPath {
-	return dbus.ObjectPath(fmt.Sprintf("%s/%s",
-		manager.baseObjectPath, changeID))
+// - Object path of the operation.
+// - DBus error (NoOperation if no operation is in progress)
+func (manager *SnapdPackageManagerInterface) GetOperationForPackage(packageId string) (dbus.ObjectPath, *dbus.Error) {
+	manager.touch()
+
+	op := manager.operationForPackage(packageId)
+	if op == nil {
+		return "", dbus.NewError(manager.noOperationErrorName,
+			[]interface{}{fmt.Sprintf("No operation in progress for package '%s'",
+				packageId)})
+	}
+
+	return manager.getObjectPath(op.id), nil
 }
 
-// emitProgress emits the `progres` DBus signal.
+// GetHistory lists the operations that finished, whether successfully or not,
+// including those carried out by previous instances of the daemon.
 //
 // Parameters:
-// packageId: ID of the package whose progress is being emitted.
-// received: Received count.
-// total: Total count.
-func (manager *SnapdPackageManagerInterface) emitProgress(changeID string, received uint64, total uint64) {
-	manager.dbusConnection.Emit(manager.getObjectPath(changeID),
-		manager.progressSignalName, received, total)
+// limit: Maximum number of entries to list (zero for all).
+//
+// Returns:
+// - History entries, newest first.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) GetHistory(limit uint32) ([]historyEntry, *dbus.Error) {
+	manager.touch()
+
+	return manager.history.recent(int(limit)), nil
 }
 
-// emitProcessing emits the `processing` DBus signal.
+// queueOperation requests an operation on packages. Operations on the same
+// package are carried out one after the other, in the order they were
+// requested, so snapd doesn't reject them as conflicting. Requesting the same
+// operation as the last one queued for the packages simply returns it, making
+// the caller one more of its owners.
 //
 // Parameters:
-// packageId: ID of the package that is processing.
-func (manager *SnapdPackageManagerInterface) emitProcessing(changeID string) {
-	manager.dbusConnection.Emit(manager.getObjectPath(changeID),
-		manager.processingSignalName, "")
+// sender: Unique bus name of the caller.
+// kind: Kind of operation ("install", "remove", "refresh").
+// packageIds: IDs of the packages to operate upon (none for all packages).
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) queueOperation(sender dbus.Sender, kind string, packageIds ...string) (dbus.ObjectPath, *dbus.Error) {
+	objectPath, dbusErr := manager.enqueue(sender, kind, packageIds...)
+	if dbusErr == nil {
+		manager.watchCaller(sender)
+	}
+
+	return objectPath, dbusErr
 }
 
-// emitFinished emits the `finished` DBus signal.
+// enqueue carries out queueOperation. The queues lock isn't held while snapd
+// is asked to start the operation: it holds its place in the queues instead.
 //
 // Parameters:
-// packageId: ID of the package that just finished an operation.
-func (manager *SnapdPackageManagerInterface) emitFinished(changeID string) {
-	manager.dbusConnection.Emit(manager.getObjectPath(changeID),
-		manager.finishedSignalName, "")
+// sender: Unique bus name of the caller.
+// kind: Kind of operation ("install", "remove", "refresh").
+// packageIds: IDs of the packages to operate upon (none for all packages).
+//
+// Returns:
+// - Object path over which the progress feedback will be provided.
+// - DBus error (nil if none)
+func (manager *SnapdPackageManagerInterface) enqueue(sender dbus.Sender, kind string, packageIds ...string) (dbus.ObjectPath, *dbus.Error) {
+	op := newOperation("", kind, packageIds...)
+	op.addOwner(sender)
+
+	manager.queuesLock.Lock()
+
+	// Operations still being started have no object path yet
+	if last := manager.lastQueued(op.queueKeys()); last != nil && last.id != "" &&
+		last.kind == kind && reflect.DeepEqual(last.packageIds, op.packageIds) {
+		last.addOwner(sender)
+		manager.queuesLock.Unlock()
+		return manager.getObjectPath(last.id), nil
+	}
+
+	if !manager.queuesEmpty(op.queueKeys()) {
+		op.id = manager.newOperationId("queued")
+		manager.addToQueues(op)
+		manager.trackOperation(op)
+		manager.queuesLock.Unlock()
+		return manager.getObjectPath(op.id), nil
+	}
+
+	// Nothing else is happening to these packages: start right away, so
+	// snapd refusing the request can be reported to the caller.
+	op.begin()
+	manager.addToQueues(op)
+	manager.queuesLock.Unlock()
+
+	changeID, err := manager.submit(op)
+	if err != nil {
+		manager.queuesLock.Lock()
+		op.id = manager.newOperationId("rejected")
+		manager.queuesLock.Unlock()
+
+		// Others may have been queued behind it in the meantime
+		manager.launch(manager.reject(op, err))
 
-	// Refresh the apps scope and the snappy store scope
-	manager.dbusConnection.Emit("/com/canonical/unity/scopes",
-		"com.canonical.unity.scopes.InvalidateResults",
-		"clickscope")
-	manager.dbusConnection.Emit("/com/canonical/unity/scopes",
-		"com.canonical.unity.scopes.InvalidateResults",
-		"snappy-store")
+		return "", dbus.NewError("org.freedesktop.DBus.Error.Failed",
+			[]interface{}{err.Error()})
+	}
+
+	canceled := op.start(changeID)
+
+	manager.queuesLock.Lock()
+	op.id = changeID
+	manager.trackOperation(op)
+	manager.queuesLock.Unlock()
+
+	manager.follow(op, canceled)
+
+	return manager.getObjectPath(changeID), nil
 }
 
-// emitError emits the `error` DBus signal.
+// newOperationId generates the ID of an operation that isn't named after its
+// snapd change, i.e. one that wasn't started right away. Must be called with
+// the queues lock held.
 //
 // Parameters:
-// packageId: ID of the package that encountered an error.
-// format: Format string of the error.
-// a...: List of values for the placeholders in the `format` string.
-func (manager *SnapdPackageManagerInterface) emitError(changeID string, format string, a ...interface{}) {
-	manager.dbusConnection.Emit(manager.getObjectPath(changeID),
-		manager.errorSignalName, fmt.Sprintf(format, a...))
+// prefix: What the operation is waiting for, or why it never started.
+//
+// Returns:
+// - New operation ID.
+func (manager *SnapdPackageManagerInterface) newOperationId(prefix string) string {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	manager.operationId++
+	return fmt.Sprintf("%s%d", prefix, manager.operationId)
 }
 
-func (manager *SnapdPackageManagerInterface) wait(changeID string) {
-	tMax := time.Time{}
+// lastQueued finds the operation last requested on a set of queues, if it's
+// the last one on all of them. Must be called with the queues lock held.
+//
+// Parameters:
+// keys: Keys of the queues.
+//
+// Returns:
+// - The operation (nil if none).
+func (manager *SnapdPackageManagerInterface) lastQueued(keys []string) *operation {
+	var last *operation
+	for i, key := range keys {
+		queue := manager.queues[key]
+		if len(queue) == 0 {
+			return nil
+		}
 
-	var lastID string
-	for {
-		chg, err := manager.client.Change(changeID)
-		if err != nil {
-			// an error here means the server most likely went away
-			// XXX: it actually can be a bunch of other things; fix client to expose it better
-			now := time.Now()
-			if tMax.IsZero() {
-				tMax = now.Add(manager.pollPeriod * 5)
-			}
-			if now.After(tMax) {
-				manager.emitError(changeID, "Error talking to snapd: %s", err)
-				return
-			}
-			manager.emitProcessing(changeID)
-			time.Sleep(manager.pollPeriod)
-			continue
+		if i == 0 {
+			last = queue[len(queue)-1]
+		} else if queue[len(queue)-1] != last {
+			return nil
 		}
-		if !tMax.IsZero() {
-			tMax = time.Time{}
+	}
+
+	return last
+}
+
+// queuesEmpty checks whether nothing is queued that would conflict with an
+// operation on a set of queues. Operations on all packages conflict with every
+// operation, and the other way around. Must be called with the queues lock
+// held.
+//
+// Parameters:
+// keys: Keys of the queues.
+//
+// Returns:
+// - Whether or not all the conflicting queues are empty.
+func (manager *SnapdPackageManagerInterface) queuesEmpty(keys []string) bool {
+	for _, key := range conflictingKeys(manager.queues, keys) {
+		if len(manager.queues[key]) > 0 {
+			return false
 		}
+	}
 
-		for _, t := range chg.Tasks {
-			switch {
-			case t.Status != "Doing":
-				continue
-			case t.Progress.Total == 1:
-				manager.emitProcessing(changeID)
-			case t.ID == lastID:
-				manager.emitProgress(changeID, uint64(t.Progress.Done), uint64(t.Progress.Total))
-			default:
-				lastID = t.ID
-			}
-			break
+	return true
+}
+
+// conflictingKeys lists the queues an operation on a set of queues conflicts
+// with: all of them for operations on all packages, and otherwise its own plus
+// the one for operations on all packages.
+//
+// Parameters:
+// queues: Queues of the package manager.
+// keys: Keys of the operation's queues.
+//
+// Returns:
+// - Keys of the conflicting queues.
+func conflictingKeys(queues map[string][]*operation, keys []string) []string {
+	for _, key := range keys {
+		if key != "" {
+			continue
 		}
 
-		if chg.Ready {
-			if chg.Status == "Done" {
-				manager.emitFinished(changeID)
-			} else if chg.Err != "" {
-				manager.emitError(changeID, chg.Err)
+		all := make([]string, 0, len(queues)+1)
+		all = append(all, "")
+		for queueKey := range queues {
+			if queueKey != "" {
+				all = append(all, queueKey)
 			}
-
-			return
 		}
 
-		// note this very purposely is not a ticker; we want
-		// to sleep 100ms between calls, not call once every
-		// 100ms.
-		time.Sleep(manager.pollPeriod)
+		return all
 	}
+
+	return append(append([]string{}, keys...), "")
+}
+
+// submit requests that snapd begin an operation.
+//
+// Parameters:
+// op: Operation to begin.
+//
+// Returns:
+// - ID of the snapd change carrying out the operation.
+// - Error (nil if none)
+func (manager *SnapdPackageManagerInterface) submit(op *operation) (string, error) {
+	op.setRevisionsBefore(manager.revisions(op.packageIds))
+
+	opts := &client.SnapOptions{}
+
+	var err error
+	var changeID string
+
+	switch {
+	case op.kind == operationKindInstall && op.isBatch():
+		changeID, err = manager.client.InstallMany(op.packageIds, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error installing packages %s: %s",
+				quotedList(op.packageIds), err)
+		}
+	case op.kind == operationKindInstall:
+		changeID, err = manager.client.Install(op.packageId, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error installing package '%s': %s",
+				op.packageId, err)
+		}
+	case op.kind == operationKindRemove && op.isBatch():
+		changeID, err = manager.client.RemoveMany(op.packageIds, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error uninstalling packages %s: %s",
+				quotedList(op.packageIds), err)
+		}
+	case op.kind == operationKindRemove:
+		changeID, err = manager.client.Remove(op.packageId, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error uninstalling package '%s': %s",
+				op.packageId, err)
+		}
+	case op.kind == operationKindRefresh && op.packageId == "":
+		changeID, err = manager.client.RefreshMany(op.packageIds, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error refreshing packages: %s", err)
+		}
+	case op.kind == operationKindRefresh:
+		changeID, err = manager.client.Refresh(op.packageId, opts)
+		if err != nil {
+			return "", fmt.Errorf("Error refreshing package '%s': %s",
+				op.packageId, err)
+		}
+	default:
+		return "", fmt.Errorf("Unknown operation kind '%s'", op.kind)
+	}
+
+	return changeID, nil
+}
+
+// addToQueues adds an operation at the end of its packages' queues. Must be
+// called with the queues lock held.
+//
+// Parameters:
+// op: Operation to add.
+func (manager *SnapdPackageManagerInterface) addToQueues(op *operation) {
+	manager.queueSeq++
+	op.seq = manager.queueSeq
+
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	for _, key := range op.queueKeys() {
+		manager.queues[key] = append(manager.queues[key], op)
+	}
+}
+
+// trackOperation begins tracking an operation already in its packages'
+// queues, and exports it over DBus at its object path. Must be called with the
+// queues lock held.
+//
+// Parameters:
+// op: Operation to track.
+//
+// Returns:
+// - The tracked operation.
+func (manager *SnapdPackageManagerInterface) trackOperation(op *operation) *operation {
+	manager.operationsLock.Lock()
+	manager.operations[op.id] = op
+	manager.operationsLock.Unlock()
+
+	manager.exportOperation(op)
+
+	return op
+}
+
+// dequeueOperation removes an operation from its packages' queues. Must be
+// called with the queues lock held.
+//
+// Parameters:
+// op: Operation to remove.
+func (manager *SnapdPackageManagerInterface) dequeueOperation(op *operation) {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	for _, key := range op.queueKeys() {
+		queue := manager.queues[key]
+		for i, queued := range queue {
+			if queued == op {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+
+		if len(queue) == 0 {
+			delete(manager.queues, key)
+		} else {
+			manager.queues[key] = queue
+		}
+	}
+}
+
+// dropOperation cancels an operation that's still queued, removing it from its
+// packages' queues without it ever being started. Must be called with the
+// queues lock held.
+//
+// Parameters:
+// op: Queued operation to drop.
+func (manager *SnapdPackageManagerInterface) dropOperation(op *operation) {
+	op.cancel()
+	manager.settleOperation(op, operationStatusCanceled, "")
+	manager.emitCanceled(op)
+	manager.dequeueOperation(op)
+	manager.retireOperation(op)
+}
+
+// startNext picks the operations at the head of the given queues to be started
+// next, unless they're already started, or still waiting in other queues.
+// They're no longer considered queued from then on. Must be called with the
+// queues lock held.
+//
+// Parameters:
+// keys: Keys of the queues to service.
+//
+// Returns:
+// - Operations to be started via launch once the queues lock is released.
+func (manager *SnapdPackageManagerInterface) startNext(keys ...string) []*operation {
+	var next []*operation
+	for _, key := range conflictingKeys(manager.queues, keys) {
+		queue := manager.queues[key]
+		if len(queue) == 0 {
+			continue
+		}
+
+		op := queue[0]
+		if !op.isQueued() || !manager.atHead(op) {
+			continue
+		}
+
+		op.begin()
+		next = append(next, op)
+	}
+
+	return next
+}
+
+// launch asks snapd to start the operations picked by startNext. Operations
+// snapd refuses are failed, moving on to the following ones. It talks to snapd
+// and records the history, so it mustn't be called with the queues lock held.
+//
+// Parameters:
+// ops: Operations returned by startNext.
+func (manager *SnapdPackageManagerInterface) launch(ops []*operation) {
+	for len(ops) > 0 {
+		op := ops[0]
+		ops = ops[1:]
+
+		changeID, err := manager.submit(op)
+		if err != nil {
+			ops = append(ops, manager.reject(op, err)...)
+			manager.retireOperation(op)
+			manager.emitError(op, "%s", op.failure())
+			continue
+		}
+
+		canceled := op.start(changeID)
+
+		// Clients know it by its queued object path, which must survive
+		// restarts
+		err = manager.paths.remember(changeID, op.id)
+		if err != nil {
+			log.Printf("package-management-daemon: Unable to remember operation %s: %s", op.id, err)
+		}
+
+		manager.follow(op, canceled)
+	}
+}
+
+// reject fails an operation snapd refused to start, removing it from its
+// packages' queues. It records the history, so it mustn't be called with the
+// queues lock held.
+//
+// Parameters:
+// op: Operation snapd refused.
+// err: Why snapd refused it.
+//
+// Returns:
+// - Operations following it, to be started via launch.
+func (manager *SnapdPackageManagerInterface) reject(op *operation, err error) []*operation {
+	manager.settleOperation(op, operationStatusError, err.Error())
+
+	manager.queuesLock.Lock()
+	defer manager.queuesLock.Unlock()
+
+	manager.dequeueOperation(op)
+
+	return manager.startNext(op.queueKeys()...)
+}
+
+// follow begins following an operation snapd started, first aborting its
+// change if the operation was canceled while it was being started.
+//
+// Parameters:
+// op: Started operation.
+// canceled: Whether or not it was canceled in the meantime.
+func (manager *SnapdPackageManagerInterface) follow(op *operation, canceled bool) {
+	if canceled {
+		_, err := manager.client.Abort(op.change())
+		if err != nil {
+			log.Printf("package-management-daemon: Unable to cancel operation %s: %s", op.id, err)
+		}
+	}
+
+	go manager.wait(op)
+}
+
+// atHead checks whether an operation is next in line in all of its queues, and
+// nothing queued before it conflicts with it in other queues. Must be called
+// with the queues lock held.
+//
+// Parameters:
+// op: Operation to check.
+//
+// Returns:
+// - Whether or not the operation is at the head of all its queues.
+func (manager *SnapdPackageManagerInterface) atHead(op *operation) bool {
+	for _, key := range op.queueKeys() {
+		queue := manager.queues[key]
+		if len(queue) == 0 || queue[0] != op {
+			return false
+		}
+	}
+
+	for _, key := range conflictingKeys(manager.queues, op.queueKeys()) {
+		queue := manager.queues[key]
+		if len(queue) > 0 && queue[0].seq < op.seq {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resumeOperations begins following the changes snapd has in progress that
+// aren't being followed yet, e.g. because the daemon was restarted while they
+// were running. They're exported under the object paths they had before,
+// including those of operations that waited in a queue, so clients that were
+// following them recover transparently.
+//
+// Returns:
+// - Error (nil if none)
+func (manager *SnapdPackageManagerInterface) resumeOperations() error {
+	changes, err := manager.client.Changes(&client.ChangesOptions{
+		Selector: client.ChangesInProgress,
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to list changes in progress: %s", err)
+	}
+
+	manager.queuesLock.Lock()
+	defer manager.queuesLock.Unlock()
+
+	inProgress := make(map[string]bool)
+	for _, change := range changes {
+		inProgress[change.ID] = true
+
+		kind, packageIds, ok := changeOperation(change)
+		if !ok || manager.operationForChange(change.ID) != nil {
+			continue
+		}
+
+		id := change.ID
+		if queuedId, ok := manager.paths.lookup(change.ID); ok {
+			id = queuedId
+
+			// Operations queued from now on mustn't reuse its ID
+			var number uint64
+			_, err := fmt.Sscanf(id, "queued%d", &number)
+			manager.operationsLock.Lock()
+			if err == nil && number > manager.operationId {
+				manager.operationId = number
+			}
+			manager.operationsLock.Unlock()
+		}
+
+		op := newOperation(id, kind, packageIds...)
+		if !change.SpawnTime.IsZero() {
+			op.startTime = change.SpawnTime
+		}
+		op.start(change.ID)
+
+		manager.addToQueues(op)
+		go manager.wait(manager.trackOperation(op))
+	}
+
+	// Changes that finished while the daemon wasn't running are of no
+	// interest anymore
+	var finished []string
+	for _, changeID := range manager.paths.changes() {
+		if !inProgress[changeID] {
+			finished = append(finished, changeID)
+		}
+	}
+
+	err = manager.paths.forget(finished...)
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to forget finished operations: %s", err)
+	}
+
+	return nil
+}
+
+// watchCallers begins watching for callers leaving the bus, so the operations
+// they requested can be dropped if nobody else is interested in them anymore.
+// Only the callers that requested operations are watched, see watchCaller.
+//
+// Returns:
+// - Error (nil if none)
+func (manager *SnapdPackageManagerInterface) watchCallers() error {
+	signals := make(chan *dbus.Signal, 16)
+
+	err := manager.dbusConnection.Subscribe(signals)
+	if err != nil {
+		return fmt.Errorf("Unable to watch for callers leaving the bus: %s", err)
+	}
+
+	manager.callersLock.Lock()
+	if manager.callers == nil {
+		manager.callers = make(map[dbus.Sender]bool)
+	}
+	manager.callersLock.Unlock()
+
+	go func() {
+		for signal := range signals {
+			if signal.Name != nameOwnerChangedSignal || len(signal.Body) != 3 {
+				continue
+			}
+
+			// Unique names losing their owner are connections leaving the
+			// bus. Signals are delivered by the goroutine reading from the
+			// bus, which must never wait for the queues, or it would no
+			// longer read the replies of the calls made with them locked.
+			name, _ := signal.Body[0].(string)
+			newOwner, _ := signal.Body[2].(string)
+			if strings.HasPrefix(name, ":") && newOwner == "" {
+				go manager.callerVanished(dbus.Sender(name))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchCaller asks the bus to tell when a caller leaves it, unless it's
+// already watched or callers aren't being watched at all. It talks to the
+// bus, so it mustn't be called with the queues lock held.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+func (manager *SnapdPackageManagerInterface) watchCaller(sender dbus.Sender) {
+	if sender == "" {
+		return
+	}
+
+	manager.callersLock.Lock()
+	watched := manager.callers == nil || manager.callers[sender]
+	if !watched {
+		manager.callers[sender] = true
+	}
+	manager.callersLock.Unlock()
+
+	if watched {
+		return
+	}
+
+	err := manager.dbusConnection.AddMatch(callerRule(sender))
+	if err != nil {
+		log.Printf(`package-management-daemon: Unable to watch "%s" leaving the bus: %s`, sender, err)
+
+		manager.callersLock.Lock()
+		delete(manager.callers, sender)
+		manager.callersLock.Unlock()
+		return
+	}
+
+	// It may have left before the bus was asked to tell
+	_, err = manager.dbusConnection.GetNameOwner(string(sender))
+	if dbusErr, ok := err.(dbus.Error); ok && dbusErr.Name == "org.freedesktop.DBus.Error.NameHasNoOwner" {
+		manager.callerVanished(sender)
+	}
+}
+
+// unwatchCaller tells the bus a caller no longer needs to be watched. It talks
+// to the bus, so it mustn't be called with the queues lock held.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+func (manager *SnapdPackageManagerInterface) unwatchCaller(sender dbus.Sender) {
+	manager.callersLock.Lock()
+	watched := manager.callers[sender]
+	delete(manager.callers, sender)
+	manager.callersLock.Unlock()
+
+	if !watched {
+		return
+	}
+
+	err := manager.dbusConnection.RemoveMatch(callerRule(sender))
+	if err != nil {
+		log.Printf(`package-management-daemon: Unable to stop watching "%s": %s`, sender, err)
+	}
+}
+
+// callerRule creates the match rule selecting the signal the bus emits when a
+// specific caller leaves it.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - Match rule.
+func callerRule(sender dbus.Sender) string {
+	return fmt.Sprintf("%s,arg0='%s'", nameOwnerChangedRule, sender)
+}
+
+// callerVanished forgets about a caller that left the bus. Queued operations
+// that no other caller requested are dropped, unless configured otherwise;
+// operations already started are left to complete.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+func (manager *SnapdPackageManagerInterface) callerVanished(sender dbus.Sender) {
+	manager.unwatchCaller(sender)
+
+	manager.queuesLock.Lock()
+
+	// Batches sit in several queues, but must only be considered once
+	seen := make(map[*operation]bool)
+	var orphans []*operation
+	for _, queue := range manager.queues {
+		for _, op := range queue {
+			if seen[op] {
+				continue
+			}
+			seen[op] = true
+
+			if op.removeOwner(sender) && op.isQueued() {
+				orphans = append(orphans, op)
+			}
+		}
+	}
+
+	if !manager.abortOnDisconnect {
+		manager.queuesLock.Unlock()
+		return
+	}
+
+	var affected []string
+	for _, op := range orphans {
+		log.Printf(`package-management-daemon: Dropping operation %s: "%s" left the bus`,
+			op.id, sender)
+		manager.dropOperation(op)
+		affected = append(affected, op.queueKeys()...)
+	}
+
+	next := manager.startNext(affected...)
+	manager.queuesLock.Unlock()
+
+	manager.launch(next)
+}
+
+// finishOperation removes a finished operation from its packages' queues, and
+// starts the next ones. The operation remains exported over DBus for the
+// operation lifetime, after which it's removed.
+//
+// Parameters:
+// op: Operation to stop tracking.
+func (manager *SnapdPackageManagerInterface) finishOperation(op *operation) {
+	manager.queuesLock.Lock()
+	manager.dequeueOperation(op)
+	next := manager.startNext(op.queueKeys()...)
+	manager.retireOperation(op)
+	manager.queuesLock.Unlock()
+
+	manager.launch(next)
+
+	err := manager.paths.forget(op.change())
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to forget operation %s: %s", op.id, err)
+	}
+}
+
+// settleOperation records the final status of an operation, and adds it to the
+// history before clients are told about it, so they can look it up right away.
+//
+// Parameters:
+// op: Operation that reached its final status.
+// status: Final status ("done", "error", "canceled").
+// errorMessage: Reason for the failure (empty if none).
+func (manager *SnapdPackageManagerInterface) settleOperation(op *operation, status string, errorMessage string) {
+	op.finish(status, errorMessage)
+	manager.recordHistory(op)
+}
+
+// retireOperation schedules the removal of a finished operation once its
+// lifetime expires.
+//
+// Parameters:
+// op: Finished operation.
+func (manager *SnapdPackageManagerInterface) retireOperation(op *operation) {
+	manager.touch()
+
+	time.AfterFunc(manager.operationLifetime, func() {
+		manager.removeOperation(op)
+	})
+}
+
+// removeOperation forgets about an operation, and removes it from DBus.
+//
+// Parameters:
+// op: Operation to remove.
+func (manager *SnapdPackageManagerInterface) removeOperation(op *operation) {
+	manager.operationsLock.Lock()
+	if manager.operations[op.id] == op {
+		delete(manager.operations, op.id)
+	}
+	manager.operationsLock.Unlock()
+
+	path := manager.getObjectPath(op.id)
+	manager.dbusConnection.Export(nil, path, manager.interfaceName)
+	manager.dbusConnection.Export(nil, path, propertiesInterfaceName)
+	manager.dbusConnection.Export(nil, path, "org.freedesktop.DBus.Introspectable")
+}
+
+// exportOperation exports an operation's properties and methods over DBus.
+// Failing to do so isn't fatal: progress is still reported via signals.
+//
+// Parameters:
+// op: Operation to export.
+func (manager *SnapdPackageManagerInterface) exportOperation(op *operation) {
+	path := manager.getObjectPath(op.id)
+
+	err := manager.dbusConnection.Export(
+		newOperationProperties(op, manager.interfaceName), path,
+		propertiesInterfaceName)
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to export operation %s: %s",
+			op.id, err)
+		return
+	}
+
+	err = manager.dbusConnection.Export(newOperationControl(manager, op), path,
+		manager.interfaceName)
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to export methods of operation %s: %s",
+			op.id, err)
+	}
+
+	err = manager.dbusConnection.Export(
+		operationIntrospection(manager.interfaceName), path,
+		"org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to export introspection for operation %s: %s",
+			op.id, err)
+	}
+}
+
+// recordHistory adds a finished operation to the history. Failing to do so
+// isn't fatal: the operation itself is done either way.
+//
+// Parameters:
+// op: Finished operation.
+func (manager *SnapdPackageManagerInterface) recordHistory(op *operation) {
+	if manager.history == nil {
+		return
+	}
+
+	// Operations never started can't have changed anything
+	revisionsAfter := map[string]string{}
+	if op.change() != "" {
+		revisionsAfter = manager.revisions(op.packageIds)
+	}
+
+	err := manager.history.record(op.historyEntry(revisionsAfter))
+	if err != nil {
+		log.Printf("package-management-daemon: Unable to record operation %s in history: %s",
+			op.id, err)
+	}
+}
+
+// revisions looks up the revisions of installed packages.
+//
+// Parameters:
+// packageIds: IDs of the packages (none for all packages).
+//
+// Returns:
+// - Revision of each installed package (empty if unknown).
+func (manager *SnapdPackageManagerInterface) revisions(packageIds []string) map[string]string {
+	revisions := make(map[string]string)
+
+	// snapd complains when none of the packages is installed
+	snaps, err := manager.client.List(packageIds)
+	if err != nil {
+		return revisions
+	}
+
+	for _, snap := range snaps {
+		revisions[snap.Name] = snap.Revision.String()
+	}
+
+	return revisions
+}
+
+// touch records activity, postponing the moment the manager becomes idle.
+func (manager *SnapdPackageManagerInterface) touch() {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	manager.lastActivity = time.Now()
+}
+
+// idleSince determines whether the manager is idle, i.e. has no operations
+// queued or in progress, and since when.
+//
+// Returns:
+// - Time of the last activity.
+// - Whether or not the manager is idle.
+func (manager *SnapdPackageManagerInterface) idleSince() (time.Time, bool) {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	for _, op := range manager.operations {
+		if !op.isFinished() {
+			return time.Time{}, false
+		}
+	}
+
+	return manager.lastActivity, true
+}
+
+// operationForPackage finds the operation in progress (or next in line) for a
+// given package.
+//
+// Parameters:
+// packageId: ID of the package being operated upon.
+//
+// Returns:
+// - The operation (nil if none).
+func (manager *SnapdPackageManagerInterface) operationForPackage(packageId string) *operation {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	for _, op := range manager.queues[packageId] {
+		if !op.isFinished() {
+			return op
+		}
+	}
+
+	return nil
+}
+
+// operationInFlight finds the operation snapd is currently carrying out on a
+// given package alone, i.e. the one at the head of its queue once started.
+//
+// Parameters:
+// packageId: ID of the package being operated upon.
+//
+// Returns:
+// - The operation (nil if none).
+func (manager *SnapdPackageManagerInterface) operationInFlight(packageId string) *operation {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	queue := manager.queues[packageId]
+	if len(queue) == 0 {
+		return nil
+	}
+
+	op := queue[0]
+	if op.packageId == "" || op.packageId != packageId || op.isQueued() || op.isFinished() {
+		return nil
+	}
+
+	return op
+}
+
+// operationForChange finds the operation following a given snapd change.
+//
+// Parameters:
+// changeID: ID of the snapd change.
+//
+// Returns:
+// - The operation (nil if none).
+func (manager *SnapdPackageManagerInterface) operationForChange(changeID string) *operation {
+	manager.operationsLock.Lock()
+	defer manager.operationsLock.Unlock()
+
+	for _, op := range manager.operations {
+		if op.change() == changeID {
+			return op
+		}
+	}
+
+	return nil
+}
+
+// operationObjectPath is used to generate an object path for a given operation.
+//
+// Parameters:
+// operationId: ID of the operation to be represented by this object path.
+//
+// Returns:
+// - New object path.
+func (manager *SnapdPackageManagerInterface) getObjectPath(operationId string) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s/%s",
+		manager.baseObjectPath, operationId))
+}
+
+// authorize checks whether the caller is allowed to manage packages. The user
+// is never asked to authenticate, since that could take longer than the
+// caller waits for a reply.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+//
+// Returns:
+// - DBus error (nil if authorized)
+func (manager *SnapdPackageManagerInterface) authorize(sender dbus.Sender) *dbus.Error {
+	_, dbusErr := manager.checkAuthorization(sender, manageAction, false,
+		fmt.Sprintf(`Caller "%s" is not authorized to manage packages`, sender))
+	return dbusErr
+}
+
+// authorizeCancel checks whether the caller is allowed to cancel an operation:
+// those it requested are fine, while those requested by others need an
+// additional authorization.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// op: Operation to be canceled.
+//
+// Returns:
+// - Whether or not the caller would be authorized once the user authenticates.
+// - DBus error (nil if authorized)
+func (manager *SnapdPackageManagerInterface) authorizeCancel(sender dbus.Sender, op *operation) (bool, *dbus.Error) {
+	if op.isOwnedBy(sender) {
+		return false, nil
+	}
+
+	return manager.checkAuthorization(sender, manageOthersAction, false,
+		fmt.Sprintf(`Caller "%s" is not authorized to cancel operations requested by others`, sender))
+}
+
+// checkAuthorization checks whether the caller is allowed to perform a polkit
+// action.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// action: ID of the polkit action.
+// interactive: Whether or not the user may be asked to authenticate.
+// denied: Message of the error returned if the caller isn't authorized.
+//
+// Returns:
+// - Whether or not the caller would be authorized once the user authenticates.
+// - DBus error (nil if authorized)
+func (manager *SnapdPackageManagerInterface) checkAuthorization(sender dbus.Sender, action string, interactive bool, denied string) (bool, *dbus.Error) {
+	authorized, challenge, err := manager.authority.checkAuthorization(sender, action, interactive)
+	if err != nil {
+		log.Printf(`package-management-daemon: Unable to authorize "%s": %s`, sender, err)
+		return false, dbus.NewError("org.freedesktop.DBus.Error.AccessDenied",
+			[]interface{}{fmt.Sprintf("Unable to authorize caller: %s", err)})
+	}
+
+	if !authorized {
+		return challenge, dbus.NewError("org.freedesktop.DBus.Error.AccessDenied",
+			[]interface{}{denied})
+	}
+
+	return false, nil
+}
+
+// checkPolicy checks whether the policy allows installing packages, logging
+// denied attempts.
+//
+// Parameters:
+// sender: Unique bus name of the caller.
+// packageIds: IDs of the packages to be installed.
+//
+// Returns:
+// - DBus error (nil if allowed)
+func (manager *SnapdPackageManagerInterface) checkPolicy(sender dbus.Sender, packageIds ...string) *dbus.Error {
+	for _, packageId := range packageIds {
+		var publisher string
+		if manager.policy.HasPublisherRules() {
+			snap, _, err := manager.client.FindOne(packageId)
+			if err != nil {
+				log.Printf(`package-management-daemon: Denied install of "%s" requested by "%s": unable to determine publisher: %s`,
+					packageId, sender, err)
+				return dbus.NewError(manager.policyDeniedErrorName,
+					[]interface{}{fmt.Sprintf(`Unable to determine publisher of snap "%s": %s`, packageId, err)})
+			}
+
+			publisher = snap.Developer
+		}
+
+		err := manager.policy.Check(packageId, publisher)
+		if err != nil {
+			log.Printf(`package-management-daemon: Denied install requested by "%s": %s`, sender, err)
+			return dbus.NewError(manager.policyDeniedErrorName, []interface{}{err.Error()})
+		}
+	}
+
+	return nil
+}
+
+// configure applies the settings read from the configuration file.
+//
+// Parameters:
+// config: Settings to apply.
+func (manager *SnapdPackageManagerInterface) configure(config *Config) {
+	manager.invalidator.setScopes(config.InvalidateScopes)
+
+	manager.queuesLock.Lock()
+	manager.abortOnDisconnect = config.AbortOnDisconnect
+	manager.queuesLock.Unlock()
+}
+
+// emitProgress emits the `progres` DBus signal.
+//
+// Parameters:
+// op: Operation whose progress is being emitted.
+// received: Received count.
+// total: Total count.
+func (manager *SnapdPackageManagerInterface) emitProgress(op *operation, received uint64, total uint64) {
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.progressSignalName, received, total)
+}
+
+// emitDownloadRate emits the `downloadRate` DBus signal.
+//
+// Parameters:
+// op: Operation whose download rate is being emitted.
+// rate: Download rate in bytes per second.
+// eta: Estimated time remaining in seconds (-1 if unknown).
+func (manager *SnapdPackageManagerInterface) emitDownloadRate(op *operation, rate uint64, eta int64) {
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.downloadRateSignalName, rate, eta)
+}
+
+// emitProcessing emits the `processing` DBus signal.
+//
+// Parameters:
+// op: Operation that is processing.
+func (manager *SnapdPackageManagerInterface) emitProcessing(op *operation) {
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.processingSignalName, "")
+}
+
+// emitFinished emits the `finished` DBus signal.
+//
+// Parameters:
+// op: Operation that just finished.
+func (manager *SnapdPackageManagerInterface) emitFinished(op *operation) {
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.finishedSignalName, "")
+
+	// Refresh the apps scope and the snappy store scope, among others
+	manager.invalidator.invalidate()
+
+	manager.notifier.notifyFinished(op)
+}
+
+// emitError emits the `error` DBus signal.
+//
+// Parameters:
+// op: Operation that encountered an error.
+// format: Format string of the error.
+// a...: List of values for the placeholders in the `format` string.
+func (manager *SnapdPackageManagerInterface) emitError(op *operation, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.errorSignalName, message)
+
+	manager.notifier.notifyError(op, message)
+}
+
+// emitCanceled emits the `canceled` DBus signal.
+//
+// Parameters:
+// op: Operation that was canceled.
+func (manager *SnapdPackageManagerInterface) emitCanceled(op *operation) {
+	manager.dbusConnection.Emit(manager.getObjectPath(op.id),
+		manager.canceledSignalName, true)
+}
+
+// wait follows the progress of an operation until its change is ready,
+// emitting the corresponding DBus signals along the way.
+//
+// Parameters:
+// op: Operation to follow.
+func (manager *SnapdPackageManagerInterface) wait(op *operation) {
+	defer manager.finishOperation(op)
+
+	subscription := manager.monitor.subscribe(op.change())
+	defer manager.monitor.unsubscribe(subscription)
+
+	tMax := time.Time{}
+
+	for {
+		var update changeUpdate
+		select {
+		case update = <-subscription.updates:
+		case <-op.cancelRequested:
+			// Find out about the change being undone right away
+			manager.monitor.poke()
+			continue
+		}
+
+		chg, err := update.change, update.err
+		if err != nil {
+			// an error here means the server most likely went away
+			// XXX: it actually can be a bunch of other things; fix client to expose it better
+			now := time.Now()
+			if tMax.IsZero() {
+				tMax = now.Add(manager.monitor.period * 5)
+			}
+			if now.After(tMax) {
+				message := fmt.Sprintf("Error talking to snapd: %s", err)
+				manager.settleOperation(op, operationStatusError, message)
+				manager.emitError(op, "%s", message)
+				return
+			}
+			manager.emitProcessing(op)
+			continue
+		}
+		if !tMax.IsZero() {
+			tMax = time.Time{}
+		}
+
+		progress, done := changePackageProgress(chg)
+		op.setPackageProgress(progress, done)
+
+		if op.isBatch() {
+			// Report how many packages are done, details being
+			// available via the PackageProgress property.
+			received, total := op.advanceProgress(uint64(done), uint64(len(op.packageIds)))
+			manager.emitProgress(op, received, total)
+		} else if received, total := changeProgress(chg); total == 0 {
+			// Nothing to report until snapd has planned the change
+			manager.emitProcessing(op)
+		} else {
+			received, total = op.advanceProgress(received, total)
+			manager.emitProgress(op, received, total)
+		}
+
+		if downloaded, total := changeDownload(chg); total > 0 {
+			rate, eta := op.sampleDownload(downloaded, total, time.Now())
+			manager.emitDownloadRate(op, rate, eta)
+		}
+
+		if chg.Ready {
+			if chg.Status == "Done" {
+				manager.settleOperation(op, operationStatusDone, "")
+				manager.emitFinished(op)
+			} else if op.isCanceled() {
+				manager.settleOperation(op, operationStatusCanceled, "")
+				manager.emitCanceled(op)
+			} else if chg.Err != "" {
+				manager.settleOperation(op, operationStatusError, chg.Err)
+				manager.emitError(op, "%s", chg.Err)
+			} else {
+				message := fmt.Sprintf("Change ended with status %s", chg.Status)
+				manager.settleOperation(op, operationStatusError, message)
+				manager.emitError(op, "%s", message)
+			}
+
+			return
+		}
+	}
+}
+
+// changeSnapName extracts the snap name from a change summary, e.g.
+// `Install "foo" snap`.
+var changeSnapName = regexp.MustCompile(`"([^"]+)"`)
+
+// changeOperation determines the kind of operation and the packages a snapd
+// change is operating upon.
+//
+// Parameters:
+// change: Change to inspect.
+//
+// Returns:
+// - Kind of operation ("install", "remove", "refresh").
+// - IDs of the packages being operated upon (empty if unknown for a batch).
+// - Whether or not the change is one the daemon knows how to follow.
+func changeOperation(change *client.Change) (string, []string, bool) {
+	var kind string
+	single := true
+
+	switch change.Kind {
+	case "install-snap":
+		kind = operationKindInstall
+	case "remove-snap":
+		kind = operationKindRemove
+	case "refresh-snap":
+		kind = operationKindRefresh
+	case "install-snaps":
+		kind, single = operationKindInstall, false
+	case "remove-snaps":
+		kind, single = operationKindRemove, false
+	case "refresh-snaps":
+		kind, single = operationKindRefresh, false
+	default:
+		return "", nil, false
+	}
+
+	var packageIds []string
+	for _, match := range changeSnapName.FindAllStringSubmatch(change.Summary, -1) {
+		packageIds = append(packageIds, match[1])
+	}
+
+	if !single {
+		return kind, packageIds, true
+	}
+
+	if len(packageIds) == 0 {
+		return "", nil, false
+	}
+
+	return kind, packageIds[:1], true
+}
+
+// changePackageProgress determines the progress of each package a change is
+// operating upon, based on the snap names found in its task summaries, e.g.
+// `Download snap "foo" from channel "stable"`.
+//
+// Parameters:
+// change: Change to inspect.
+//
+// Returns:
+// - Progress of each package's download (bytes received/total).
+// - Number of packages whose tasks are all done.
+func changePackageProgress(change *client.Change) (map[string]operationProgress, uint32) {
+	progress := make(map[string]operationProgress)
+	pending := make(map[string]bool)
+
+	for _, task := range change.Tasks {
+		match := changeSnapName.FindStringSubmatch(task.Summary)
+		if match == nil {
+			continue
+		}
+
+		packageId := match[1]
+		if _, ok := progress[packageId]; !ok {
+			progress[packageId] = operationProgress{}
+		}
+
+		if task.Status != "Done" {
+			pending[packageId] = true
+		}
+
+		if task.Progress.Total > 1 {
+			progress[packageId] = operationProgress{
+				Received: uint64(task.Progress.Done),
+				Total:    uint64(task.Progress.Total),
+			}
+		}
+	}
+
+	return progress, uint32(len(progress) - len(pending))
+}
+
+// changeProgress computes the overall progress of a change across all of its
+// tasks. Downloads are weighted by their size and make up most of the
+// progress, the other tasks sharing the remainder equally.
+//
+// Parameters:
+// change: Change to inspect.
+//
+// Returns:
+// - Progress made, out of the total.
+// - Total (zero if the change has no tasks).
+func changeProgress(change *client.Change) (uint64, uint64) {
+	downloaded, downloadTotal := changeDownload(change)
+
+	var steps, stepsDone uint64
+	for _, task := range change.Tasks {
+		if task.Progress.Total > 1 {
+			continue
+		}
+
+		steps++
+		if task.Status == "Done" {
+			stepsDone++
+		}
+	}
+
+	var downloadShare uint64
+	switch {
+	case downloadTotal == 0 && steps == 0:
+		return 0, 0
+	case downloadTotal == 0:
+		downloadShare = 0
+	case steps == 0:
+		downloadShare = progressScale
+	default:
+		downloadShare = progressDownloadShare
+	}
+
+	var received uint64
+	if downloadTotal > 0 {
+		received += downloadShare * downloaded / downloadTotal
+	}
+	if steps > 0 {
+		received += (progressScale - downloadShare) * stepsDone / steps
+	}
+
+	return received, progressScale
+}
+
+// changeDownload sums up the progress of every download in a change.
+//
+// Parameters:
+// change: Change to inspect.
+//
+// Returns:
+// - Number of bytes downloaded.
+// - Total number of bytes to download (zero if nothing to download).
+func changeDownload(change *client.Change) (uint64, uint64) {
+	var downloaded, total uint64
+
+	for _, task := range change.Tasks {
+		if task.Progress.Total <= 1 {
+			continue
+		}
+
+		taskTotal := uint64(task.Progress.Total)
+		taskDone := uint64(task.Progress.Done)
+		if task.Status == "Done" || taskDone > taskTotal {
+			taskDone = taskTotal
+		}
+
+		downloaded += taskDone
+		total += taskTotal
+	}
+
+	return downloaded, total
+}
+
+// quotedList formats a list of package IDs for use in messages.
+//
+// Parameters:
+// packageIds: IDs of the packages.
+//
+// Returns:
+// - Formatted list, e.g. "'foo', 'bar'"
+func quotedList(packageIds []string) string {
+	quoted := make([]string, len(packageIds))
+	for i, packageId := range packageIds {
+		quoted[i] = fmt.Sprintf("'%s'", packageId)
+	}
+
+	return strings.Join(quoted, ", ")
 }
@@ -19,6 +19,14 @@
 package daemon
 
 import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/policy"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,6 +42,10 @@ func TestNewSnapdPackageManagerInterface(t *testing.T) {
 		t.Errorf(`Progress signal name was "%s", expected "foo.progress"`, manager.progressSignalName)
 	}
 
+	if manager.downloadRateSignalName != "foo.downloadRate" {
+		t.Errorf(`Download rate signal name was "%s", expected "foo.downloadRate"`, manager.downloadRateSignalName)
+	}
+
 	if manager.finishedSignalName != "foo.finished" {
 		t.Errorf(`Finished signal name was "%s", expected "foo.finished"`, manager.finishedSignalName)
 	}
@@ -41,6 +53,10 @@ func TestNewSnapdPackageManagerInterface(t *testing.T) {
 	if manager.errorSignalName != "foo.error" {
 		t.Errorf(`Error signal name was "%s", expected "foo.error"`, manager.errorSignalName)
 	}
+
+	if manager.canceledSignalName != "foo.canceled" {
+		t.Errorf(`Canceled signal name was "%s", expected "foo.canceled"`, manager.canceledSignalName)
+	}
 }
 
 // Test that NewSnapdmPackageManagerInterface fails with an invalid base object
@@ -63,15 +79,16 @@ func TestSnapdInstall(t *testing.T) {
 	}
 
 	// Make the manager poll faster so the tests are more timely
-	manager.pollPeriod = time.Millisecond
+	manager.monitor = newChangeMonitor(manager.client, time.Millisecond)
+	manager.authority = new(FakeAuthority)
 
 	// Begin installation of two packages
-	_, dbusErr := manager.Install("foo")
+	_, dbusErr := manager.Install(":1.42", "foo")
 	if dbusErr == nil {
 		t.Fatalf("Expected error while installing 'foo'")
 	}
 
-	_, dbusErr2 := manager.Install("bar")
+	_, dbusErr2 := manager.Install(":1.42", "bar")
 	if dbusErr2 == nil {
 		t.Fatalf("Expected error while installing 'bar'")
 	}
@@ -88,16 +105,1603 @@ func TestSnapdUninstall(t *testing.T) {
 	}
 
 	// Make the manager poll faster so the tests are more timely
-	manager.pollPeriod = time.Millisecond
+	manager.monitor = newChangeMonitor(manager.client, time.Millisecond)
+	manager.authority = new(FakeAuthority)
 
 	// Begin installation of two packages
-	_, dbusErr := manager.Uninstall("foo")
+	_, dbusErr := manager.Uninstall(":1.42", "foo")
 	if dbusErr == nil {
 		t.Fatalf("Expected error while installing 'foo'")
 	}
 
-	_, dbusErr2 := manager.Uninstall("bar")
+	_, dbusErr2 := manager.Uninstall(":1.42", "bar")
 	if dbusErr2 == nil {
 		t.Fatalf("Expected error while installing 'bar'")
 	}
 }
+
+// Test typical Refresh usage.
+func TestSnapdRefresh(t *testing.T) {
+	dbusServer := new(FakeDbusServer)
+	dbusServer.InitializeSignals()
+
+	manager, err := NewSnapdPackageManagerInterface(dbusServer, "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	// Make the manager poll faster so the tests are more timely
+	manager.monitor = newChangeMonitor(manager.client, time.Millisecond)
+	manager.authority = new(FakeAuthority)
+
+	// Begin refresh of two packages
+	_, dbusErr := manager.Refresh(":1.42", "foo")
+	if dbusErr == nil {
+		t.Fatalf("Expected error while refreshing 'foo'")
+	}
+
+	_, dbusErr2 := manager.Refresh(":1.42", "bar")
+	if dbusErr2 == nil {
+		t.Fatalf("Expected error while refreshing 'bar'")
+	}
+}
+
+// Test typical RefreshAll usage.
+func TestSnapdRefreshAll(t *testing.T) {
+	dbusServer := new(FakeDbusServer)
+	dbusServer.InitializeSignals()
+
+	manager, err := NewSnapdPackageManagerInterface(dbusServer, "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	// Make the manager poll faster so the tests are more timely
+	manager.monitor = newChangeMonitor(manager.client, time.Millisecond)
+	manager.authority = new(FakeAuthority)
+
+	_, dbusErr := manager.RefreshAll(":1.42")
+	if dbusErr == nil {
+		t.Fatalf("Expected error while refreshing all packages")
+	}
+}
+
+// Test that canceling when no operation is in progress results in an error.
+func TestSnapdCancel_noOperation(t *testing.T) {
+	manager, err := NewSnapdPackageManagerInterface(new(FakeDbusServer), "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	manager.authority = new(FakeAuthority)
+
+	dbusErr := manager.Cancel(":1.42", "foo")
+	if dbusErr == nil {
+		t.Error("Expected an error due to no operation being in progress")
+	}
+}
+
+// Test that failing to abort the change results in an error, and doesn't mark
+// the operation as canceled.
+func TestSnapdCancel_abortFailure(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	op := manager.operationForPackage("foo")
+
+	snapd.lock.Lock()
+	snapd.failAbort = true
+	snapd.lock.Unlock()
+
+	dbusErr := manager.Cancel(":1.42", "foo")
+	if dbusErr == nil {
+		t.Error("Expected an error due to snapd failing to abort")
+	}
+
+	if op.isCanceled() {
+		t.Error("Expected operation not to be canceled")
+	}
+}
+
+// Test that operations can be looked up by package until they're finished.
+func TestSnapdOperationForPackage(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+
+	manager.operationsLock.Lock()
+	op := manager.operations["1"]
+	manager.operationsLock.Unlock()
+
+	if op == nil || manager.operationForPackage("foo") != op {
+		t.Error("Expected to find the operation for 'foo'")
+	}
+
+	if manager.operationForPackage("bar") != nil {
+		t.Error("Expected no operation for 'bar'")
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return manager.operationForPackage("foo") == nil },
+		"Expected no operation for 'foo' once finished")
+}
+
+// Test that operations are exported over DBus when started, and removed once
+// their lifetime expires after finishing.
+func TestSnapdOperationExport(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	dbusServer := manager.dbusConnection.(*FakeDbusServer)
+
+	manager.operationLifetime = time.Millisecond
+
+	manager.Install(":1.42", "foo")
+	op := manager.operationForPackage("foo")
+
+	properties, ok := dbusServer.ExportedObject("/foo/1", propertiesInterfaceName).(*operationProperties)
+	if !ok {
+		t.Fatal("Expected operation properties to be exported at /foo/1")
+	}
+
+	if properties.op != op {
+		t.Error("Expected exported properties to be those of the new operation")
+	}
+
+	if properties.interfaceName != "foo" {
+		t.Errorf(`Properties interface was "%s", expected "foo"`, properties.interfaceName)
+	}
+
+	if dbusServer.ExportedObject("/foo/1", "org.freedesktop.DBus.Introspectable") == nil {
+		t.Error("Expected introspection to be exported at /foo/1")
+	}
+
+	if control, ok := dbusServer.ExportedObject("/foo/1", "foo").(*operationControl); !ok || control.op != op {
+		t.Error("Expected the methods of the new operation to be exported at /foo/1")
+	}
+
+	snapd.finishChange("1", "Done")
+
+	deadline := time.Now().Add(time.Second)
+	for dbusServer.ExportedObject("/foo/1", propertiesInterfaceName) != nil ||
+		dbusServer.ExportedObject("/foo/1", "foo") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected operation to be unexported after its lifetime")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.operationsLock.Lock()
+	_, ok = manager.operations["1"]
+	manager.operationsLock.Unlock()
+
+	if ok {
+		t.Error("Expected operation to be forgotten after its lifetime")
+	}
+}
+
+// Test that failing to export an operation doesn't prevent it from being
+// tracked.
+func TestSnapdOperationExport_failure(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.dbusConnection.(*FakeDbusServer).failExport = true
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	if manager.operationForPackage("foo") == nil {
+		t.Error("Expected to find the operation for 'foo'")
+	}
+}
+
+// Test that only operations in progress are listed.
+func TestSnapdListOperations(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "bar")
+	manager.Refresh(":1.42", "baz")
+
+	snapd.finishChange("3", "Done")
+
+	waitUntil(t, func() bool { return manager.operationForPackage("baz") == nil },
+		"Expected the refresh to finish")
+
+	objectPaths, dbusErr := manager.ListOperations()
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error listing operations: %s", dbusErr)
+	}
+
+	expected := []dbus.ObjectPath{"/foo/1", "/foo/2"}
+	if !reflect.DeepEqual(objectPaths, expected) {
+		t.Errorf("Operations were %v, expected %v", objectPaths, expected)
+	}
+}
+
+// Test typical GetOperationForPackage usage.
+func TestSnapdGetOperationForPackage(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+
+	objectPath, dbusErr := manager.GetOperationForPackage("foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting operation: %s", dbusErr)
+	}
+
+	if objectPath != "/foo/1" {
+		t.Errorf(`Object path was "%s", expected "/foo/1"`, objectPath)
+	}
+}
+
+// Test that GetOperationForPackage fails with a specific error when no
+// operation is in progress for the package.
+func TestSnapdGetOperationForPackage_noOperation(t *testing.T) {
+	manager, err := NewSnapdPackageManagerInterface(new(FakeDbusServer), "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	_, dbusErr := manager.GetOperationForPackage("foo")
+	if dbusErr == nil {
+		t.Fatal("Expected an error due to no operation being in progress")
+	}
+
+	if dbusErr.Name != "foo.Error.NoOperation" {
+		t.Errorf(`Error was "%s", expected "foo.Error.NoOperation"`, dbusErr.Name)
+	}
+}
+
+// Test that failing to reach snapd while resuming operations results in an
+// error.
+func TestSnapdResumeOperations_snapdFailure(t *testing.T) {
+	manager, err := NewSnapdPackageManagerInterface(new(FakeDbusServer), "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	err = manager.resumeOperations()
+	if err == nil {
+		t.Error("Expected an error due to snapd not being available")
+	}
+}
+
+// Test that operations that waited in a queue are resumed under the object
+// path they had before the restart.
+func TestSnapdResumeOperations_queuedPath(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	path := filepath.Join(directory, "operations.json")
+	paths, err := loadOperationPaths(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading journal: %s", err)
+	}
+
+	paths.remember("7", "queued3")
+	paths.remember("9", "queued4")
+
+	manager, snapd := newQueueTestManager(t)
+	manager.paths = paths
+
+	// Change 9 finished while the daemon wasn't running
+	snapd.addChange(&client.Change{ID: "7", Kind: "remove-snap", Summary: `Remove "foo" snap`})
+
+	err = manager.resumeOperations()
+	if err != nil {
+		t.Fatalf("Unexpected error resuming operations: %s", err)
+	}
+
+	op := manager.operationForChange("7")
+	if op == nil || op.id != "queued3" {
+		t.Fatal("Expected change 7 to be resumed as queued3")
+	}
+
+	objectPath, dbusErr := manager.GetOperationForPackage("foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting operation: %s", dbusErr)
+	}
+
+	if objectPath != "/foo/queued3" {
+		t.Errorf(`Object path was "%s", expected "/foo/queued3"`, objectPath)
+	}
+
+	// New queued operations must not reuse the resumed ID
+	objectPath, dbusErr = manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	if objectPath != "/foo/queued4" {
+		t.Errorf(`Object path was "%s", expected "/foo/queued4"`, objectPath)
+	}
+
+	paths, err = loadOperationPaths(path)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading journal: %s", err)
+	}
+
+	if _, ok := paths.lookup("9"); ok {
+		t.Error("Expected the finished change to be forgotten")
+	}
+
+	snapd.finishChange("7", "Done")
+
+	waitUntil(t, func() bool { _, ok := manager.paths.lookup("7"); return !ok },
+		"Expected change 7 to be forgotten once done")
+}
+
+// Test that the object paths of queued operations are remembered while
+// they're in progress.
+func TestSnapdQueue_rememberPath(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	paths, err := loadOperationPaths(filepath.Join(directory, "operations.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error loading journal: %s", err)
+	}
+
+	manager, snapd := newQueueTestManager(t)
+	manager.paths = paths
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	objectPath, dbusErr := manager.Uninstall(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while uninstalling: %s", dbusErr)
+	}
+
+	// Operations started right away are known by their change ID already
+	if _, ok := paths.lookup("1"); ok {
+		t.Error("Expected the first operation not to be remembered")
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { _, ok := paths.lookup("2"); return ok },
+		"Expected the queued operation to be remembered once started")
+
+	if id, _ := paths.lookup("2"); manager.getObjectPath(id) != objectPath {
+		t.Errorf(`Remembered path was "%s", expected "%s"`, manager.getObjectPath(id), objectPath)
+	}
+
+	snapd.finishChange("2", "Done")
+
+	waitUntil(t, func() bool { _, ok := paths.lookup("2"); return !ok },
+		"Expected the queued operation to be forgotten once done")
+}
+
+// Data for TestChangeOperation
+var changeOperationTests = []struct {
+	change             *client.Change
+	expectedKind       string
+	expectedPackageIds []string
+	expectedOk         bool
+}{
+	{&client.Change{Kind: "install-snap", Summary: `Install "foo" snap`}, operationKindInstall, []string{"foo"}, true},
+	{&client.Change{Kind: "remove-snap", Summary: `Remove "foo" snap`}, operationKindRemove, []string{"foo"}, true},
+	{&client.Change{Kind: "refresh-snap", Summary: `Refresh "foo" snap`}, operationKindRefresh, []string{"foo"}, true},
+	{&client.Change{Kind: "refresh-snaps", Summary: `Refresh snaps "foo", "bar"`}, operationKindRefresh, []string{"foo", "bar"}, true},
+	{&client.Change{Kind: "refresh-snaps", Summary: "Refresh all snaps"}, operationKindRefresh, nil, true},
+	{&client.Change{Kind: "install-snaps", Summary: `Install snaps "foo", "bar"`}, operationKindInstall, []string{"foo", "bar"}, true},
+	{&client.Change{Kind: "remove-snaps", Summary: `Remove snaps "foo", "bar"`}, operationKindRemove, []string{"foo", "bar"}, true},
+	{&client.Change{Kind: "install-snap", Summary: "Install snap"}, "", nil, false},
+	{&client.Change{Kind: "connect-snap", Summary: `Connect "foo:bar"`}, "", nil, false},
+}
+
+// Test that changes are mapped to the operations they represent.
+func TestChangeOperation(t *testing.T) {
+	for i, test := range changeOperationTests {
+		kind, packageIds, ok := changeOperation(test.change)
+
+		if ok != test.expectedOk {
+			t.Errorf("Test case %d: Ok was %t, expected %t", i, ok, test.expectedOk)
+		}
+
+		if kind != test.expectedKind {
+			t.Errorf(`Test case %d: Kind was "%s", expected "%s"`, i, kind, test.expectedKind)
+		}
+
+		if !reflect.DeepEqual(packageIds, test.expectedPackageIds) {
+			t.Errorf(`Test case %d: Package IDs were %v, expected %v`, i, packageIds, test.expectedPackageIds)
+		}
+	}
+}
+
+// Test that the manager is only idle without operations in progress, and that
+// calls count as activity.
+func TestSnapdIdleSince(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	before, idle := manager.idleSince()
+	if !idle {
+		t.Error("Expected new manager to be idle")
+	}
+
+	manager.Install(":1.42", "foo")
+
+	_, idle = manager.idleSince()
+	if idle {
+		t.Error("Expected manager not to be idle with an operation in progress")
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool {
+		_, idle := manager.idleSince()
+		return idle
+	}, "Expected manager to become idle once the operation finished")
+
+	manager.ListOperations()
+
+	after, idle := manager.idleSince()
+	if !idle {
+		t.Error("Expected manager to be idle once the operation finished")
+	}
+
+	if !after.After(before) {
+		t.Error("Expected calls to count as activity")
+	}
+}
+
+// newQueueTestManager creates a manager talking to a fake snapd, polling
+// quickly so the tests are more timely.
+func newQueueTestManager(t *testing.T) (*SnapdPackageManagerInterface, *FakeSnapdClient) {
+	manager, err := NewSnapdPackageManagerInterface(new(FakeDbusServer), "foo", "/foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while creating new manager: %s", err)
+	}
+
+	snapd := new(FakeSnapdClient)
+	manager.client = snapd
+	manager.monitor = newChangeMonitor(snapd, time.Millisecond)
+	manager.authority = new(FakeAuthority)
+
+	return manager, snapd
+}
+
+// waitUntil polls a condition until it's true, failing the test if it takes
+// too long.
+func waitUntil(t *testing.T, condition func() bool, message string) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Test that requesting the same operation twice coalesces the requests.
+func TestSnapdQueue_coalesce(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	objectPath1, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	objectPath2, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	if objectPath1 != objectPath2 {
+		t.Errorf(`Got object paths "%s" and "%s", expected them to be the same`, objectPath1, objectPath2)
+	}
+
+	if requests := snapd.requested(); len(requests) != 1 {
+		t.Errorf("Got %d requests to snapd, expected 1: %v", len(requests), requests)
+	}
+}
+
+// Test that operations on the same package are carried out in order, one after
+// the other.
+func TestSnapdQueue_samePackage(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	objectPath1, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	objectPath2, dbusErr := manager.Uninstall(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while uninstalling: %s", dbusErr)
+	}
+
+	if objectPath1 == objectPath2 {
+		t.Fatalf(`Expected different object paths, got "%s" twice`, objectPath1)
+	}
+
+	manager.operationsLock.Lock()
+	queued := manager.operations[strings.TrimPrefix(string(objectPath2), "/foo/")]
+	manager.operationsLock.Unlock()
+
+	if queued == nil || !queued.isQueued() {
+		t.Fatal("Expected second operation to be queued")
+	}
+
+	status, _ := newOperationProperties(queued, "foo").Get("foo", "Status")
+	if status.Value() != operationStatusQueued {
+		t.Errorf(`Status was %#v, expected "%s"`, status.Value(), operationStatusQueued)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected only "install foo"`, requests)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return queued.change() == "2" },
+		"Expected second operation to start once the first one is done")
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "remove foo"}) {
+		t.Errorf(`Requests were %v, expected "install foo" then "remove foo"`, requests)
+	}
+
+	snapd.finishChange("2", "Done")
+
+	waitUntil(t, func() bool { return manager.operationForPackage("foo") == nil },
+		"Expected no operation to remain for 'foo'")
+}
+
+// Test that operations on different packages are carried out concurrently.
+func TestSnapdQueue_differentPackages(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Install(":1.42", "bar")
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "install bar"}) {
+		t.Errorf(`Requests were %v, expected both installs to be started`, requests)
+	}
+}
+
+// Test that a queued operation refused by snapd fails, and lets the next one
+// start.
+func TestSnapdQueue_startFailure(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+	manager.Refresh(":1.42", "foo")
+
+	snapd.lock.Lock()
+	snapd.failRemove = true
+	snapd.lock.Unlock()
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool {
+		requests := snapd.requested()
+		return len(requests) == 3 && requests[2] == "refresh foo"
+	}, "Expected refresh to start once the removal failed")
+}
+
+// Test that operations snapd refuses to start are only reported once the
+// queues are unlocked, so a slow notification server can't stall them.
+func TestSnapdQueue_startFailureReportedUnlocked(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	var lock sync.Mutex
+	notified, locked := false, false
+
+	dbusServer := manager.dbusConnection.(*FakeDbusServer)
+	dbusServer.beforeCall = func() {
+		queuesFree := manager.queuesLock.TryLock()
+		if queuesFree {
+			manager.queuesLock.Unlock()
+		}
+
+		lock.Lock()
+		notified, locked = true, !queuesFree
+		lock.Unlock()
+	}
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+
+	snapd.lock.Lock()
+	snapd.failRemove = true
+	snapd.lock.Unlock()
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return notified
+	}, "Expected the failure to be notified")
+
+	lock.Lock()
+	defer lock.Unlock()
+	if locked {
+		t.Error("Expected the failure to be notified with the queues unlocked")
+	}
+}
+
+// Test that a change ending without an error message, yet not done nor
+// canceled, is reported as failed.
+func TestSnapdQueue_undone(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	dbusServer := manager.dbusConnection.(*FakeDbusServer)
+	dbusServer.InitializeSignals()
+
+	manager.Install(":1.42", "foo")
+	op := manager.operationForPackage("foo")
+
+	snapd.finishChange("1", "Undone")
+
+	for {
+		signal := receiveSignal(t, dbusServer)
+		if signal.Name == manager.errorSignalName {
+			break
+		}
+	}
+
+	if op.failure() != "Change ended with status Undone" {
+		t.Errorf(`Failure was "%s", expected the change's status`, op.failure())
+	}
+}
+
+// Test that snapd is asked to start operations with the queues unlocked, so
+// other requests don't wait for it.
+func TestSnapdQueue_startUnlocked(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	var lock sync.Mutex
+	var locked []bool
+
+	snapd.beforeChange = func() {
+		queuesFree := manager.queuesLock.TryLock()
+		if queuesFree {
+			manager.queuesLock.Unlock()
+		}
+
+		lock.Lock()
+		locked = append(locked, !queuesFree)
+		lock.Unlock()
+	}
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 2 },
+		"Expected the removal to start once the install is done")
+
+	lock.Lock()
+	defer lock.Unlock()
+	if !reflect.DeepEqual(locked, []bool{false, false}) {
+		t.Errorf("Queues locked while starting was %v, expected never", locked)
+	}
+}
+
+// Test that an operation canceled while snapd is starting it has its change
+// aborted once started.
+func TestSnapdQueue_cancelWhileStarting(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	var dbusErr *dbus.Error
+	snapd.beforeChange = func() {
+		dbusErr = manager.Cancel(":1.42", "foo")
+	}
+
+	_, installErr := manager.Install(":1.42", "foo")
+	if installErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", installErr)
+	}
+
+	if dbusErr != nil {
+		t.Errorf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected "install foo" then "abort 1"`, requests)
+	}
+}
+
+// Test that refreshing all packages waits for the operations already requested,
+// and operations requested afterwards wait for it.
+func TestSnapdQueue_refreshAll(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.RefreshAll(":1.42")
+	manager.Install(":1.42", "bar")
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected only "install foo"`, requests)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 2 },
+		"Expected the refresh to start once the install is done")
+
+	time.Sleep(20 * time.Millisecond)
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "refresh-many "}) {
+		t.Errorf(`Requests were %v, expected "install foo" then "refresh-many "`, requests)
+	}
+
+	snapd.finishChange("2", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 3 },
+		"Expected the second install to start once the refresh is done")
+}
+
+// Test that canceling by package aborts the running operation, leaving the
+// queued ones to start afterwards.
+func TestSnapdQueue_cancel(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+
+	queued := manager.queues["foo"][1]
+
+	dbusErr := manager.Cancel(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	if !queued.isQueued() {
+		t.Error("Expected queued operation to remain queued")
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the install to be aborted`, requests)
+	}
+
+	snapd.finishChange("1", "Undone")
+
+	waitUntil(t, func() bool { return queued.change() == "2" },
+		"Expected the removal to start once the install is undone")
+}
+
+// Test that canceling a queued operation via its object path drops it, leaving
+// the running one alone.
+func TestSnapdQueue_cancelQueued(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	dbusServer := manager.dbusConnection.(*FakeDbusServer)
+
+	manager.Install(":1.42", "foo")
+	objectPath, _ := manager.Uninstall(":1.42", "foo")
+
+	queued := manager.queues["foo"][1]
+
+	control, ok := dbusServer.ExportedObject(objectPath, "foo").(*operationControl)
+	if !ok {
+		t.Fatalf("Expected operation methods to be exported at %s", objectPath)
+	}
+
+	dbusErr := control.Cancel(":1.42")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	status, _ := newOperationProperties(queued, "foo").Get("foo", "Status")
+	if status.Value() != operationStatusCanceled {
+		t.Errorf(`Status was %#v, expected "%s"`, status.Value(), operationStatusCanceled)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected the install to be left alone`, requests)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return manager.operationForPackage("foo") == nil },
+		"Expected no operation to remain for 'foo'")
+
+	if requests := snapd.requested(); len(requests) != 1 {
+		t.Errorf("Expected the canceled removal never to start, requests were %v", requests)
+	}
+
+	dbusErr = control.Cancel(":1.42")
+	if dbusErr == nil {
+		t.Error("Expected an error due to the operation being finished")
+	}
+}
+
+// Test that batches can't be canceled by package, but only via their object
+// path.
+func TestSnapdQueue_cancelBatch(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	dbusServer := manager.dbusConnection.(*FakeDbusServer)
+
+	objectPath, _ := manager.InstallMany(":1.42", []string{"foo", "bar"})
+
+	dbusErr := manager.Cancel(":1.42", "foo")
+	if dbusErr == nil {
+		t.Error("Expected an error due to no operation being in progress on 'foo' alone")
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install-many foo,bar"}) {
+		t.Fatalf(`Requests were %v, expected the batch not to be aborted`, requests)
+	}
+
+	control := dbusServer.ExportedObject(objectPath, "foo").(*operationControl)
+
+	dbusErr = control.Cancel(":1.42")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install-many foo,bar", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the batch to be aborted`, requests)
+	}
+}
+
+// Test typical InstallMany usage.
+func TestSnapdInstallMany(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	objectPath, dbusErr := manager.InstallMany(":1.42", []string{"foo", "bar"})
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install-many foo,bar"}) {
+		t.Errorf(`Requests were %v, expected a single batch install`, requests)
+	}
+
+	// Both packages are busy with the same operation
+	for _, packageId := range []string{"foo", "bar"} {
+		path, dbusErr := manager.GetOperationForPackage(packageId)
+		if dbusErr != nil || path != objectPath {
+			t.Errorf(`Operation for "%s" was "%s", expected "%s"`, packageId, path, objectPath)
+		}
+	}
+
+	// Installing one of them must wait for the batch to be done
+	manager.Uninstall(":1.42", "foo")
+	if requests := snapd.requested(); len(requests) != 1 {
+		t.Errorf("Expected removal to be queued, requests were %v", requests)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 2 },
+		"Expected removal to start once the batch is done")
+}
+
+// Test that a batch install waits for operations on any of its packages.
+func TestSnapdInstallMany_queued(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.InstallMany(":1.42", []string{"foo", "bar"})
+	manager.Install(":1.42", "bar")
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected only "install foo"`, requests)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 2 },
+		"Expected batch to start once 'foo' is done")
+
+	if requests := snapd.requested(); requests[1] != "install-many foo,bar" {
+		t.Errorf(`Requests were %v, expected the batch to start next`, requests)
+	}
+
+	snapd.finishChange("2", "Done")
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 3 },
+		"Expected 'bar' install to start once the batch is done")
+}
+
+// Test that an empty batch is refused.
+func TestSnapdInstallMany_empty(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+
+	_, dbusErr := manager.InstallMany(":1.42", nil)
+	if dbusErr == nil {
+		t.Error("Expected an error due to an empty batch")
+	}
+
+	_, dbusErr = manager.UninstallMany(":1.42", nil)
+	if dbusErr == nil {
+		t.Error("Expected an error due to an empty batch")
+	}
+}
+
+// Test typical UninstallMany usage, including aggregate progress.
+func TestSnapdUninstallMany(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	objectPath, dbusErr := manager.UninstallMany(":1.42", []string{"foo", "bar"})
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while uninstalling: %s", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"remove-many foo,bar"}) {
+		t.Errorf(`Requests were %v, expected a single batch removal`, requests)
+	}
+
+	snapd.setTasks("1", []*client.Task{
+		{Summary: `Remove snap "foo"`, Status: "Done"},
+		{Summary: `Remove snap "bar"`, Status: "Doing"},
+	})
+
+	manager.operationsLock.Lock()
+	op := manager.operations[strings.TrimPrefix(string(objectPath), "/foo/")]
+	manager.operationsLock.Unlock()
+
+	waitUntil(t, func() bool {
+		done, _ := newOperationProperties(op, "foo").Get("foo", "PackagesDone")
+		return done.Value() == uint32(1)
+	}, "Expected one package to be reported done")
+
+	progress, _ := newOperationProperties(op, "foo").Get("foo", "Progress")
+	if progress.Value() != (operationProgress{1, 2}) {
+		t.Errorf("Progress was %v, expected 1/2", progress.Value())
+	}
+}
+
+// Test that per-package progress is extracted from task summaries.
+func TestChangePackageProgress(t *testing.T) {
+	change := &client.Change{Tasks: []*client.Task{
+		{Summary: `Download snap "foo" from channel "stable"`, Status: "Done",
+			Progress: client.TaskProgress{Done: 10, Total: 10}},
+		{Summary: `Mount snap "foo"`, Status: "Done"},
+		{Summary: `Download snap "bar" from channel "stable"`, Status: "Doing",
+			Progress: client.TaskProgress{Done: 5, Total: 20}},
+		{Summary: `Mount snap "bar"`, Status: "Do"},
+		{Summary: "Run configure hook", Status: "Do"},
+	}}
+
+	progress, done := changePackageProgress(change)
+
+	if done != 1 {
+		t.Errorf("Got %d packages done, expected 1", done)
+	}
+
+	expected := map[string]operationProgress{
+		"foo": {10, 10},
+		"bar": {5, 20},
+	}
+
+	if !reflect.DeepEqual(progress, expected) {
+		t.Errorf("Progress was %v, expected %v", progress, expected)
+	}
+}
+
+// Data for TestChangeProgress
+var changeProgressTests = []struct {
+	tasks            []*client.Task
+	expectedReceived uint64
+}{
+	// Download halfway, nothing else done
+	{[]*client.Task{
+		{Status: "Doing", Progress: client.TaskProgress{Done: 50, Total: 100}},
+		{Status: "Do", Progress: client.TaskProgress{Total: 1}},
+	}, 400},
+
+	// Download done, one of two remaining tasks done
+	{[]*client.Task{
+		{Status: "Done", Progress: client.TaskProgress{Done: 100, Total: 100}},
+		{Status: "Done", Progress: client.TaskProgress{Total: 1}},
+		{Status: "Doing", Progress: client.TaskProgress{Total: 1}},
+	}, 900},
+
+	// Downloads are weighted by size
+	{[]*client.Task{
+		{Status: "Done", Progress: client.TaskProgress{Done: 300, Total: 300}},
+		{Status: "Do", Progress: client.TaskProgress{Total: 100}},
+	}, 750},
+
+	// No downloads at all
+	{[]*client.Task{
+		{Status: "Done"},
+		{Status: "Done"},
+		{Status: "Doing"},
+		{Status: "Do"},
+	}, 500},
+}
+
+// Test that the overall progress is weighted across all tasks.
+func TestChangeProgress(t *testing.T) {
+	for i, test := range changeProgressTests {
+		received, total := changeProgress(&client.Change{Tasks: test.tasks})
+		if received != test.expectedReceived || total != progressScale {
+			t.Errorf("Test case %d: Progress was %d/%d, expected %d/%d", i,
+				received, total, test.expectedReceived, progressScale)
+		}
+	}
+}
+
+// Test that a change without tasks has no progress to report.
+func TestChangeProgress_noTasks(t *testing.T) {
+	_, total := changeProgress(&client.Change{})
+	if total != 0 {
+		t.Errorf("Total was %d, expected 0", total)
+	}
+}
+
+// Test that progress doesn't regress when moving from the download to the
+// other tasks.
+func TestSnapdInstall_monotonicProgress(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+
+	objectPath, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	manager.operationsLock.Lock()
+	op := manager.operations[strings.TrimPrefix(string(objectPath), "/foo/")]
+	manager.operationsLock.Unlock()
+
+	progress := func() interface{} {
+		value, _ := newOperationProperties(op, "foo").Get("foo", "Progress")
+		return value.Value()
+	}
+
+	snapd.setTasks("1", []*client.Task{
+		{ID: "1", Status: "Done", Progress: client.TaskProgress{Done: 100, Total: 100}},
+		{ID: "2", Status: "Doing", Progress: client.TaskProgress{Total: 1}},
+	})
+
+	waitUntil(t, func() bool {
+		return progress() == operationProgress{800, progressScale}
+	}, "Expected download to be reported done")
+
+	// snapd going back on a task must not move the progress backwards
+	snapd.setTasks("1", []*client.Task{
+		{ID: "1", Status: "Doing", Progress: client.TaskProgress{Done: 10, Total: 100}},
+		{ID: "2", Status: "Do", Progress: client.TaskProgress{Total: 1}},
+	})
+
+	time.Sleep(20 * time.Millisecond)
+
+	if value := progress(); value != (operationProgress{800, progressScale}) {
+		t.Errorf("Progress was %v, expected it to remain 800/%d", value, progressScale)
+	}
+}
+
+// Test that downloads are summed up across all tasks.
+func TestChangeDownload(t *testing.T) {
+	change := &client.Change{Tasks: []*client.Task{
+		{Status: "Done", Progress: client.TaskProgress{Done: 10, Total: 100}},
+		{Status: "Doing", Progress: client.TaskProgress{Done: 50, Total: 200}},
+		{Status: "Do", Progress: client.TaskProgress{Total: 1}},
+	}}
+
+	downloaded, total := changeDownload(change)
+	if downloaded != 150 || total != 300 {
+		t.Errorf("Download was %d/%d, expected 150/300", downloaded, total)
+	}
+}
+
+// Test that a notification is posted once an operation finishes.
+func TestSnapdInstall_notification(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	server := manager.dbusConnection.(*FakeDbusServer)
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool {
+		return len(server.madeCalls()) == 1
+	}, "Expected a notification to be posted")
+
+	if summary := server.madeCalls()[0].args[3]; summary != "foo installed" {
+		t.Errorf(`Summary was "%s", expected "foo installed"`, summary)
+	}
+}
+
+// Test that callers are checked against polkit before starting changes.
+func TestSnapdInstall_authorization(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	authority := manager.authority.(*FakeAuthority)
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while installing: %s", dbusErr)
+	}
+
+	expected := []string{":1.42 " + manageAction}
+	if checks := authority.checks(); !reflect.DeepEqual(checks, expected) {
+		t.Errorf("Authorization checks were %v, expected %v", checks, expected)
+	}
+
+	if len(snapd.requested()) != 1 {
+		t.Errorf("Got %d requests, expected the install to be requested", len(snapd.requested()))
+	}
+}
+
+// Data for TestSnapd_accessDenied
+var accessDeniedTests = []*FakeAuthority{
+	{denied: true},
+	{fail: true},
+}
+
+// Test that unauthorized callers are denied access without making changes.
+func TestSnapd_accessDenied(t *testing.T) {
+	for i, authority := range accessDeniedTests {
+		manager, snapd := newQueueTestManager(t)
+		manager.authority = authority
+
+		requests := []func() *dbus.Error{
+			func() *dbus.Error { _, err := manager.Install(":1.42", "foo"); return err },
+			func() *dbus.Error { _, err := manager.InstallMany(":1.42", []string{"foo"}); return err },
+			func() *dbus.Error { _, err := manager.Uninstall(":1.42", "foo"); return err },
+			func() *dbus.Error { _, err := manager.UninstallMany(":1.42", []string{"foo"}); return err },
+			func() *dbus.Error { _, err := manager.Refresh(":1.42", "foo"); return err },
+			func() *dbus.Error { _, err := manager.RefreshAll(":1.42"); return err },
+			func() *dbus.Error { return manager.Cancel(":1.42", "foo") },
+		}
+
+		for j, request := range requests {
+			dbusErr := request()
+			if dbusErr == nil {
+				t.Errorf("Test case %d: Request %d: Expected access to be denied", i, j)
+				continue
+			}
+
+			if dbusErr.Name != "org.freedesktop.DBus.Error.AccessDenied" {
+				t.Errorf(`Test case %d: Request %d: Error was "%s", expected "org.freedesktop.DBus.Error.AccessDenied"`,
+					i, j, dbusErr.Name)
+			}
+		}
+
+		if requested := snapd.requested(); len(requested) != 0 {
+			t.Errorf("Test case %d: Got requests %v, expected none", i, requested)
+		}
+	}
+}
+
+// Data for TestSnapdInstall_policy
+var installPolicyTests = []struct {
+	policy  *policy.Policy
+	allowed bool
+}{
+	{nil, true},
+	{&policy.Policy{Deny: policy.Rules{Names: []string{"fo*"}}}, false},
+	{&policy.Policy{Allow: policy.Rules{Publishers: []string{"canonical"}}}, true},
+	{&policy.Policy{Allow: policy.Rules{Publishers: []string{"bar"}}}, false},
+}
+
+// Test that installs are refused unless the policy allows them.
+func TestSnapdInstall_policy(t *testing.T) {
+	for i, test := range installPolicyTests {
+		manager, snapd := newQueueTestManager(t)
+		snapd.publishers = map[string]string{"foo": "canonical"}
+		manager.policy = test.policy
+
+		_, dbusErr := manager.Install(":1.42", "foo")
+		if test.allowed {
+			if dbusErr != nil {
+				t.Errorf("Test case %d: Unexpected error while installing: %s", i, dbusErr)
+			}
+			continue
+		}
+
+		if dbusErr == nil || dbusErr.Name != "foo.Error.PolicyDenied" {
+			t.Errorf("Test case %d: Expected install to be denied by policy, got %v", i, dbusErr)
+		}
+
+		if requested := snapd.requested(); len(requested) != 0 {
+			t.Errorf("Test case %d: Got requests %v, expected none", i, requested)
+		}
+	}
+}
+
+// Test that batches are refused if any package isn't allowed.
+func TestSnapdInstallMany_policy(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	manager.policy = &policy.Policy{Deny: policy.Rules{Names: []string{"bar"}}}
+
+	_, dbusErr := manager.InstallMany(":1.42", []string{"foo", "bar"})
+	if dbusErr == nil || dbusErr.Name != "foo.Error.PolicyDenied" {
+		t.Errorf("Expected install to be denied by policy, got %v", dbusErr)
+	}
+
+	if requested := snapd.requested(); len(requested) != 0 {
+		t.Errorf("Got requests %v, expected none", requested)
+	}
+}
+
+// Test that installs are refused when the publisher can't be determined.
+func TestSnapdInstall_policyUnknownPublisher(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	snapd.failFindOne = true
+	manager.policy = &policy.Policy{Allow: policy.Rules{Publishers: []string{"canonical"}}}
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr == nil || dbusErr.Name != "foo.Error.PolicyDenied" {
+		t.Errorf("Expected install to be denied by policy, got %v", dbusErr)
+	}
+}
+
+// Test that callers can't cancel operations requested by others unless
+// they're allowed to.
+func TestSnapdCancel_otherCaller(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	manager.authority = &FakeAuthority{deniedAction: manageOthersAction}
+
+	manager.Install(":1.42", "foo")
+
+	dbusErr := manager.Cancel(":1.43", "foo")
+	if dbusErr == nil || dbusErr.Name != "org.freedesktop.DBus.Error.AccessDenied" {
+		t.Errorf("Expected access to be denied, got %v", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected the install not to be aborted`, requests)
+	}
+
+	// The owner is allowed to cancel it
+	dbusErr = manager.Cancel(":1.42", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the install to be aborted`, requests)
+	}
+}
+
+// Test that privileged callers can cancel operations requested by others.
+func TestSnapdCancel_otherCallerPrivileged(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	authority := new(FakeAuthority)
+	manager.authority = authority
+
+	manager.Install(":1.42", "foo")
+
+	dbusErr := manager.Cancel(":1.43", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	checks := authority.checks()
+	if checks[len(checks)-1] != ":1.43 "+manageOthersAction {
+		t.Errorf("Checks were %v, expected the caller to be checked for %s", checks, manageOthersAction)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the install to be aborted`, requests)
+	}
+}
+
+// Test that callers needing to authenticate to cancel operations requested by
+// others get their reply right away, while the operation is canceled once
+// they're done.
+func TestSnapdCancel_otherCallerChallenged(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	authority := &FakeAuthority{challengedAction: manageOthersAction}
+	manager.authority = authority
+
+	manager.Install(":1.42", "foo")
+
+	dbusErr := manager.Cancel(":1.43", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	waitUntil(t, func() bool { return len(snapd.requested()) == 2 },
+		"Expected the install to be aborted once the user authenticated")
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo", "abort 1"}) {
+		t.Errorf(`Requests were %v, expected the install to be aborted`, requests)
+	}
+
+	checks := authority.checks()
+	if checks[len(checks)-1] != ":1.43 "+manageOthersAction+" (interactive)" {
+		t.Errorf("Checks were %v, expected the user to be asked to authenticate", checks)
+	}
+}
+
+// Test that operations requested by others are left alone if the user fails to
+// authenticate.
+func TestSnapdCancel_otherCallerChallengeDenied(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	authority := &FakeAuthority{challengedAction: manageOthersAction, deniedAction: manageOthersAction}
+	manager.authority = authority
+
+	manager.Install(":1.42", "foo")
+
+	dbusErr := manager.Cancel(":1.43", "foo")
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error while canceling: %s", dbusErr)
+	}
+
+	waitUntil(t, func() bool { return len(authority.checks()) == 4 },
+		"Expected the user to be asked to authenticate")
+	time.Sleep(50 * time.Millisecond)
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected the install not to be aborted`, requests)
+	}
+}
+
+// Test that queued operations are dropped once their caller leaves the bus,
+// while those already started are left to complete.
+func TestSnapdCallerVanished(t *testing.T) {
+	manager, snapd := newQueueTestManager(t)
+	manager.configure(&Config{AbortOnDisconnect: true})
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+
+	running := manager.queues["foo"][0]
+	queued := manager.queues["foo"][1]
+
+	manager.callerVanished(":1.42")
+
+	if running.isCanceled() {
+		t.Error("Expected running operation not to be canceled")
+	}
+
+	status, _ := newOperationProperties(queued, "foo").Get("foo", "Status")
+	if status.Value() != operationStatusCanceled {
+		t.Errorf(`Status was %#v, expected "%s"`, status.Value(), operationStatusCanceled)
+	}
+
+	if requests := snapd.requested(); !reflect.DeepEqual(requests, []string{"install foo"}) {
+		t.Errorf(`Requests were %v, expected only "install foo"`, requests)
+	}
+}
+
+// Test that coalesced operations are only dropped once all their callers left
+// the bus.
+func TestSnapdCallerVanished_coalesced(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.configure(&Config{AbortOnDisconnect: true})
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.43", "foo")
+	manager.Uninstall(":1.44", "foo")
+
+	queued := manager.queues["foo"][1]
+
+	manager.callerVanished(":1.43")
+	if queued.isFinished() {
+		t.Error("Expected operation to remain queued while another caller owns it")
+	}
+
+	manager.callerVanished(":1.44")
+	if !queued.isFinished() {
+		t.Error("Expected operation to be dropped once all its callers left")
+	}
+}
+
+// Test that queued operations are kept by default once their caller leaves the
+// bus.
+func TestSnapdCallerVanished_disabled(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.43", "foo")
+
+	queued := manager.queues["foo"][1]
+
+	manager.callerVanished(":1.43")
+	if queued.isFinished() {
+		t.Error("Expected operation to remain queued")
+	}
+}
+
+// Test that callers leaving the bus are noticed.
+func TestSnapdWatchCallers(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.configure(&Config{AbortOnDisconnect: true})
+	server := manager.dbusConnection.(*FakeDbusServer)
+
+	err := manager.watchCallers()
+	if err != nil {
+		t.Fatalf("Unexpected error while watching callers: %s", err)
+	}
+
+	manager.Install(":1.42", "foo")
+	manager.Uninstall(":1.43", "foo")
+	manager.Refresh(":1.43", "bar")
+
+	// Only the callers are watched, each of them once
+	expected := []string{callerRule(":1.42"), callerRule(":1.43")}
+	if matches := server.addedMatches(); !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Match rules were %v, expected %v", matches, expected)
+	}
+
+	manager.queuesLock.Lock()
+	queued := manager.queues["foo"][1]
+	manager.queuesLock.Unlock()
+
+	// Well-known names changing owner must be ignored
+	server.receive(&dbus.Signal{Name: nameOwnerChangedSignal,
+		Body: []interface{}{"com.example.Foo", ":1.43", ""}})
+	server.receive(&dbus.Signal{Name: nameOwnerChangedSignal,
+		Body: []interface{}{":1.43", ":1.43", ""}})
+
+	waitUntil(t, queued.isFinished, "Expected operation to be dropped once its caller left")
+
+	waitUntil(t, func() bool {
+		return reflect.DeepEqual(server.addedMatches(), []string{callerRule(":1.42")})
+	}, "Expected the caller to no longer be watched once it left")
+}
+
+// Test that callers leaving the bus before they're watched are noticed.
+func TestSnapdWatchCallers_alreadyLeft(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.configure(&Config{AbortOnDisconnect: true})
+	server := manager.dbusConnection.(*FakeDbusServer)
+	server.leftBus = []string{":1.43"}
+
+	err := manager.watchCallers()
+	if err != nil {
+		t.Fatalf("Unexpected error while watching callers: %s", err)
+	}
+
+	manager.Install(":1.42", "foo")
+	objectPath, _ := manager.Uninstall(":1.43", "foo")
+
+	manager.operationsLock.Lock()
+	queued := manager.operations[strings.TrimPrefix(string(objectPath), "/foo/")]
+	manager.operationsLock.Unlock()
+
+	if queued == nil || !queued.isFinished() {
+		t.Error("Expected operation to be dropped since its caller already left")
+	}
+}
+
+// Test that signals keep being read while the queues are locked, so calls made
+// with them locked can still get their replies.
+func TestSnapdWatchCallers_queuesLocked(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	server := manager.dbusConnection.(*FakeDbusServer)
+
+	err := manager.watchCallers()
+	if err != nil {
+		t.Fatalf("Unexpected error while watching callers: %s", err)
+	}
+
+	manager.queuesLock.Lock()
+	defer manager.queuesLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		// Many more than the signals channel holds
+		for i := 0; i < 100; i++ {
+			server.receive(&dbus.Signal{Name: nameOwnerChangedSignal,
+				Body: []interface{}{fmt.Sprintf(":1.%d", i), fmt.Sprintf(":1.%d", i), ""}})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected signals to be read while the queues are locked")
+	}
+}
+
+// Test that failing to watch a caller doesn't fail its request.
+func TestSnapdWatchCallers_addMatchFailure(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.dbusConnection.(*FakeDbusServer).failAddMatch = true
+
+	err := manager.watchCallers()
+	if err != nil {
+		t.Fatalf("Unexpected error while watching callers: %s", err)
+	}
+
+	_, dbusErr := manager.Install(":1.42", "foo")
+	if dbusErr != nil {
+		t.Errorf("Unexpected error while installing: %s", dbusErr)
+	}
+}
+
+// Test that failing to subscribe to bus signals results in an error.
+func TestSnapdWatchCallers_subscribeFailure(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+	manager.dbusConnection.(*FakeDbusServer).failSubscribe = true
+
+	err := manager.watchCallers()
+	if err == nil {
+		t.Error("Expected an error due to failure to subscribe")
+	}
+}
+
+// Test that finished operations are recorded in the history, along with the
+// revisions before and after.
+func TestSnapdGetHistory(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	manager, snapd := newQueueTestManager(t)
+	journal, _ := loadHistory(filepath.Join(directory, "history.json"), 10)
+	manager.history = journal
+
+	snapd.setInstalled("foo", 1)
+
+	manager.Refresh(":1.42", "foo")
+	manager.Uninstall(":1.42", "foo")
+	newOperationControl(manager, manager.queues["foo"][1]).Cancel(":1.42")
+
+	snapd.setInstalled("foo", 2)
+	snapd.finishChange("1", "Done")
+
+	waitUntil(t, func() bool {
+		entries, _ := manager.GetHistory(0)
+		return len(entries) == 2
+	}, "Expected both operations to be recorded")
+
+	entries, dbusErr := manager.GetHistory(0)
+	if dbusErr != nil {
+		t.Fatalf("Unexpected error getting history: %s", dbusErr)
+	}
+
+	// The queued removal was dropped first, without changing anything
+	removal, refresh := entries[1], entries[0]
+	if removal.Kind != operationKindRemove || removal.Status != operationStatusCanceled ||
+		len(removal.RevisionsAfter) != 0 {
+		t.Errorf("Removal entry was %#v, expected it to be canceled", removal)
+	}
+
+	if refresh.Kind != operationKindRefresh || refresh.Status != operationStatusDone ||
+		!reflect.DeepEqual(refresh.PackageIds, []string{"foo"}) {
+		t.Errorf("Refresh entry was %#v, expected it to be done", refresh)
+	}
+
+	if refresh.RevisionsBefore["foo"] != "1" || refresh.RevisionsAfter["foo"] != "2" {
+		t.Errorf("Revisions were %v and %v, expected 1 and 2", refresh.RevisionsBefore,
+			refresh.RevisionsAfter)
+	}
+
+	entries, _ = manager.GetHistory(1)
+	if len(entries) != 1 || entries[0].Kind != operationKindRefresh {
+		t.Errorf("Entries were %#v, expected only the refresh", entries)
+	}
+}
+
+// Test that requests snapd refuses are recorded in the history.
+func TestSnapdGetHistory_refused(t *testing.T) {
+	directory, cleanup := historyDirectory(t)
+	defer cleanup()
+
+	manager, snapd := newQueueTestManager(t)
+	journal, _ := loadHistory(filepath.Join(directory, "history.json"), 10)
+	manager.history = journal
+
+	snapd.failInstall = true
+
+	manager.Install(":1.42", "foo")
+
+	entries, _ := manager.GetHistory(0)
+	if len(entries) != 1 || entries[0].Status != operationStatusError || entries[0].Error == "" {
+		t.Errorf("Entries were %#v, expected the install to have failed", entries)
+	}
+
+	if len(entries) == 1 && entries[0].Id == "" {
+		t.Error("Expected the refused install to have an ID")
+	}
+}
+
+// Test that there's no history unless a journal was opened.
+func TestSnapdGetHistory_noJournal(t *testing.T) {
+	manager, _ := newQueueTestManager(t)
+
+	manager.Install(":1.42", "foo")
+	manager.Cancel(":1.42", "foo")
+
+	entries, dbusErr := manager.GetHistory(0)
+	if dbusErr != nil || len(entries) != 0 {
+		t.Errorf("Got entries %#v and error %v, expected none", entries, dbusErr)
+	}
+}
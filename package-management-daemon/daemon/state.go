@@ -0,0 +1,89 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// stateDirectory determines where the daemon keeps the state that outlives
+// it, following the XDG base directory specification.
+//
+// Returns:
+// - Path to the state directory.
+func stateDirectory() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+
+	return filepath.Join(stateHome, "unity-scope-snappy")
+}
+
+// writeFileAtomically replaces a file with new contents, so a crash never
+// leaves it half written. The contents are synced to disk before they replace
+// the file, and the directory holding it is created as needed.
+//
+// Parameters:
+// path: Path to the file.
+// write: Function writing the new contents.
+//
+// Returns:
+// - Error (nil if none)
+func writeFileAtomically(path string, write func(writer io.Writer) error) error {
+	directory := filepath.Dir(path)
+
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return fmt.Errorf(`Unable to create directory "%s": %s`, directory, err)
+	}
+
+	file, err := ioutil.TempFile(directory, "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("Unable to create temporary file: %s", err)
+	}
+	defer os.Remove(file.Name())
+
+	writer := bufio.NewWriter(file)
+	err = write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf(`Unable to write "%s": %s`, path, err)
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf(`Unable to replace "%s": %s`, path, err)
+	}
+
+	return nil
+}
@@ -1,19 +1,65 @@
 package main
 
 import (
+	"flag"
 	"launchpad.net/unity-scope-snappy/package-management-daemon/daemon"
+	"launchpad.net/unity-scope-snappy/policy"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// idleTimeout is how long the daemon may sit unused before exiting. Since it's
+// DBus activated, it will simply be restarted upon the next call.
+var idleTimeout = flag.Duration("idle-timeout", 5*time.Minute,
+	"Exit after being idle for this long (0 to never exit)")
+
+// configPath is the path to the daemon configuration file.
+var configPath = flag.String("config", daemon.DefaultConfigPath(),
+	"Path to the configuration file")
+
+// historyPath is the path to the journal finished operations are recorded in.
+var historyPath = flag.String("history", daemon.DefaultHistoryPath(),
+	"Path to the history of finished operations")
+
+// operationsPath is the path to the journal remembering the object paths of
+// queued operations.
+var operationsPath = flag.String("operations", daemon.DefaultOperationsPath(),
+	"Path to the object paths of queued operations in progress")
+
+// policyPath is the path to the system-wide policy file deciding which snaps
+// may be installed.
+var policyPath = flag.String("policy", policy.DefaultPath,
+	"Path to the policy file deciding which snaps may be installed")
+
 // main is the entry point of the daemon
 func main() {
+	flag.Parse()
+
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	daemon, err := daemon.New()
+	config, err := daemon.LoadConfig(*configPath)
+	if err != nil {
+		log.Printf("package-management-daemon: Using default configuration: %s", err)
+		config = daemon.DefaultConfig()
+	}
+
+	// Refuse to run rather than ignore a policy that can't be read
+	installPolicy, err := policy.Load(*policyPath)
+	if err != nil {
+		log.Fatalf("Unable to load policy: %s", err)
+	}
+
+	daemon, err := daemon.New(daemon.Options{
+		Config:         config,
+		Policy:         installPolicy,
+		HistoryPath:    *historyPath,
+		OperationsPath: *operationsPath,
+		IdleTimeout:    *idleTimeout,
+	})
 	if err != nil {
 		log.Fatalf("Unable to create daemon: %s", err)
 	}
@@ -23,5 +69,14 @@ func main() {
 		log.Printf("package-management-daemon: Error running daemon: %s", err)
 	}
 
-	<-signals // Block here so the daemon can run, exiting if a signal comes in.
+	// Block here so the daemon can run, exiting if a signal comes in or once
+	// it's no longer in use.
+	select {
+	case <-signals:
+	case <-daemon.Idle():
+		err = daemon.Stop()
+		if err != nil {
+			log.Printf("package-management-daemon: Error stopping daemon: %s", err)
+		}
+	}
 }
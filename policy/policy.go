@@ -0,0 +1,186 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package policy decides which snaps may be installed on managed devices,
+// based upon a system-wide allowlist and denylist.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// DefaultPath is where the system-wide policy file lives.
+const DefaultPath = "/etc/unity-scope-snappy/policy.json"
+
+// Rules lists the snaps a rule applies to, by name or by publisher. Both
+// support glob patterns, e.g. "foo-*".
+type Rules struct {
+	Names      []string `json:"names"`
+	Publishers []string `json:"publishers"`
+}
+
+// Policy decides which snaps may be installed. Snaps matching the denylist are
+// never allowed. If the allowlist isn't empty, only snaps matching it are
+// allowed.
+type Policy struct {
+	Allow Rules `json:"allow"`
+	Deny  Rules `json:"deny"`
+}
+
+// DeniedError is returned when the policy doesn't allow a snap.
+type DeniedError struct {
+	Name   string // Name of the snap
+	Reason string // Why the snap isn't allowed
+}
+
+func (err *DeniedError) Error() string {
+	return fmt.Sprintf(`Snap "%s" is not allowed by policy: %s`, err.Name, err.Reason)
+}
+
+// Load reads the policy file at the given path. A missing file results in a
+// policy allowing every snap.
+//
+// Parameters:
+// path: Path to the policy file.
+//
+// Returns:
+// - Pointer to the loaded Policy (nil if error)
+// - Error (nil if none)
+func Load(path string) (*Policy, error) {
+	policy := new(Policy)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return policy, nil
+		}
+
+		return nil, fmt.Errorf(`Unable to open policy file "%s": %s`, path, err)
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(policy)
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to parse policy file "%s": %s`, path, err)
+	}
+
+	err = policy.validate()
+	if err != nil {
+		return nil, fmt.Errorf(`Invalid policy file "%s": %s`, path, err)
+	}
+
+	return policy, nil
+}
+
+// Check determines whether the policy allows a snap. A nil policy allows
+// every snap.
+//
+// Parameters:
+// name: Name of the snap.
+// publisher: Publisher of the snap (may be empty if unknown).
+//
+// Returns:
+// - Error (nil if allowed, *DeniedError otherwise)
+func (policy *Policy) Check(name string, publisher string) error {
+	if policy == nil {
+		return nil
+	}
+
+	if reason, ok := policy.Deny.match(name, publisher); ok {
+		return &DeniedError{Name: name, Reason: "denied by " + reason}
+	}
+
+	if policy.Allow.empty() {
+		return nil
+	}
+
+	if _, ok := policy.Allow.match(name, publisher); !ok {
+		return &DeniedError{Name: name, Reason: "not in the allowlist"}
+	}
+
+	return nil
+}
+
+// HasPublisherRules checks whether the policy depends upon publishers, in
+// which case they need to be known when checking snaps.
+//
+// Returns:
+// - Whether or not any rule is about publishers.
+func (policy *Policy) HasPublisherRules() bool {
+	if policy == nil {
+		return false
+	}
+
+	return len(policy.Allow.Publishers) > 0 || len(policy.Deny.Publishers) > 0
+}
+
+// validate makes sure every pattern of the policy is well-formed.
+//
+// Returns:
+// - Error (nil if none)
+func (policy *Policy) validate() error {
+	for _, rules := range []Rules{policy.Allow, policy.Deny} {
+		for _, pattern := range append(rules.Names, rules.Publishers...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf(`Bad pattern "%s": %s`, pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// match checks whether a snap matches any of the rules.
+//
+// Parameters:
+// name: Name of the snap.
+// publisher: Publisher of the snap (may be empty if unknown).
+//
+// Returns:
+// - Description of the matching rule.
+// - Whether or not a rule matched.
+func (rules Rules) match(name string, publisher string) (string, bool) {
+	for _, pattern := range rules.Names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return fmt.Sprintf(`name "%s"`, pattern), true
+		}
+	}
+
+	if publisher == "" {
+		return "", false
+	}
+
+	for _, pattern := range rules.Publishers {
+		if matched, _ := path.Match(pattern, publisher); matched {
+			return fmt.Sprintf(`publisher "%s"`, pattern), true
+		}
+	}
+
+	return "", false
+}
+
+// empty checks whether there are no rules at all.
+//
+// Returns:
+// - Whether or not the rules are empty.
+func (rules Rules) empty() bool {
+	return len(rules.Names) == 0 && len(rules.Publishers) == 0
+}
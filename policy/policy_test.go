@@ -0,0 +1,147 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePolicy writes a policy file into a temporary directory.
+func writePolicy(t *testing.T, contents string) (string, func()) {
+	directory, err := ioutil.TempDir("", "policy_test")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %s", err)
+	}
+
+	path := filepath.Join(directory, "policy.json")
+	err = ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write policy file: %s", err)
+	}
+
+	return path, func() { os.RemoveAll(directory) }
+}
+
+// Data for TestPolicy_check
+var checkTests = []struct {
+	policy    *Policy
+	name      string
+	publisher string
+	allowed   bool
+}{
+	// No policy at all
+	{nil, "foo", "bar", true},
+	{&Policy{}, "foo", "bar", true},
+
+	// Denylist only
+	{&Policy{Deny: Rules{Names: []string{"foo"}}}, "foo", "bar", false},
+	{&Policy{Deny: Rules{Names: []string{"foo"}}}, "baz", "bar", true},
+	{&Policy{Deny: Rules{Names: []string{"game-*"}}}, "game-chess", "bar", false},
+	{&Policy{Deny: Rules{Publishers: []string{"bar"}}}, "foo", "bar", false},
+	{&Policy{Deny: Rules{Publishers: []string{"bar"}}}, "foo", "", true},
+
+	// Allowlist only
+	{&Policy{Allow: Rules{Names: []string{"foo"}}}, "foo", "bar", true},
+	{&Policy{Allow: Rules{Names: []string{"foo"}}}, "baz", "bar", false},
+	{&Policy{Allow: Rules{Publishers: []string{"canonical"}}}, "foo", "canonical", true},
+	{&Policy{Allow: Rules{Publishers: []string{"canonical"}}}, "foo", "bar", false},
+
+	// The denylist wins
+	{&Policy{Allow: Rules{Publishers: []string{"canonical"}}, Deny: Rules{Names: []string{"foo"}}}, "foo", "canonical", false},
+}
+
+// Test that snaps are checked against both lists.
+func TestPolicy_check(t *testing.T) {
+	for i, test := range checkTests {
+		err := test.policy.Check(test.name, test.publisher)
+		if test.allowed && err != nil {
+			t.Errorf("Test case %d: Unexpected denial: %s", i, err)
+		}
+
+		if !test.allowed {
+			if _, ok := err.(*DeniedError); !ok {
+				t.Errorf("Test case %d: Expected a *DeniedError, got %v", i, err)
+			}
+		}
+	}
+}
+
+// Test typical Load usage.
+func TestLoad(t *testing.T) {
+	path, cleanup := writePolicy(t, `{
+		"allow": {"publishers": ["canonical"]},
+		"deny": {"names": ["game-*"]}
+	}`)
+	defer cleanup()
+
+	policy, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading policy: %s", err)
+	}
+
+	if !policy.HasPublisherRules() {
+		t.Error("Expected policy to have publisher rules")
+	}
+
+	if policy.Check("foo", "canonical") != nil {
+		t.Error("Expected snaps from canonical to be allowed")
+	}
+
+	if policy.Check("game-chess", "canonical") == nil {
+		t.Error("Expected games to be denied")
+	}
+}
+
+// Test that a missing policy file allows every snap.
+func TestLoad_missingFile(t *testing.T) {
+	policy, err := Load("/does/not/exist.json")
+	if err != nil {
+		t.Fatalf("Unexpected error loading policy: %s", err)
+	}
+
+	if policy.Check("foo", "bar") != nil {
+		t.Error("Expected every snap to be allowed")
+	}
+
+	if policy.HasPublisherRules() {
+		t.Error("Expected policy not to have publisher rules")
+	}
+}
+
+// Data for TestLoad_invalidFile
+var invalidPolicyTests = []string{
+	"invalid",
+	`{"deny": {"names": ["foo["]}}`,
+}
+
+// Test that an invalid policy file results in an error.
+func TestLoad_invalidFile(t *testing.T) {
+	for i, contents := range invalidPolicyTests {
+		path, cleanup := writePolicy(t, contents)
+		defer cleanup()
+
+		_, err := Load(path)
+		if err == nil {
+			t.Errorf("Test case %d: Expected an error due to invalid policy file", i)
+		}
+	}
+}
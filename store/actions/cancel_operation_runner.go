@@ -0,0 +1,74 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"fmt"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages"
+)
+
+// CancelOperationRunner is an action Runner to handle the cancellation of an
+// install, uninstall or refresh operation in progress on a specific package.
+type CancelOperationRunner struct {
+	metadata operation.Metadata // Metadata of the operation's preview
+}
+
+// NewCancelOperationRunner creates a new CancelOperationRunner.
+//
+// Returns:
+// - Pointer to new CancelOperationRunner.
+// - Error (nil if none).
+func NewCancelOperationRunner() (*CancelOperationRunner, error) {
+	return new(CancelOperationRunner), nil
+}
+
+// SetMetadata records which operation the preview is following.
+//
+// Parameters:
+// metadata: Metadata of the preview showing the operation.
+func (runner *CancelOperationRunner) SetMetadata(metadata operation.Metadata) {
+	runner.metadata = metadata
+}
+
+// Run cancels the operation followed by the preview, or the operation in
+// progress on the snap with the given ID if the preview doesn't know which.
+//
+// Parameters:
+// packageManager: Package manager to use for canceling the operation.
+// snapId: ID of the snap whose operation should be canceled.
+//
+// Return:
+// - Pointer to an ActivationResponse for showing the preview.
+// - Error (nil if none).
+func (runner CancelOperationRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
+	var err error
+	if runner.metadata.ObjectPath != "" {
+		err = packageManager.CancelOperation(runner.metadata.ObjectPath)
+	} else {
+		err = packageManager.Cancel(snapId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to cancel operation on package with ID "%s": %s`, snapId, err)
+	}
+
+	// No operation metadata, so the preview reflects the package's state.
+	return scopes.NewActivationResponse(scopes.ActivationShowPreview), nil
+}
@@ -0,0 +1,88 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages/fakes"
+	"testing"
+)
+
+// Test typical Run usage.
+func TestCancelOperationRunner_run(t *testing.T) {
+	actionRunner, _ := NewCancelOperationRunner()
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if !packageManager.CancelCalled {
+		t.Error("Expected package manager Cancel() function to be called")
+	}
+
+	if response.Status != scopes.ActivationShowPreview {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationShowPreview)
+	}
+
+	if response.ScopeData != nil {
+		t.Error("Expected no scope data")
+	}
+}
+
+// Test that the operation followed by the preview is canceled via its object
+// path.
+func TestCancelOperationRunner_run_objectPath(t *testing.T) {
+	actionRunner, _ := NewCancelOperationRunner()
+	actionRunner.SetMetadata(operation.Metadata{InstallRequested: true, ObjectPath: "/foo/1"})
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	_, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if !packageManager.CancelOperationCalled {
+		t.Error("Expected package manager CancelOperation() function to be called")
+	}
+
+	if packageManager.CancelCalled {
+		t.Error("Expected package manager Cancel() function not to be called")
+	}
+}
+
+// Test that a failure to cancel results in an error
+func TestCancelOperationRunner_run_cancelFailure(t *testing.T) {
+	actionRunner, _ := NewCancelOperationRunner()
+
+	packageManager := &fakes.FakeDbusManager{FailCancel: true}
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err == nil {
+		t.Error("Expected an error due to failure to cancel")
+	}
+	if response != nil {
+		t.Error("Expected response to be nil")
+	}
+}
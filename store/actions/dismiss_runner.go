@@ -0,0 +1,50 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/packages"
+)
+
+// DismissRunner is an action Runner to handle the failure of an operation being
+// dismissed.
+type DismissRunner struct{}
+
+// NewDismissRunner creates a new DismissRunner.
+//
+// Returns:
+// - Pointer to new DismissRunner.
+// - Error (nil if none).
+func NewDismissRunner() (*DismissRunner, error) {
+	return new(DismissRunner), nil
+}
+
+// Run simply refreshes the preview, leaving the failure behind.
+//
+// Parameters:
+// stateManager: Package state manager (not used).
+// snapId: ID of the specific snap (not used).
+//
+// Return:
+// - Pointer to an ActivationResponse for showing the preview.
+// - Error (nil if none).
+func (runner DismissRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
+	return scopes.NewActivationResponse(scopes.ActivationShowPreview), nil
+}
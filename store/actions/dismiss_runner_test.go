@@ -0,0 +1,47 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages/fakes"
+	"testing"
+)
+
+// Test typical Run usage.
+func TestDismissRunner_run(t *testing.T) {
+	runner, _ := NewDismissRunner()
+
+	response, err := runner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err != nil {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if response.Status != scopes.ActivationShowPreview {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationShowPreview)
+	}
+
+	// Verify lack of operation metadata, so the failure is forgotten
+	_, ok := response.ScopeData.(operation.Metadata)
+	if ok {
+		t.Error("Response ScopeData should not include operation metadata")
+	}
+}
@@ -24,9 +24,15 @@ import (
 	"launchpad.net/unity-scope-snappy/store/packages"
 )
 
+// failureHistoryLimit is the number of history entries searched for the
+// operation that failed.
+const failureHistoryLimit = 10
+
 // FailedRunner is an action Runner to handle a failed install or uninstall
 // operation.
-type FailedRunner struct{}
+type FailedRunner struct {
+	retryCount int // Number of times the operation had already been retried
+}
 
 // NewFailedRunner creates a new FailedRunner.
 //
@@ -37,11 +43,21 @@ func NewFailedRunner() (*FailedRunner, error) {
 	return new(FailedRunner), nil
 }
 
-// Run shoves the failed state into the metadata to be passed to the preview.
+// SetMetadata keeps track of how many times the operation had been retried, so
+// the limit still applies to the next retry.
+//
+// Parameters:
+// metadata: Metadata of the preview showing the operation's progress.
+func (runner *FailedRunner) SetMetadata(metadata operation.Metadata) {
+	runner.retryCount = metadata.RetryCount
+}
+
+// Run shoves the failed state into the metadata to be passed to the preview,
+// along with the reason for the failure as recorded by the daemon.
 //
 // Parameters:
-// stateManager: Package state manager (not used).
-// snapId: ID of the snap upon which the operation just had an error (not used).
+// stateManager: Package state manager to use for finding out about the failure.
+// snapId: ID of the snap upon which the operation just had an error.
 //
 // Return:
 // - Pointer to an ActivationResponse for showing the preview.
@@ -49,7 +65,43 @@ func NewFailedRunner() (*FailedRunner, error) {
 func (runner FailedRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
 	response := scopes.NewActivationResponse(scopes.ActivationShowPreview)
 
-	response.SetScopeData(operation.Metadata{Failed: true})
+	metadata := operation.Metadata{
+		Failed:     true,
+		RetryCount: runner.retryCount,
+	}
+
+	// Failing to find out why just means the preview can't explain it
+	if entry := lastOperation(packageManager, snapId); entry != nil && entry.Status == "error" {
+		metadata.FailedOperation = entry.Kind
+		metadata.ErrorMessage = entry.Error
+	}
+
+	response.SetScopeData(metadata)
 
 	return response, nil
 }
+
+// lastOperation finds the latest operation on a snap in the daemon's history.
+//
+// Parameters:
+// packageManager: Package manager holding the history.
+// snapId: ID of the snap.
+//
+// Returns:
+// - History entry of the operation (nil if none or unknown).
+func lastOperation(packageManager packages.DbusManager, snapId string) *operation.HistoryEntry {
+	history, err := packageManager.GetHistory(failureHistoryLimit)
+	if err != nil {
+		return nil
+	}
+
+	for i, entry := range history {
+		for _, packageId := range entry.PackageIds {
+			if packageId == snapId {
+				return &history[i]
+			}
+		}
+	}
+
+	return nil
+}
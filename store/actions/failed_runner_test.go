@@ -50,3 +50,54 @@ func TestFailedRunner_run(t *testing.T) {
 		t.Errorf("Expected metadata to indicate that the operation failed")
 	}
 }
+
+// Test that the reason for the failure is carried into the metadata.
+func TestFailedRunner_run_errorMessage(t *testing.T) {
+	actionRunner, _ := NewFailedRunner()
+	packageManager := &fakes.FakeDbusManager{History: []operation.HistoryEntry{
+		{Kind: "remove", PackageIds: []string{"bar"}, Status: "done"},
+		{Kind: "install", PackageIds: []string{"foo"}, Status: "error", Error: "Out of space"},
+		{Kind: "install", PackageIds: []string{"foo"}, Status: "error", Error: "Older failure"},
+	}}
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	metadata := response.ScopeData.(operation.Metadata)
+	if !metadata.Failed || metadata.FailedOperation != "install" || metadata.ErrorMessage != "Out of space" {
+		t.Errorf("Metadata was %#v, expected the latest install failure", metadata)
+	}
+}
+
+// Test that failing to get the history still reports the failure.
+func TestFailedRunner_run_historyFailure(t *testing.T) {
+	actionRunner, _ := NewFailedRunner()
+
+	response, err := actionRunner.Run(&fakes.FakeDbusManager{FailGetHistory: true}, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	metadata := response.ScopeData.(operation.Metadata)
+	if !metadata.Failed || metadata.ErrorMessage != "" {
+		t.Errorf("Metadata was %#v, expected a failure without reason", metadata)
+	}
+}
+
+// Test that the retry count is carried over, so the retry limit holds.
+func TestFailedRunner_run_retryCount(t *testing.T) {
+	actionRunner, _ := NewFailedRunner()
+	actionRunner.SetMetadata(operation.Metadata{InstallRequested: true, RetryCount: 2})
+
+	response, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	metadata := response.ScopeData.(operation.Metadata)
+	if metadata.RetryCount != 2 {
+		t.Errorf("Metadata retry count was %d, expected 2", metadata.RetryCount)
+	}
+}
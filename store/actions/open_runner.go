@@ -20,12 +20,18 @@ package actions
 
 import (
 	"fmt"
+	"github.com/snapcore/snapd/client"
 	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
 	"launchpad.net/unity-scope-snappy/store/packages"
 )
 
 // OpenRunner is an action Runner to handle the launch of a specific snap.
-type OpenRunner struct{}
+type OpenRunner struct {
+	snapdClient packages.WebdmManager  // Used to find out about the snap's apps
+	dispatcher  packages.UrlDispatcher // Used to open the chosen app
+	appName     string                 // App picked by the user, if any
+}
 
 // NewOpenRunner creates a new OpenRunner
 //
@@ -36,15 +42,98 @@ func NewOpenRunner() (*OpenRunner, error) {
 	return new(OpenRunner), nil
 }
 
-// Run is where a snap would be launched, if such a thing were supported.
+// NewOpenAppRunner creates a new OpenRunner opening a specific app of the
+// snap, as picked by the user.
+//
+// Parameters:
+// appName: Name of the app to open.
+//
+// Returns:
+// - Pointer to new OpenRunner
+// - Error (nil if none)
+func NewOpenAppRunner(appName string) (*OpenRunner, error) {
+	return &OpenRunner{appName: appName}, nil
+}
+
+// SetLauncher provides the means of launching the snap.
+//
+// Parameters:
+// snapdClient: Client used to find out about the snap's apps.
+// dispatcher: Dispatcher used to open the chosen app.
+func (runner *OpenRunner) SetLauncher(snapdClient packages.WebdmManager, dispatcher packages.UrlDispatcher) {
+	runner.snapdClient = snapdClient
+	runner.dispatcher = dispatcher
+}
+
+// Run launches the app of the snap with the given ID. If the snap has several
+// apps and none was picked yet, the user is asked to pick one instead.
 //
 // Parameters:
 // packageManager: Package manager (not used).
-// snapId: ID of the snap to launch (not used).
+// snapId: ID of the snap to launch.
 //
 // Return:
-// - A nil pointer to an ActivationResponse
-// - An error saying that this isn't supported (yet).
+// - Pointer to an ActivationResponse for hiding the dash or picking an app.
+// - Error (nil if none).
 func (runner OpenRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
-	return nil, fmt.Errorf(`Unable to open package with ID "%s": Opening snaps is not yet supported`, snapId)
+	if runner.snapdClient == nil || runner.dispatcher == nil {
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": No launcher available`, snapId)
+	}
+
+	snap, err := runner.snapdClient.Query(snapId)
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": %s`, snapId, err)
+	}
+
+	if snap.Status != client.StatusInstalled && snap.Status != client.StatusActive {
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": Package is not installed`, snapId)
+	}
+
+	apps := packages.LaunchableApps(*snap)
+
+	if runner.appName != "" {
+		for _, app := range apps {
+			if app.Name == runner.appName {
+				return runner.open(snapId, *snap, app)
+			}
+		}
+
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": Package has no app named "%s"`, snapId, runner.appName)
+	}
+
+	switch len(apps) {
+	case 0:
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": Package has no app to open`, snapId)
+	case 1:
+		return runner.open(snapId, *snap, apps[0])
+	}
+
+	response := scopes.NewActivationResponse(scopes.ActivationShowPreview)
+
+	metadata := operation.Metadata{
+		AppPickerRequested: true,
+	}
+
+	response.SetScopeData(metadata)
+
+	return response, nil
+}
+
+// open launches a specific app of a snap.
+//
+// Parameters:
+// snapId: ID of the snap the app belongs to.
+// snap: Snap the app belongs to.
+// app: App to launch.
+//
+// Return:
+// - Pointer to an ActivationResponse for hiding the dash.
+// - Error (nil if none).
+func (runner OpenRunner) open(snapId string, snap client.Snap, app client.AppInfo) (*scopes.ActivationResponse, error) {
+	err := runner.dispatcher.DispatchUrl(packages.AppUri(snap, app))
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to open package with ID "%s": %s`, snapId, err)
+	}
+
+	return scopes.NewActivationResponse(scopes.ActivationHideDash), nil
 }
@@ -19,16 +19,183 @@
 package actions
 
 import (
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
 	"launchpad.net/unity-scope-snappy/store/packages/fakes"
 	"testing"
 )
 
-// Test typical Run usage.
-func TestOpenActionRunnerRun(t *testing.T) {
+// Test that a snap with a single app has it opened.
+func TestOpenRunner_run(t *testing.T) {
+	actionRunner, _ := NewOpenRunner()
+
+	snapdClient := &fakes.FakeWebdmManager{Snaps: map[string]client.Snap{
+		"foo": {Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{
+			{Name: "foo", DesktopFile: "/var/lib/snapd/desktop/applications/foo_foo.desktop"},
+			{Name: "foo-daemon", Daemon: "simple"},
+		}},
+	}}
+	dispatcher := &fakes.FakeUrlDispatcher{}
+	actionRunner.SetLauncher(snapdClient, dispatcher)
+
+	response, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err != nil {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if response.Status != scopes.ActivationHideDash {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationHideDash)
+	}
+
+	if dispatcher.Uri != "application:///foo_foo.desktop" {
+		t.Errorf(`Dispatched URI was "%s", expected "application:///foo_foo.desktop"`, dispatcher.Uri)
+	}
+}
+
+// Test that a snap with several apps has the user pick one.
+func TestOpenRunner_run_severalApps(t *testing.T) {
+	actionRunner, _ := NewOpenRunner()
+
+	snapdClient := &fakes.FakeWebdmManager{Snaps: map[string]client.Snap{
+		"foo": {Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{
+			{Name: "foo"},
+			{Name: "bar"},
+		}},
+	}}
+	dispatcher := &fakes.FakeUrlDispatcher{}
+	actionRunner.SetLauncher(snapdClient, dispatcher)
+
+	response, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if dispatcher.DispatchUrlCalled {
+		t.Error("Expected no app to be opened before the user picks one")
+	}
+
+	if response.Status != scopes.ActivationShowPreview {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationShowPreview)
+	}
+
+	metadata, ok := response.ScopeData.(operation.Metadata)
+	if !ok {
+		t.Fatalf("Expected response ScopeData to include operation metadata")
+	}
+
+	if !metadata.AppPickerRequested {
+		t.Errorf("Expected metadata to indicate that the app picker was requested")
+	}
+}
+
+// Test that the app picked by the user is opened.
+func TestOpenRunner_run_pickedApp(t *testing.T) {
+	actionRunner, err := NewRunner(OpenAppAction("bar"))
+	if err != nil {
+		t.Fatalf("Unexpected error when creating runner: %s", err)
+	}
+
+	launcherRunner, ok := actionRunner.(LauncherRunner)
+	if !ok {
+		t.Fatal("Expected runner to be a LauncherRunner")
+	}
+
+	snapdClient := &fakes.FakeWebdmManager{Snaps: map[string]client.Snap{
+		"foo": {Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{
+			{Name: "foo", DesktopFile: "/var/lib/snapd/desktop/applications/foo_foo.desktop"},
+			{Name: "bar", DesktopFile: "/var/lib/snapd/desktop/applications/foo_bar.desktop"},
+		}},
+	}}
+	dispatcher := &fakes.FakeUrlDispatcher{}
+	launcherRunner.SetLauncher(snapdClient, dispatcher)
+
+	response, err := launcherRunner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err != nil {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if response.Status != scopes.ActivationHideDash {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationHideDash)
+	}
+
+	if dispatcher.Uri != "application:///foo_bar.desktop" {
+		t.Errorf(`Dispatched URI was "%s", expected "application:///foo_bar.desktop"`, dispatcher.Uri)
+	}
+}
+
+// Test that picking an app the snap doesn't have results in an error.
+func TestOpenRunner_run_unknownApp(t *testing.T) {
+	actionRunner, _ := NewOpenAppRunner("baz")
+
+	snapdClient := &fakes.FakeWebdmManager{Snaps: map[string]client.Snap{
+		"foo": {Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{
+			{Name: "foo"},
+			{Name: "bar"},
+		}},
+	}}
+	dispatcher := &fakes.FakeUrlDispatcher{}
+	actionRunner.SetLauncher(snapdClient, dispatcher)
+
+	response, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
+	if err == nil {
+		t.Error("Expected an error due to unknown app")
+	}
+	if response != nil {
+		t.Error("Expected response to be nil")
+	}
+
+	if dispatcher.DispatchUrlCalled {
+		t.Error("Expected no app to be opened")
+	}
+}
+
+// Data for TestOpenRunner_run_failure
+var openRunnerFailureTests = []struct {
+	snap         client.Snap
+	failQuery    bool
+	failDispatch bool
+}{
+	// Not installed
+	{client.Snap{Name: "foo", Status: client.StatusAvailable, Apps: []client.AppInfo{{Name: "foo"}}}, false, false},
+
+	// No app to open
+	{client.Snap{Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{{Name: "foo", Daemon: "simple"}}}, false, false},
+
+	// Unable to query snapd
+	{client.Snap{Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{{Name: "foo"}}}, true, false},
+
+	// Unable to dispatch
+	{client.Snap{Name: "foo", Status: client.StatusActive, Apps: []client.AppInfo{{Name: "foo"}}}, false, true},
+}
+
+// Test that failing to open the snap results in an error.
+func TestOpenRunner_run_failure(t *testing.T) {
+	for i, test := range openRunnerFailureTests {
+		actionRunner, _ := NewOpenRunner()
+		actionRunner.SetLauncher(&fakes.FakeWebdmManager{
+			Snaps:     map[string]client.Snap{"foo": test.snap},
+			FailQuery: test.failQuery,
+		}, &fakes.FakeUrlDispatcher{FailDispatchUrl: test.failDispatch})
+
+		response, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
+		if err == nil {
+			t.Errorf("Test case %d: Expected an error", i)
+		}
+		if response != nil {
+			t.Errorf("Test case %d: Expected response to be nil", i)
+		}
+	}
+}
+
+// Test that opening without a launcher results in an error.
+func TestOpenRunner_run_noLauncher(t *testing.T) {
 	actionRunner, _ := NewOpenRunner()
 
 	_, err := actionRunner.Run(&fakes.FakeDbusManager{}, "foo")
 	if err == nil {
-		t.Error("Expected an error due to opening not being supported")
+		t.Error("Expected an error due to missing launcher")
 	}
 }
@@ -0,0 +1,66 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"fmt"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages"
+)
+
+// RefreshAllRunner is an action Runner to handle the update of all installed
+// packages that have updates available.
+type RefreshAllRunner struct{}
+
+// NewRefreshAllRunner creates a new RefreshAllRunner.
+//
+// Returns:
+// - Pointer to new RefreshAllRunner.
+// - Error (nil if none).
+func NewRefreshAllRunner() (*RefreshAllRunner, error) {
+	return new(RefreshAllRunner), nil
+}
+
+// Run updates all installed snaps that have updates available.
+//
+// Parameters:
+// packageManager: Package manager to use for refreshing the snaps.
+// snapId: ID of the snap (not used).
+//
+// Return:
+// - Pointer to an ActivationResponse for showing the preview.
+// - Error (nil if none).
+func (runner RefreshAllRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
+	objectPath, err := packageManager.RefreshAll()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to refresh packages: %s", err)
+	}
+
+	response := scopes.NewActivationResponse(scopes.ActivationShowPreview)
+
+	metadata := operation.Metadata{
+		RefreshRequested: true,
+		ObjectPath:       objectPath,
+	}
+
+	response.SetScopeData(metadata)
+
+	return response, nil
+}
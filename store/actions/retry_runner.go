@@ -0,0 +1,94 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"fmt"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages"
+)
+
+// RetryLimit is the number of times a failed operation may be retried.
+const RetryLimit = 3
+
+// RetryRunner is an action Runner to handle retrying a failed operation upon a
+// specific package.
+type RetryRunner struct {
+	metadata operation.Metadata // Metadata of the failed operation's preview
+}
+
+// NewRetryRunner creates a new RetryRunner.
+//
+// Returns:
+// - Pointer to new RetryRunner.
+// - Error (nil if none).
+func NewRetryRunner() (*RetryRunner, error) {
+	return new(RetryRunner), nil
+}
+
+// SetMetadata records which operation failed, and how many times it has been
+// retried.
+//
+// Parameters:
+// metadata: Metadata of the preview showing the failure.
+func (runner *RetryRunner) SetMetadata(metadata operation.Metadata) {
+	runner.metadata = metadata
+}
+
+// Run replays the failed operation upon the snap with the given ID.
+//
+// Parameters:
+// packageManager: Package manager to use for replaying the operation.
+// snapId: ID of the snap upon which the operation failed.
+//
+// Return:
+// - Pointer to an ActivationResponse for showing the preview.
+// - Error (nil if none).
+func (runner RetryRunner) Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error) {
+	if runner.metadata.RetryCount >= RetryLimit {
+		return nil, fmt.Errorf(`Operation on package with ID "%s" was already retried %d times`, snapId, runner.metadata.RetryCount)
+	}
+
+	metadata := operation.Metadata{RetryCount: runner.metadata.RetryCount + 1}
+
+	var err error
+	switch runner.metadata.FailedOperation {
+	case "install":
+		metadata.InstallRequested = true
+		metadata.ObjectPath, err = packageManager.Install(snapId)
+	case "remove":
+		metadata.UninstallConfirmed = true
+		metadata.ObjectPath, err = packageManager.Uninstall(snapId)
+	case "refresh":
+		metadata.RefreshRequested = true
+		metadata.ObjectPath, err = packageManager.Refresh(snapId)
+	default:
+		return nil, fmt.Errorf(`Unable to retry unknown operation "%s" on package with ID "%s"`, runner.metadata.FailedOperation, snapId)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(`Unable to retry operation "%s" on package with ID "%s": %s`, runner.metadata.FailedOperation, snapId, err)
+	}
+
+	response := scopes.NewActivationResponse(scopes.ActivationShowPreview)
+	response.SetScopeData(metadata)
+
+	return response, nil
+}
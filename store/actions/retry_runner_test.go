@@ -0,0 +1,170 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package actions
+
+import (
+	"github.com/godbus/dbus"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/packages/fakes"
+	"testing"
+)
+
+// Test that a failed installation is retried.
+func TestRetryRunner_run_install(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true, FailedOperation: "install"})
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if !packageManager.InstallCalled {
+		t.Error("Expected package manager Install() function to be called")
+	}
+
+	if response.Status != scopes.ActivationShowPreview {
+		t.Errorf(`Response status was "%d", expected "%d"`, response.Status, scopes.ActivationShowPreview)
+	}
+
+	// Verify operation metadata
+	metadata, ok := response.ScopeData.(operation.Metadata)
+	if !ok {
+		// Exit here so we don't dereference nil
+		t.Fatalf("Expected response ScopeData to include operation metadata")
+	}
+
+	if !metadata.InstallRequested {
+		t.Errorf("Expected metadata to indicate that an installation was requested")
+	}
+
+	if metadata.ObjectPath != dbus.ObjectPath("/foo/1") {
+		t.Errorf(`Metadata object path was "%s", expected "/foo/1"`, metadata.ObjectPath)
+	}
+
+	if metadata.RetryCount != 1 {
+		t.Errorf("Metadata retry count was %d, expected 1", metadata.RetryCount)
+	}
+}
+
+// Test that a failed removal is retried.
+func TestRetryRunner_run_uninstall(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true, FailedOperation: "remove", RetryCount: 1})
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if !packageManager.UninstallCalled {
+		t.Error("Expected package manager Uninstall() function to be called")
+	}
+
+	metadata := response.ScopeData.(operation.Metadata)
+	if !metadata.UninstallConfirmed {
+		t.Errorf("Expected metadata to indicate that an uninstallation was confirmed")
+	}
+
+	if metadata.RetryCount != 2 {
+		t.Errorf("Metadata retry count was %d, expected 2", metadata.RetryCount)
+	}
+}
+
+// Test that a failed update is retried.
+func TestRetryRunner_run_refresh(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true, FailedOperation: "refresh", RetryCount: 2})
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error when attempting to run: %s", err)
+	}
+
+	if !packageManager.RefreshCalled {
+		t.Error("Expected package manager Refresh() function to be called")
+	}
+
+	metadata := response.ScopeData.(operation.Metadata)
+	if !metadata.RefreshRequested {
+		t.Errorf("Expected metadata to indicate that a refresh was requested")
+	}
+
+	if metadata.RetryCount != 3 {
+		t.Errorf("Metadata retry count was %d, expected 3", metadata.RetryCount)
+	}
+}
+
+// Test that the operation is no longer retried once the limit is reached.
+func TestRetryRunner_run_limitReached(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true, FailedOperation: "install", RetryCount: RetryLimit})
+
+	packageManager := new(fakes.FakeDbusManager)
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err == nil {
+		t.Error("Expected an error due to the retry limit being reached")
+	}
+	if response != nil {
+		t.Error("Expected response to be nil")
+	}
+
+	if packageManager.InstallCalled {
+		t.Error("Expected package manager Install() function to not be called")
+	}
+}
+
+// Test that an unknown operation can't be retried.
+func TestRetryRunner_run_unknownOperation(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true})
+
+	response, err := actionRunner.Run(new(fakes.FakeDbusManager), "foo")
+	if err == nil {
+		t.Error("Expected an error due to the operation being unknown")
+	}
+	if response != nil {
+		t.Error("Expected response to be nil")
+	}
+}
+
+// Test that a failure to replay the operation results in an error.
+func TestRetryRunner_run_installationFailure(t *testing.T) {
+	actionRunner, _ := NewRetryRunner()
+	actionRunner.SetMetadata(operation.Metadata{Failed: true, FailedOperation: "install"})
+
+	packageManager := &fakes.FakeDbusManager{FailInstall: true}
+
+	response, err := actionRunner.Run(packageManager, "foo")
+	if err == nil {
+		t.Error("Expected an error due to failure to install")
+	}
+	if response != nil {
+		t.Error("Expected response to be nil")
+	}
+}
@@ -21,7 +21,9 @@ package actions
 import (
 	"fmt"
 	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/operation"
 	"launchpad.net/unity-scope-snappy/store/packages"
+	"strings"
 )
 
 type ActionId string
@@ -32,19 +34,55 @@ const (
 	ActionUninstall                 = "uninstall"
 	ActionUninstallConfirm          = "uninstall_confirm"
 	ActionUninstallCancel           = "uninstall_cancel"
+	ActionRefresh                   = "refresh"
+	ActionRefreshAll                = "refresh_all"
+	ActionCancelOperation           = "cancel_operation"
 	ActionOpen                      = "open"
+	ActionDismiss                   = "dismiss"
+	ActionRetry                     = "retry"
 
 	// Actions from the progress widget
 	ActionFinished = "finished"
 	ActionFailed   = "failed"
 )
 
+// OpenAppAction creates the ID of the action opening a specific app of a
+// snap, as used by the app picker.
+//
+// Parameters:
+// appName: Name of the app to be opened.
+//
+// Returns:
+// - ID of the action opening that app.
+func OpenAppAction(appName string) ActionId {
+	return ActionId(ActionOpen + ":" + appName)
+}
+
 // Runner is an interface to be implemented by the action handlers throughout
 // the scope.
 type Runner interface {
 	Run(packageManager packages.DbusManager, snapId string) (*scopes.ActivationResponse, error)
 }
 
+// MetadataRunner is an interface to be implemented by action handlers that
+// depend upon the state persisted in the scope data.
+type MetadataRunner interface {
+	Runner
+
+	// SetMetadata provides the metadata of the preview the action came from.
+	SetMetadata(metadata operation.Metadata)
+}
+
+// LauncherRunner is an interface to be implemented by action handlers that
+// launch installed snaps.
+type LauncherRunner interface {
+	Runner
+
+	// SetLauncher provides the means of finding out about a snap's apps, and
+	// of opening them.
+	SetLauncher(snapdClient packages.WebdmManager, dispatcher packages.UrlDispatcher)
+}
+
 // NewRunner is a factory for getting the correct Runner for a given ActionId.
 //
 // Parameters:
@@ -59,8 +97,18 @@ func NewRunner(actionId ActionId) (Runner, error) {
 		return NewConfirmUninstallRunner()
 	case ActionUninstallCancel:
 		return NewCancelUninstallRunner()
+	case ActionRefresh:
+		return NewRefreshRunner()
+	case ActionRefreshAll:
+		return NewRefreshAllRunner()
+	case ActionCancelOperation:
+		return NewCancelOperationRunner()
 	case ActionOpen:
 		return NewOpenRunner()
+	case ActionDismiss:
+		return NewDismissRunner()
+	case ActionRetry:
+		return NewRetryRunner()
 
 	// Actions from the progress widget
 	case ActionFinished:
@@ -68,6 +116,11 @@ func NewRunner(actionId ActionId) (Runner, error) {
 	case ActionFailed:
 		return NewFailedRunner()
 	default:
+		// Actions from the app picker name the app to open
+		if appName := strings.TrimPrefix(string(actionId), ActionOpen+":"); appName != string(actionId) {
+			return NewOpenAppRunner(appName)
+		}
+
 		return nil, fmt.Errorf(`Unsupported action ID: "%s"`, actionId)
 	}
 }
@@ -32,7 +32,13 @@ var newRunnerTests = []struct {
 	{ActionUninstall, &UninstallRunner{}},
 	{ActionUninstallConfirm, &ConfirmUninstallRunner{}},
 	{ActionUninstallCancel, &CancelUninstallRunner{}},
+	{ActionRefresh, &RefreshRunner{}},
+	{ActionRefreshAll, &RefreshAllRunner{}},
+	{ActionCancelOperation, &CancelOperationRunner{}},
 	{ActionOpen, &OpenRunner{}},
+	{OpenAppAction("foo"), &OpenRunner{}},
+	{ActionDismiss, &DismissRunner{}},
+	{ActionRetry, &RetryRunner{}},
 	{ActionFinished, &FinishedRunner{}},
 	{ActionFailed, &FailedRunner{}},
 }
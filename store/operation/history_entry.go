@@ -0,0 +1,37 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package operation
+
+// HistoryEntry describes how a finished operation went, as recorded in the
+// history of the Package Manager service.
+type HistoryEntry struct {
+	Id         string
+	Kind       string   // Kind of operation ("install", "remove", "refresh")
+	PackageIds []string // IDs of the packages operated upon (empty for all)
+
+	// Key: Package ID
+	// Value: Revision of the package (absent if not installed)
+	RevisionsBefore map[string]string
+	RevisionsAfter  map[string]string
+
+	StartTime int64  // Unix time the operation was requested
+	EndTime   int64  // Unix time the operation finished
+	Status    string // Final status ("done", "error", "canceled")
+	Error     string // Reason for the failure (empty if none)
+}
@@ -28,9 +28,19 @@ type Metadata struct {
 	InstallRequested   bool
 	UninstallRequested bool
 	UninstallConfirmed bool
+	RefreshRequested   bool
+	AppPickerRequested bool
 
 	Finished bool
 	Failed   bool
 
+	// Kind of the operation that failed ("install", "remove", "refresh") and
+	// the reason it did (both empty if unknown)
+	FailedOperation string
+	ErrorMessage    string
+
+	// Number of times the failed operation has been retried
+	RetryCount int
+
 	ObjectPath dbus.ObjectPath
 }
@@ -0,0 +1,69 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/client"
+)
+
+// LaunchableApps determines which of a snap's apps can be opened. Apps with a
+// desktop file are preferred; should there be none, any app which isn't a
+// daemon will do.
+//
+// Parameters:
+// snap: Snap whose apps are considered.
+//
+// Returns:
+// - Slice of apps that can be opened
+func LaunchableApps(snap client.Snap) []client.AppInfo {
+	desktopApps := make([]client.AppInfo, 0)
+	commandApps := make([]client.AppInfo, 0)
+	for _, app := range snap.Apps {
+		if app.DesktopFile != "" {
+			desktopApps = append(desktopApps, app)
+		} else if app.Daemon == "" {
+			commandApps = append(commandApps, app)
+		}
+	}
+
+	if len(desktopApps) != 0 {
+		return desktopApps
+	}
+
+	return commandApps
+}
+
+// AppUri determines the URI used for opening a snap's app.
+//
+// Parameters:
+// snap: Snap holding the app.
+// app: App to be opened.
+//
+// Returns:
+// - URI of the app (its desktop file's if it has one)
+func AppUri(snap client.Snap, app client.AppInfo) string {
+	if app.DesktopFile != "" {
+		return "application:///" + filepath.Base(app.DesktopFile)
+	}
+
+	return fmt.Sprintf("appid://%s/%s/current-user-version", snap.Name, app.Name)
+}
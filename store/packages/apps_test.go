@@ -0,0 +1,70 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"github.com/snapcore/snapd/client"
+	"testing"
+)
+
+// Data for TestAppUri
+var appUriTests = []struct {
+	app         string
+	desktopFile string
+	expected    string
+}{
+	{"bar", "/var/lib/snapd/desktop/applications/foo_bar.desktop", "application:///foo_bar.desktop"},
+	{"bar", "", "appid://foo/bar/current-user-version"},
+}
+
+// Test that apps are opened via their desktop file if they have one.
+func TestAppUri(t *testing.T) {
+	for i, test := range appUriTests {
+		uri := AppUri(client.Snap{Name: "foo"}, client.AppInfo{Name: test.app, DesktopFile: test.desktopFile})
+		if uri != test.expected {
+			t.Errorf(`Test case %d: URI was "%s", expected "%s"`, i, uri, test.expected)
+		}
+	}
+}
+
+// Test that apps with a desktop file are preferred, and daemons never opened.
+func TestLaunchableApps(t *testing.T) {
+	snap := client.Snap{Apps: []client.AppInfo{
+		{Name: "daemon", Daemon: "simple"},
+		{Name: "command"},
+		{Name: "desktop", DesktopFile: "foo_desktop.desktop"},
+	}}
+
+	apps := LaunchableApps(snap)
+	if len(apps) != 1 || apps[0].Name != "desktop" {
+		t.Errorf("Launchable apps were %v, expected only the desktop app", apps)
+	}
+
+	snap.Apps = snap.Apps[:2]
+	apps = LaunchableApps(snap)
+	if len(apps) != 1 || apps[0].Name != "command" {
+		t.Errorf("Launchable apps were %v, expected only the command app", apps)
+	}
+
+	snap.Apps = snap.Apps[:1]
+	apps = LaunchableApps(snap)
+	if len(apps) != 0 {
+		t.Errorf("Launchable apps were %v, expected none", apps)
+	}
+}
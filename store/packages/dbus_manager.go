@@ -20,6 +20,7 @@ package packages
 
 import (
 	"github.com/godbus/dbus"
+	"launchpad.net/unity-scope-snappy/store/operation"
 )
 
 // DbusManager is an interface to be implemented by any struct that supports
@@ -29,4 +30,13 @@ type DbusManager interface {
 	Connect() error
 	Install(packageId string) (dbus.ObjectPath, error)
 	Uninstall(packageId string) (dbus.ObjectPath, error)
+	Refresh(packageId string) (dbus.ObjectPath, error)
+	RefreshAll() (dbus.ObjectPath, error)
+	Cancel(packageId string) error
+	CancelOperation(objectPath dbus.ObjectPath) error
+	ListOperations() ([]dbus.ObjectPath, error)
+	GetOperationForPackage(packageId string) (dbus.ObjectPath, string, error)
+	GetDownloadStatus(objectPath dbus.ObjectPath) (uint64, int64, error)
+	FollowDownloadStatus(objectPath dbus.ObjectPath, stop <-chan bool, update func(rate uint64, eta int64)) error
+	GetHistory(limit uint32) ([]operation.HistoryEntry, error)
 }
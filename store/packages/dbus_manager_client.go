@@ -21,15 +21,32 @@ package packages
 import (
 	"fmt"
 	"github.com/godbus/dbus"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"time"
 )
 
 const (
-	defaultDbusObject          = "com.canonical.applications.WebdmPackageManager"
-	defaultDbusObjectInterface = "com.canonical.applications.Download"
-	defaultInstallMethod       = defaultDbusObjectInterface + ".Install"
-	defaultUninstallMethod     = defaultDbusObjectInterface + ".Uninstall"
+	defaultDbusObject                   = "com.canonical.applications.WebdmPackageManager"
+	defaultDbusObjectInterface          = "com.canonical.applications.Download"
+	defaultInstallMethod                = defaultDbusObjectInterface + ".Install"
+	defaultUninstallMethod              = defaultDbusObjectInterface + ".Uninstall"
+	defaultRefreshMethod                = defaultDbusObjectInterface + ".Refresh"
+	defaultRefreshAllMethod             = defaultDbusObjectInterface + ".RefreshAll"
+	defaultCancelMethod                 = defaultDbusObjectInterface + ".Cancel"
+	defaultListOperationsMethod         = defaultDbusObjectInterface + ".ListOperations"
+	defaultGetOperationForPackageMethod = defaultDbusObjectInterface + ".GetOperationForPackage"
+	defaultGetHistoryMethod             = defaultDbusObjectInterface + ".GetHistory"
+	defaultNoOperationError             = defaultDbusObjectInterface + ".Error.NoOperation"
+	defaultOperationKindProperty        = defaultDbusObjectInterface + ".Kind"
+	defaultDownloadRateProperty         = defaultDbusObjectInterface + ".DownloadRate"
+	defaultEtaProperty                  = defaultDbusObjectInterface + ".Eta"
+	defaultStatusProperty               = defaultDbusObjectInterface + ".Status"
 )
 
+// defaultPollInterval is how often the properties of an operation are polled
+// while following its download status.
+const defaultPollInterval = time.Second
+
 // DbusManagerClient is a DBus client for communicating with the WebDM Package
 // Manager DBus service.
 type DbusManagerClient struct {
@@ -38,8 +55,22 @@ type DbusManagerClient struct {
 	dbusObject          string
 	dbusObjectInterface string
 
-	installMethod   string
-	uninstallMethod string
+	installMethod    string
+	uninstallMethod  string
+	refreshMethod    string
+	refreshAllMethod string
+	cancelMethod     string
+
+	listOperationsMethod         string
+	getOperationForPackageMethod string
+	getHistoryMethod             string
+	noOperationError             string
+	operationKindProperty        string
+	downloadRateProperty         string
+	etaProperty                  string
+	statusProperty               string
+
+	pollInterval time.Duration
 }
 
 // NewDbusManagerClient creates a new DbusManagerClient.
@@ -51,6 +82,20 @@ func NewDbusManagerClient() *DbusManagerClient {
 
 	client.installMethod = defaultInstallMethod
 	client.uninstallMethod = defaultUninstallMethod
+	client.refreshMethod = defaultRefreshMethod
+	client.refreshAllMethod = defaultRefreshAllMethod
+	client.cancelMethod = defaultCancelMethod
+
+	client.listOperationsMethod = defaultListOperationsMethod
+	client.getOperationForPackageMethod = defaultGetOperationForPackageMethod
+	client.getHistoryMethod = defaultGetHistoryMethod
+	client.noOperationError = defaultNoOperationError
+	client.operationKindProperty = defaultOperationKindProperty
+	client.downloadRateProperty = defaultDownloadRateProperty
+	client.etaProperty = defaultEtaProperty
+	client.statusProperty = defaultStatusProperty
+
+	client.pollInterval = defaultPollInterval
 
 	return client
 }
@@ -107,3 +152,254 @@ func (client *DbusManagerClient) Uninstall(packageId string) (dbus.ObjectPath, e
 
 	return objectPath, err
 }
+
+// Refresh requests that the Package Manager service update the given package
+// to its latest revision.
+//
+// Parameters:
+// packageId: The ID of the package to refresh.
+//
+// Returns:
+// - DBus object path to monitor the refresh operation.
+// - Error (nil if none).
+func (client *DbusManagerClient) Refresh(packageId string) (dbus.ObjectPath, error) {
+	if client.connection == nil {
+		return "", fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	var objectPath dbus.ObjectPath
+	err := busObject.Call(client.refreshMethod, 0, packageId).Store(&objectPath)
+
+	return objectPath, err
+}
+
+// RefreshAll requests that the Package Manager service update every installed
+// package that has a newer revision available.
+//
+// Returns:
+// - DBus object path to monitor the refresh operation.
+// - Error (nil if none).
+func (client *DbusManagerClient) RefreshAll() (dbus.ObjectPath, error) {
+	if client.connection == nil {
+		return "", fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	var objectPath dbus.ObjectPath
+	err := busObject.Call(client.refreshAllMethod, 0).Store(&objectPath)
+
+	return objectPath, err
+}
+
+// Cancel requests that the Package Manager service cancel the operation in
+// progress on the given package.
+//
+// Parameters:
+// packageId: The ID of the package whose operation should be canceled.
+//
+// Returns:
+// - Error (nil if none).
+func (client *DbusManagerClient) Cancel(packageId string) error {
+	if client.connection == nil {
+		return fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	return busObject.Call(client.cancelMethod, 0, packageId).Err
+}
+
+// CancelOperation requests that the Package Manager service cancel a specific
+// operation, whether it's still queued or already in progress.
+//
+// Parameters:
+// objectPath: DBus object path of the operation to cancel.
+//
+// Returns:
+// - Error (nil if none).
+func (client *DbusManagerClient) CancelOperation(objectPath dbus.ObjectPath) error {
+	if client.connection == nil {
+		return fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, objectPath)
+
+	return busObject.Call(client.cancelMethod, 0).Err
+}
+
+// ListOperations requests the list of operations currently in progress in the
+// Package Manager service.
+//
+// Returns:
+// - DBus object paths of the operations in progress.
+// - Error (nil if none).
+func (client *DbusManagerClient) ListOperations() ([]dbus.ObjectPath, error) {
+	if client.connection == nil {
+		return nil, fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	var objectPaths []dbus.ObjectPath
+	err := busObject.Call(client.listOperationsMethod, 0).Store(&objectPaths)
+
+	return objectPaths, err
+}
+
+// GetOperationForPackage requests the operation currently in progress on the
+// given package, along with its kind.
+//
+// Parameters:
+// packageId: The ID of the package being operated upon.
+//
+// Returns:
+// - DBus object path to monitor the operation (empty if none in progress).
+// - Kind of the operation ("install", "remove", "refresh").
+// - Error (nil if none).
+func (client *DbusManagerClient) GetOperationForPackage(packageId string) (dbus.ObjectPath, string, error) {
+	if client.connection == nil {
+		return "", "", fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	var objectPath dbus.ObjectPath
+	err := busObject.Call(client.getOperationForPackageMethod, 0, packageId).Store(&objectPath)
+	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok && dbusErr.Name == client.noOperationError {
+			return "", "", nil
+		}
+
+		return "", "", err
+	}
+
+	kind, err := client.connection.Object(client.dbusObject, objectPath).GetProperty(client.operationKindProperty)
+	if err != nil {
+		return "", "", err
+	}
+
+	kindString, ok := kind.Value().(string)
+	if !ok {
+		return "", "", fmt.Errorf(`Operation "%s" has an invalid kind: %s`, objectPath, kind)
+	}
+
+	return objectPath, kindString, nil
+}
+
+// GetDownloadStatus requests the download rate and estimated time remaining of
+// an operation.
+//
+// Parameters:
+// objectPath: DBus object path of the operation.
+//
+// Returns:
+// - Download rate in bytes per second (0 if not downloading).
+// - Estimated time remaining in seconds (-1 if unknown).
+// - Error (nil if none).
+func (client *DbusManagerClient) GetDownloadStatus(objectPath dbus.ObjectPath) (uint64, int64, error) {
+	if client.connection == nil {
+		return 0, -1, fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, objectPath)
+
+	rate, err := busObject.GetProperty(client.downloadRateProperty)
+	if err != nil {
+		return 0, -1, err
+	}
+
+	rateValue, ok := rate.Value().(uint64)
+	if !ok {
+		return 0, -1, fmt.Errorf(`Operation "%s" has an invalid download rate: %s`, objectPath, rate)
+	}
+
+	eta, err := busObject.GetProperty(client.etaProperty)
+	if err != nil {
+		return 0, -1, err
+	}
+
+	etaValue, ok := eta.Value().(int64)
+	if !ok {
+		return 0, -1, fmt.Errorf(`Operation "%s" has an invalid ETA: %s`, objectPath, eta)
+	}
+
+	return rateValue, etaValue, nil
+}
+
+// FollowDownloadStatus reports the download rate and estimated time remaining
+// of an operation whenever they change, polling its properties until the
+// operation is over or following it is no longer wanted. Its signals aren't
+// used, as the connection is shared and can't stop delivering them.
+//
+// Parameters:
+// objectPath: DBus object path of the operation.
+// stop: Channel closed (or written to) when following should stop.
+// update: Function called with the download rate in bytes per second (0 if
+// not downloading) and the estimated time remaining in seconds (-1 if
+// unknown).
+//
+// Returns:
+// - Error (nil if none).
+func (client *DbusManagerClient) FollowDownloadStatus(objectPath dbus.ObjectPath, stop <-chan bool, update func(rate uint64, eta int64)) error {
+	if client.connection == nil {
+		return fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, objectPath)
+
+	// The status shown to begin with is left alone until it changes
+	var lastRate uint64
+	var lastEta int64 = -1
+
+	for {
+		status, err := busObject.GetProperty(client.statusProperty)
+		if err != nil {
+			return err
+		}
+
+		if status.Value() != "queued" && status.Value() != "running" {
+			return nil
+		}
+
+		rate, eta, err := client.GetDownloadStatus(objectPath)
+		if err != nil {
+			return err
+		}
+
+		if rate != lastRate || eta != lastEta {
+			update(rate, eta)
+			lastRate, lastEta = rate, eta
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(client.pollInterval):
+		}
+	}
+}
+
+// GetHistory requests the operations that finished in the Package Manager
+// service, whether successfully or not.
+//
+// Parameters:
+// limit: Maximum number of entries to request (zero for all).
+//
+// Returns:
+// - History entries, newest first.
+// - Error (nil if none).
+func (client *DbusManagerClient) GetHistory(limit uint32) ([]operation.HistoryEntry, error) {
+	if client.connection == nil {
+		return nil, fmt.Errorf("Client is not connected")
+	}
+
+	busObject := client.connection.Object(client.dbusObject, "/")
+
+	var entries []operation.HistoryEntry
+	err := busObject.Call(client.getHistoryMethod, 0, limit).Store(&entries)
+
+	return entries, err
+}
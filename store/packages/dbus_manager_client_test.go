@@ -19,9 +19,13 @@
 package packages
 
 import (
+	"fmt"
+	"github.com/godbus/dbus"
 	"launchpad.net/unity-scope-snappy/store/packages/fakes"
 	"launchpad.net/unity-scope-snappy/store/packages/mocks"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // Test typical NewDbusManagerClient usage.
@@ -58,7 +62,7 @@ func TestDbusManagerClient_connect(t *testing.T) {
 func TestDbusManagerClient_install(t *testing.T) {
 	client := NewDbusManagerClient()
 	mockObject := &mocks.MockBusObject{}
-	client.connection = fakes.FakeDbusConnection{mockObject}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
 
 	_, err := client.Install("foo")
 	if err != nil {
@@ -95,7 +99,7 @@ func TestDbusManagerClient_install_beforeConnect(t *testing.T) {
 func TestDbusManagerClient_uninstall(t *testing.T) {
 	client := NewDbusManagerClient()
 	mockObject := &mocks.MockBusObject{}
-	client.connection = fakes.FakeDbusConnection{mockObject}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
 
 	_, err := client.Uninstall("foo")
 	if err != nil {
@@ -127,3 +131,475 @@ func TestDbusManagerClient_uninstall_beforeConnect(t *testing.T) {
 		t.Error("Expected an error due to uninstall before connect")
 	}
 }
+
+// Test typical Refresh usage.
+func TestDbusManagerClient_refresh(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, err := client.Refresh("foo")
+	if err != nil {
+		t.Errorf("Unexpected error refreshing: %s", err)
+	}
+
+	if !mockObject.CallCalled {
+		t.Errorf("Expected client to call MockBusObject.Call")
+	}
+
+	if mockObject.Method != client.refreshMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.refreshMethod)
+	}
+
+	if len(mockObject.Args) != 1 {
+		t.Fatalf("Got %d arguments, expected 1", len(mockObject.Args))
+	}
+
+	if mockObject.Args[0] != "foo" {
+		t.Errorf(`Refresh was called with "%s", expected "foo"`, mockObject.Args[0])
+	}
+}
+
+// Test that trying to refresh before connecting results in an error.
+func TestDbusManagerClient_refresh_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, err := client.Refresh("foo")
+	if err == nil {
+		t.Error("Expected an error due to refresh before connect")
+	}
+}
+
+// Test typical RefreshAll usage.
+func TestDbusManagerClient_refreshAll(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, err := client.RefreshAll()
+	if err != nil {
+		t.Errorf("Unexpected error refreshing: %s", err)
+	}
+
+	if !mockObject.CallCalled {
+		t.Errorf("Expected client to call MockBusObject.Call")
+	}
+
+	if mockObject.Method != client.refreshAllMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.refreshAllMethod)
+	}
+
+	if len(mockObject.Args) != 0 {
+		t.Errorf("Got %d arguments, expected 0", len(mockObject.Args))
+	}
+}
+
+// Test that trying to refresh all before connecting results in an error.
+func TestDbusManagerClient_refreshAll_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, err := client.RefreshAll()
+	if err == nil {
+		t.Error("Expected an error due to refresh before connect")
+	}
+}
+
+// Test typical Cancel usage.
+func TestDbusManagerClient_cancel(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := client.Cancel("foo")
+	if err != nil {
+		t.Errorf("Unexpected error canceling: %s", err)
+	}
+
+	if !mockObject.CallCalled {
+		t.Errorf("Expected client to call MockBusObject.Call")
+	}
+
+	if mockObject.Method != client.cancelMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.cancelMethod)
+	}
+
+	if len(mockObject.Args) != 1 {
+		t.Fatalf("Got %d arguments, expected 1", len(mockObject.Args))
+	}
+
+	if mockObject.Args[0] != "foo" {
+		t.Errorf(`Cancel was called with "%s", expected "foo"`, mockObject.Args[0])
+	}
+}
+
+// Test that trying to cancel before connecting results in an error.
+func TestDbusManagerClient_cancel_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	err := client.Cancel("foo")
+	if err == nil {
+		t.Error("Expected an error due to cancel before connect")
+	}
+}
+
+// Test typical CancelOperation usage.
+func TestDbusManagerClient_cancelOperation(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := client.CancelOperation("/foo/1")
+	if err != nil {
+		t.Errorf("Unexpected error canceling: %s", err)
+	}
+
+	if !mockObject.CallCalled {
+		t.Errorf("Expected client to call MockBusObject.Call")
+	}
+
+	if mockObject.Method != client.cancelMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.cancelMethod)
+	}
+
+	if len(mockObject.Args) != 0 {
+		t.Errorf("Got %d arguments, expected none", len(mockObject.Args))
+	}
+}
+
+// Test that trying to cancel an operation before connecting results in an
+// error.
+func TestDbusManagerClient_cancelOperation_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	err := client.CancelOperation("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to cancel before connect")
+	}
+}
+
+// Test typical ListOperations usage.
+func TestDbusManagerClient_listOperations(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		CallBody: []interface{}{[]dbus.ObjectPath{"/foo/1", "/foo/2"}},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	objectPaths, err := client.ListOperations()
+	if err != nil {
+		t.Fatalf("Unexpected error listing operations: %s", err)
+	}
+
+	if mockObject.Method != client.listOperationsMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.listOperationsMethod)
+	}
+
+	if len(objectPaths) != 2 {
+		t.Fatalf("Got %d operations, expected 2", len(objectPaths))
+	}
+
+	if objectPaths[0] != "/foo/1" || objectPaths[1] != "/foo/2" {
+		t.Errorf(`Operations were %v, expected [/foo/1 /foo/2]`, objectPaths)
+	}
+}
+
+// Test that trying to list operations before connecting results in an error.
+func TestDbusManagerClient_listOperations_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, err := client.ListOperations()
+	if err == nil {
+		t.Error("Expected an error due to listing operations before connect")
+	}
+}
+
+// Test typical GetOperationForPackage usage.
+func TestDbusManagerClient_getOperationForPackage(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	objectPath, kind, err := client.GetOperationForPackage("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error getting operation: %s", err)
+	}
+
+	if mockObject.Method != client.getOperationForPackageMethod {
+		t.Errorf(`Client called method "%s", expected "%s"`, mockObject.Method, client.getOperationForPackageMethod)
+	}
+
+	if len(mockObject.Args) != 1 || mockObject.Args[0] != "foo" {
+		t.Errorf(`GetOperationForPackage was called with %v, expected ["foo"]`, mockObject.Args)
+	}
+
+	if objectPath != "/foo/1" {
+		t.Errorf(`Object path was "%s", expected "/foo/1"`, objectPath)
+	}
+
+	if mockObject.Property != client.operationKindProperty {
+		t.Errorf(`Client got property "%s", expected "%s"`, mockObject.Property, client.operationKindProperty)
+	}
+
+	if kind != "foo" {
+		t.Errorf(`Kind was "%s", expected "foo"`, kind)
+	}
+}
+
+// Test that having no operation in progress isn't an error.
+func TestDbusManagerClient_getOperationForPackage_noOperation(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		CallErr: dbus.Error{Name: defaultNoOperationError},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	objectPath, kind, err := client.GetOperationForPackage("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error getting operation: %s", err)
+	}
+
+	if objectPath != "" || kind != "" {
+		t.Errorf(`Got operation "%s" (%s), expected none`, objectPath, kind)
+	}
+
+	if mockObject.GetPropertyCalled {
+		t.Error("Expected client not to query the kind of a missing operation")
+	}
+}
+
+// Test that other errors are reported.
+func TestDbusManagerClient_getOperationForPackage_failure(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		CallErr: dbus.Error{Name: "org.freedesktop.DBus.Error.Failed"},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, _, err := client.GetOperationForPackage("foo")
+	if err == nil {
+		t.Error("Expected an error due to the call failing")
+	}
+}
+
+// Test that trying to get an operation before connecting results in an error.
+func TestDbusManagerClient_getOperationForPackage_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, _, err := client.GetOperationForPackage("foo")
+	if err == nil {
+		t.Error("Expected an error due to getting an operation before connect")
+	}
+}
+
+// Test typical GetDownloadStatus usage.
+func TestDbusManagerClient_getDownloadStatus(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		Properties: map[string]dbus.Variant{
+			client.downloadRateProperty: dbus.MakeVariant(uint64(1000)),
+			client.etaProperty:          dbus.MakeVariant(int64(42)),
+		},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	rate, eta, err := client.GetDownloadStatus("/foo/1")
+	if err != nil {
+		t.Fatalf("Unexpected error getting download status: %s", err)
+	}
+
+	if rate != 1000 || eta != 42 {
+		t.Errorf("Got rate %d and ETA %d, expected 1000 and 42", rate, eta)
+	}
+}
+
+// Test that properties of the wrong type result in an error.
+func TestDbusManagerClient_getDownloadStatus_invalidProperty(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{} // Properties default to "foo"
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, _, err := client.GetDownloadStatus("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to the invalid download rate")
+	}
+}
+
+// Test that failing to get the properties results in an error.
+func TestDbusManagerClient_getDownloadStatus_failure(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{PropertyErr: fmt.Errorf("Failed at user request")}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, _, err := client.GetDownloadStatus("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to failure to get property")
+	}
+}
+
+// Test that trying to get the download status before connecting results in an
+// error.
+func TestDbusManagerClient_getDownloadStatus_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, _, err := client.GetDownloadStatus("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to getting the download status before connect")
+	}
+}
+
+// Test typical FollowDownloadStatus usage.
+func TestDbusManagerClient_followDownloadStatus(t *testing.T) {
+	client := NewDbusManagerClient()
+	client.pollInterval = time.Millisecond
+	mockObject := &mocks.MockBusObject{
+		Properties: map[string]dbus.Variant{
+			client.statusProperty:       dbus.MakeVariant("done"),
+			client.downloadRateProperty: dbus.MakeVariant(uint64(2000)),
+			client.etaProperty:          dbus.MakeVariant(int64(21)),
+		},
+		PropertySequences: map[string][]dbus.Variant{
+			client.statusProperty: {
+				dbus.MakeVariant("queued"),
+				dbus.MakeVariant("running"),
+				dbus.MakeVariant("running"),
+				dbus.MakeVariant("running"),
+			},
+			client.downloadRateProperty: {
+				dbus.MakeVariant(uint64(0)),
+				dbus.MakeVariant(uint64(1000)),
+				dbus.MakeVariant(uint64(1000)),
+			},
+			client.etaProperty: {
+				dbus.MakeVariant(int64(-1)),
+				dbus.MakeVariant(int64(42)),
+				dbus.MakeVariant(int64(42)),
+			},
+		},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	var updates [][2]int64
+	err := client.FollowDownloadStatus("/foo/1", nil, func(rate uint64, eta int64) {
+		updates = append(updates, [2]int64{int64(rate), eta})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error following download status: %s", err)
+	}
+
+	// Only changes are reported
+	if !reflect.DeepEqual(updates, [][2]int64{{1000, 42}, {2000, 21}}) {
+		t.Errorf("Updates were %v, expected both download rates", updates)
+	}
+}
+
+// Test that following the download status stops when requested.
+func TestDbusManagerClient_followDownloadStatus_stop(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		Properties: map[string]dbus.Variant{
+			client.statusProperty:       dbus.MakeVariant("queued"),
+			client.downloadRateProperty: dbus.MakeVariant(uint64(0)),
+			client.etaProperty:          dbus.MakeVariant(int64(-1)),
+		},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	stop := make(chan bool)
+	close(stop)
+
+	err := client.FollowDownloadStatus("/foo/1", stop, func(rate uint64, eta int64) {
+		t.Error("Expected no update")
+	})
+	if err != nil {
+		t.Errorf("Unexpected error following download status: %s", err)
+	}
+}
+
+// Test that following an operation that already ended returns right away.
+func TestDbusManagerClient_followDownloadStatus_alreadyDone(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		Properties: map[string]dbus.Variant{
+			client.statusProperty: dbus.MakeVariant("done"),
+		},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := client.FollowDownloadStatus("/foo/1", nil, func(rate uint64, eta int64) {
+		t.Error("Expected no update")
+	})
+	if err != nil {
+		t.Errorf("Unexpected error following download status: %s", err)
+	}
+}
+
+// Test that failing to poll the operation results in an error.
+func TestDbusManagerClient_followDownloadStatus_failure(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{PropertyErr: fmt.Errorf("Failed at user request")}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := client.FollowDownloadStatus("/foo/1", nil, func(rate uint64, eta int64) {})
+	if err == nil {
+		t.Error("Expected an error due to failure to get the operation status")
+	}
+}
+
+// Test that trying to follow the download status before connecting results in
+// an error.
+func TestDbusManagerClient_followDownloadStatus_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	err := client.FollowDownloadStatus("/foo/1", nil, func(rate uint64, eta int64) {})
+	if err == nil {
+		t.Error("Expected an error due to following the download status before connect")
+	}
+}
+
+// Test typical GetHistory usage.
+func TestDbusManagerClient_getHistory(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{
+		CallBody: []interface{}{[]interface{}{
+			[]interface{}{"1", "install", []string{"foo"}, map[string]string{},
+				map[string]string{"foo": "3"}, int64(10), int64(20), "done", ""},
+		}},
+	}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	entries, err := client.GetHistory(5)
+	if err != nil {
+		t.Fatalf("Unexpected error getting history: %s", err)
+	}
+
+	if mockObject.Method != client.getHistoryMethod {
+		t.Errorf(`Method was "%s", expected "%s"`, mockObject.Method, client.getHistoryMethod)
+	}
+
+	if len(mockObject.Args) != 1 || mockObject.Args[0] != uint32(5) {
+		t.Errorf("Args were %v, expected the limit", mockObject.Args)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Got %d entries, expected 1", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Kind != "install" || entry.PackageIds[0] != "foo" ||
+		entry.RevisionsAfter["foo"] != "3" || entry.EndTime != 20 || entry.Status != "done" {
+		t.Errorf("Entry was %#v, expected the install of foo", entry)
+	}
+}
+
+// Test that failing to get the history results in an error.
+func TestDbusManagerClient_getHistory_failure(t *testing.T) {
+	client := NewDbusManagerClient()
+	mockObject := &mocks.MockBusObject{CallErr: fmt.Errorf("Failed at user request")}
+	client.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	_, err := client.GetHistory(0)
+	if err == nil {
+		t.Error("Expected an error due to failure to call")
+	}
+}
+
+// Test that trying to get the history before connecting results in an error.
+func TestDbusManagerClient_getHistory_beforeConnect(t *testing.T) {
+	client := NewDbusManagerClient()
+	_, err := client.GetHistory(0)
+	if err == nil {
+		t.Error("Expected an error due to getting the history before connect")
+	}
+}
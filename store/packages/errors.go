@@ -0,0 +1,67 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"fmt"
+	"time"
+)
+
+// RequestError is returned when snapd refuses to start a change, e.g. because
+// the package doesn't exist or another change is already in progress for it.
+type RequestError struct {
+	Operation string // Operation that was requested ("install", "remove")
+	PackageId string // ID of the package the operation was requested for
+	Kind      string // Error kind as reported by snapd (may be empty)
+	Message   string // Error message as reported by snapd
+}
+
+func (err *RequestError) Error() string {
+	return fmt.Sprintf(`snapd: Unable to %s package "%s": %s`, err.Operation,
+		err.PackageId, err.Message)
+}
+
+// ChangeError is returned when a snapd change was started, but finished in a
+// state other than "Done".
+type ChangeError struct {
+	Operation string // Operation that was requested ("install", "remove")
+	PackageId string // ID of the package the change was operating on
+	ChangeId  string // ID of the snapd change
+	Status    string // Final status of the change (e.g. "Error", "Undone")
+	Message   string // Error message of the change (may be empty)
+}
+
+func (err *ChangeError) Error() string {
+	return fmt.Sprintf(`snapd: Change %s to %s package "%s" ended with status "%s": %s`,
+		err.ChangeId, err.Operation, err.PackageId, err.Status, err.Message)
+}
+
+// TimeoutError is returned when a snapd change didn't become ready within the
+// allotted time. Note that the change may still be running in snapd.
+type TimeoutError struct {
+	Operation string        // Operation that was requested ("install", "remove")
+	PackageId string        // ID of the package the change was operating on
+	ChangeId  string        // ID of the snapd change
+	Timeout   time.Duration // Time waited before giving up
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf(`snapd: Timed out after %s waiting for change %s to %s package "%s"`,
+		err.Timeout, err.ChangeId, err.Operation, err.PackageId)
+}
@@ -37,7 +37,7 @@ func TestFakeDbusConnection_names(t *testing.T) {
 // Test that Object simply returns the given DbusObject.
 func TestFakeDbusConnection_object(t *testing.T) {
 	mock := &mocks.MockBusObject{}
-	connection := FakeDbusConnection{mock}
+	connection := FakeDbusConnection{DbusObject: mock}
 
 	if !reflect.DeepEqual(connection.Object("foo", "bar"), mock) {
 		t.Error("Expected the fake connection to return the given mock")
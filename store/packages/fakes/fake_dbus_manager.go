@@ -21,18 +21,50 @@ package fakes
 import (
 	"fmt"
 	"github.com/godbus/dbus"
+	"launchpad.net/unity-scope-snappy/store/operation"
 )
 
 // FakeDbusManager is a fake implementation of the DbusManager interface, for
 // use within tests.
 type FakeDbusManager struct {
-	ConnectCalled   bool
-	InstallCalled   bool
-	UninstallCalled bool
+	ConnectCalled    bool
+	InstallCalled    bool
+	UninstallCalled  bool
+	RefreshCalled    bool
+	RefreshAllCalled bool
+	CancelCalled     bool
 
-	FailConnect   bool
-	FailInstall   bool
-	FailUninstall bool
+	CancelOperationCalled        bool
+	ListOperationsCalled         bool
+	GetOperationForPackageCalled bool
+	GetDownloadStatusCalled      bool
+	FollowDownloadStatusCalled   bool
+	GetHistoryCalled             bool
+
+	FailConnect    bool
+	FailInstall    bool
+	FailUninstall  bool
+	FailRefresh    bool
+	FailRefreshAll bool
+	FailCancel     bool
+
+	FailCancelOperation        bool
+	FailListOperations         bool
+	FailGetOperationForPackage bool
+	FailGetDownloadStatus      bool
+	FailFollowDownloadStatus   bool
+	FailGetHistory             bool
+
+	// Key: Package ID
+	// Value: Kind of the operation in progress on that package
+	Operations map[string]string
+
+	// Download status reported for every operation
+	DownloadRate uint64
+	Eta          int64
+
+	// Entries reported by GetHistory, newest first
+	History []operation.HistoryEntry
 }
 
 func (manager *FakeDbusManager) Connect() error {
@@ -64,3 +96,111 @@ func (manager *FakeDbusManager) Uninstall(packageId string) (dbus.ObjectPath, er
 
 	return "/foo/1", nil
 }
+
+func (manager *FakeDbusManager) Refresh(packageId string) (dbus.ObjectPath, error) {
+	manager.RefreshCalled = true
+
+	if manager.FailRefresh {
+		return "", fmt.Errorf("Failed at user request")
+	}
+
+	return "/foo/1", nil
+}
+
+func (manager *FakeDbusManager) RefreshAll() (dbus.ObjectPath, error) {
+	manager.RefreshAllCalled = true
+
+	if manager.FailRefreshAll {
+		return "", fmt.Errorf("Failed at user request")
+	}
+
+	return "/foo/1", nil
+}
+
+func (manager *FakeDbusManager) Cancel(packageId string) error {
+	manager.CancelCalled = true
+
+	if manager.FailCancel {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	return nil
+}
+
+func (manager *FakeDbusManager) CancelOperation(objectPath dbus.ObjectPath) error {
+	manager.CancelOperationCalled = true
+
+	if manager.FailCancelOperation {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	return nil
+}
+
+func (manager *FakeDbusManager) ListOperations() ([]dbus.ObjectPath, error) {
+	manager.ListOperationsCalled = true
+
+	if manager.FailListOperations {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	objectPaths := make([]dbus.ObjectPath, 0, len(manager.Operations))
+	for range manager.Operations {
+		objectPaths = append(objectPaths, dbus.ObjectPath(fmt.Sprintf("/foo/%d", len(objectPaths)+1)))
+	}
+
+	return objectPaths, nil
+}
+
+func (manager *FakeDbusManager) GetOperationForPackage(packageId string) (dbus.ObjectPath, string, error) {
+	manager.GetOperationForPackageCalled = true
+
+	if manager.FailGetOperationForPackage {
+		return "", "", fmt.Errorf("Failed at user request")
+	}
+
+	kind, ok := manager.Operations[packageId]
+	if !ok {
+		return "", "", nil
+	}
+
+	return "/foo/1", kind, nil
+}
+
+func (manager *FakeDbusManager) GetDownloadStatus(objectPath dbus.ObjectPath) (uint64, int64, error) {
+	manager.GetDownloadStatusCalled = true
+
+	if manager.FailGetDownloadStatus {
+		return 0, -1, fmt.Errorf("Failed at user request")
+	}
+
+	return manager.DownloadRate, manager.Eta, nil
+}
+
+// FollowDownloadStatus reports the download status once, as if the operation
+// then ended.
+func (manager *FakeDbusManager) FollowDownloadStatus(objectPath dbus.ObjectPath, stop <-chan bool, update func(rate uint64, eta int64)) error {
+	manager.FollowDownloadStatusCalled = true
+
+	if manager.FailFollowDownloadStatus {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	update(manager.DownloadRate, manager.Eta)
+
+	return nil
+}
+
+func (manager *FakeDbusManager) GetHistory(limit uint32) ([]operation.HistoryEntry, error) {
+	manager.GetHistoryCalled = true
+
+	if manager.FailGetHistory {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	if limit > 0 && int(limit) < len(manager.History) {
+		return manager.History[:limit], nil
+	}
+
+	return manager.History, nil
+}
@@ -19,6 +19,7 @@
 package fakes
 
 import (
+	"launchpad.net/unity-scope-snappy/store/operation"
 	"testing"
 )
 
@@ -113,3 +114,277 @@ func TestFakeDbusManager_Uninstall_failureRequest(t *testing.T) {
 		t.Error("Expected UninstallCalled to have been set")
 	}
 }
+
+// Test typical Refresh usage.
+func TestFakeDbusManager_Refresh(t *testing.T) {
+	manager := &FakeDbusManager{}
+
+	objectPath, err := manager.Refresh("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while refreshing: %s", err)
+	}
+
+	if !objectPath.IsValid() {
+		t.Errorf("Object path was unexpectedly invalid: %s", objectPath)
+	}
+
+	if !manager.RefreshCalled {
+		t.Error("Expected RefreshCalled to have been set")
+	}
+}
+
+// Test that requesting an error in Refresh actually results in an error.
+func TestFakeDbusManager_Refresh_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailRefresh: true}
+
+	_, err := manager.Refresh("foo")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+
+	if !manager.RefreshCalled {
+		t.Error("Expected RefreshCalled to have been set")
+	}
+}
+
+// Test typical RefreshAll usage.
+func TestFakeDbusManager_RefreshAll(t *testing.T) {
+	manager := &FakeDbusManager{}
+
+	objectPath, err := manager.RefreshAll()
+	if err != nil {
+		t.Fatalf("Unexpected error while refreshing: %s", err)
+	}
+
+	if !objectPath.IsValid() {
+		t.Errorf("Object path was unexpectedly invalid: %s", objectPath)
+	}
+
+	if !manager.RefreshAllCalled {
+		t.Error("Expected RefreshAllCalled to have been set")
+	}
+}
+
+// Test that requesting an error in RefreshAll actually results in an error.
+func TestFakeDbusManager_RefreshAll_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailRefreshAll: true}
+
+	_, err := manager.RefreshAll()
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+
+	if !manager.RefreshAllCalled {
+		t.Error("Expected RefreshAllCalled to have been set")
+	}
+}
+
+// Test typical Cancel usage.
+func TestFakeDbusManager_Cancel(t *testing.T) {
+	manager := &FakeDbusManager{}
+
+	err := manager.Cancel("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while canceling: %s", err)
+	}
+
+	if !manager.CancelCalled {
+		t.Error("Expected CancelCalled to have been set")
+	}
+}
+
+// Test that requesting an error in Cancel actually results in an error.
+func TestFakeDbusManager_Cancel_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailCancel: true}
+
+	err := manager.Cancel("foo")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+
+	if !manager.CancelCalled {
+		t.Error("Expected CancelCalled to have been set")
+	}
+}
+
+// Test typical CancelOperation usage.
+func TestFakeDbusManager_CancelOperation(t *testing.T) {
+	manager := &FakeDbusManager{}
+
+	err := manager.CancelOperation("/foo/1")
+	if err != nil {
+		t.Fatalf("Unexpected error while canceling: %s", err)
+	}
+
+	if !manager.CancelOperationCalled {
+		t.Error("Expected CancelOperationCalled to have been set")
+	}
+}
+
+// Test that requesting an error in CancelOperation actually results in an
+// error.
+func TestFakeDbusManager_CancelOperation_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailCancelOperation: true}
+
+	err := manager.CancelOperation("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical ListOperations usage.
+func TestFakeDbusManager_ListOperations(t *testing.T) {
+	manager := &FakeDbusManager{Operations: map[string]string{"foo": "install"}}
+
+	objectPaths, err := manager.ListOperations()
+	if err != nil {
+		t.Fatalf("Unexpected error while listing operations: %s", err)
+	}
+
+	if !manager.ListOperationsCalled {
+		t.Error("Expected ListOperationsCalled to have been set")
+	}
+
+	if len(objectPaths) != 1 {
+		t.Errorf("Got %d operations, expected 1", len(objectPaths))
+	}
+}
+
+// Test that requesting an error in ListOperations actually results in an
+// error.
+func TestFakeDbusManager_ListOperations_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailListOperations: true}
+
+	_, err := manager.ListOperations()
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical GetOperationForPackage usage.
+func TestFakeDbusManager_GetOperationForPackage(t *testing.T) {
+	manager := &FakeDbusManager{Operations: map[string]string{"foo": "install"}}
+
+	objectPath, kind, err := manager.GetOperationForPackage("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting operation: %s", err)
+	}
+
+	if !manager.GetOperationForPackageCalled {
+		t.Error("Expected GetOperationForPackageCalled to have been set")
+	}
+
+	if objectPath != "/foo/1" || kind != "install" {
+		t.Errorf(`Got operation "%s" (%s), expected "/foo/1" (install)`, objectPath, kind)
+	}
+
+	objectPath, _, err = manager.GetOperationForPackage("bar")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting operation: %s", err)
+	}
+
+	if objectPath != "" {
+		t.Errorf(`Got operation "%s", expected none`, objectPath)
+	}
+}
+
+// Test that requesting an error in GetOperationForPackage actually results in
+// an error.
+func TestFakeDbusManager_GetOperationForPackage_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailGetOperationForPackage: true}
+
+	_, _, err := manager.GetOperationForPackage("foo")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical GetDownloadStatus usage.
+func TestFakeDbusManager_GetDownloadStatus(t *testing.T) {
+	manager := &FakeDbusManager{DownloadRate: 1000, Eta: 42}
+
+	rate, eta, err := manager.GetDownloadStatus("/foo/1")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting download status: %s", err)
+	}
+
+	if !manager.GetDownloadStatusCalled {
+		t.Error("Expected GetDownloadStatusCalled to have been set")
+	}
+
+	if rate != 1000 || eta != 42 {
+		t.Errorf("Got rate %d and ETA %d, expected 1000 and 42", rate, eta)
+	}
+}
+
+// Test that requesting an error in GetDownloadStatus actually results in an
+// error.
+func TestFakeDbusManager_GetDownloadStatus_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailGetDownloadStatus: true}
+
+	_, _, err := manager.GetDownloadStatus("/foo/1")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical FollowDownloadStatus usage.
+func TestFakeDbusManager_FollowDownloadStatus(t *testing.T) {
+	manager := &FakeDbusManager{DownloadRate: 1000, Eta: 42}
+
+	var rate uint64
+	var eta int64
+	err := manager.FollowDownloadStatus("/foo/1", nil, func(newRate uint64, newEta int64) {
+		rate, eta = newRate, newEta
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error while following download status: %s", err)
+	}
+
+	if !manager.FollowDownloadStatusCalled {
+		t.Error("Expected FollowDownloadStatusCalled to have been set")
+	}
+
+	if rate != 1000 || eta != 42 {
+		t.Errorf("Got rate %d and ETA %d, expected 1000 and 42", rate, eta)
+	}
+}
+
+// Test that requesting an error in FollowDownloadStatus actually results in an
+// error.
+func TestFakeDbusManager_FollowDownloadStatus_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailFollowDownloadStatus: true}
+
+	err := manager.FollowDownloadStatus("/foo/1", nil, func(rate uint64, eta int64) {})
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical GetHistory usage.
+func TestFakeDbusManager_GetHistory(t *testing.T) {
+	manager := &FakeDbusManager{History: []operation.HistoryEntry{{Id: "2"}, {Id: "1"}}}
+
+	entries, err := manager.GetHistory(1)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting history: %s", err)
+	}
+
+	if !manager.GetHistoryCalled {
+		t.Error("Expected GetHistoryCalled to have been set")
+	}
+
+	if len(entries) != 1 || entries[0].Id != "2" {
+		t.Errorf("Entries were %v, expected only the newest", entries)
+	}
+}
+
+// Test that requesting an error in GetHistory actually results in an error.
+func TestFakeDbusManager_GetHistory_failureRequest(t *testing.T) {
+	manager := &FakeDbusManager{FailGetHistory: true}
+
+	_, err := manager.GetHistory(0)
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
@@ -0,0 +1,45 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package fakes
+
+import (
+	"fmt"
+)
+
+// FakeUrlDispatcher is a fake implementation of the UrlDispatcher interface,
+// for use within tests.
+type FakeUrlDispatcher struct {
+	DispatchUrlCalled bool
+	FailDispatchUrl   bool
+
+	// URI most recently dispatched
+	Uri string
+}
+
+func (dispatcher *FakeUrlDispatcher) DispatchUrl(uri string) error {
+	dispatcher.DispatchUrlCalled = true
+
+	if dispatcher.FailDispatchUrl {
+		return fmt.Errorf("Failed at user request")
+	}
+
+	dispatcher.Uri = uri
+
+	return nil
+}
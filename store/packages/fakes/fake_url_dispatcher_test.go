@@ -0,0 +1,55 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package fakes
+
+import (
+	"testing"
+)
+
+// Test typical DispatchUrl usage.
+func TestFakeUrlDispatcher_dispatchUrl(t *testing.T) {
+	dispatcher := &FakeUrlDispatcher{}
+
+	err := dispatcher.DispatchUrl("application:///foo.desktop")
+	if err != nil {
+		t.Fatalf("Unexpected error while dispatching: %s", err)
+	}
+
+	if !dispatcher.DispatchUrlCalled {
+		t.Error("Expected DispatchUrlCalled to have been set")
+	}
+
+	if dispatcher.Uri != "application:///foo.desktop" {
+		t.Errorf(`Dispatched URI was "%s", expected "application:///foo.desktop"`, dispatcher.Uri)
+	}
+}
+
+// Test that requesting an error in DispatchUrl actually results in an error.
+func TestFakeUrlDispatcher_dispatchUrl_failureRequested(t *testing.T) {
+	dispatcher := &FakeUrlDispatcher{FailDispatchUrl: true}
+
+	err := dispatcher.DispatchUrl("application:///foo.desktop")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+
+	if !dispatcher.DispatchUrlCalled {
+		t.Error("Expected DispatchUrlCalled to have been set")
+	}
+}
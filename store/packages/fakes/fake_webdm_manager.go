@@ -0,0 +1,97 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package fakes
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+)
+
+// FakeWebdmManager is a fake implementation of the WebdmManager interface, for
+// use within tests.
+type FakeWebdmManager struct {
+	QueryCalled bool
+	FailQuery   bool
+
+	FailGetInstalledPackages   bool
+	GetStorePackagesCalled     bool
+	GetUpdatablePackagesCalled bool
+
+	// Section given to the last GetStorePackages call
+	StoreSection string
+
+	// Sections returned by GetStoreSections
+	Sections []string
+
+	// Key: Package ID
+	// Value: Snap returned by Query
+	Snaps map[string]client.Snap
+}
+
+func (manager *FakeWebdmManager) GetInstalledPackages() ([]client.Snap, error) {
+	if manager.FailGetInstalledPackages {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	return nil, nil
+}
+
+func (manager *FakeWebdmManager) GetStorePackages(query string, section string) ([]client.Snap, error) {
+	manager.GetStorePackagesCalled = true
+	manager.StoreSection = section
+
+	return nil, nil
+}
+
+func (manager *FakeWebdmManager) GetStoreSections() ([]string, error) {
+	return manager.Sections, nil
+}
+
+func (manager *FakeWebdmManager) GetUpdatablePackages() ([]client.Snap, error) {
+	manager.GetUpdatablePackagesCalled = true
+
+	return nil, nil
+}
+
+func (manager *FakeWebdmManager) Query(packageId string) (*client.Snap, error) {
+	manager.QueryCalled = true
+
+	if manager.FailQuery {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	snap, ok := manager.Snaps[packageId]
+	if !ok {
+		return nil, fmt.Errorf(`No package with ID "%s"`, packageId)
+	}
+
+	return &snap, nil
+}
+
+func (manager *FakeWebdmManager) UpdateAvailable(snap client.Snap) (bool, error) {
+	return false, nil
+}
+
+func (manager *FakeWebdmManager) Install(packageId string) error {
+	return nil
+}
+
+func (manager *FakeWebdmManager) Uninstall(packageId string) error {
+	return nil
+}
@@ -0,0 +1,102 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package fakes
+
+import (
+	"github.com/snapcore/snapd/client"
+	"testing"
+)
+
+// Test typical Query usage.
+func TestFakeWebdmManager_query(t *testing.T) {
+	manager := &FakeWebdmManager{Snaps: map[string]client.Snap{
+		"foo": {Name: "foo"},
+	}}
+
+	snap, err := manager.Query("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error while querying: %s", err)
+	}
+
+	if !manager.QueryCalled {
+		t.Error("Expected QueryCalled to have been set")
+	}
+
+	if snap.Name != "foo" {
+		t.Errorf(`Snap name was "%s", expected "foo"`, snap.Name)
+	}
+
+	_, err = manager.Query("bar")
+	if err == nil {
+		t.Error("Expected an error due to unknown package")
+	}
+}
+
+// Test that requesting an error in Query actually results in an error.
+func TestFakeWebdmManager_query_failureRequested(t *testing.T) {
+	manager := &FakeWebdmManager{FailQuery: true}
+
+	_, err := manager.Query("foo")
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test that requesting an error in GetInstalledPackages actually results in an
+// error.
+func TestFakeWebdmManager_getInstalledPackages_failureRequested(t *testing.T) {
+	manager := &FakeWebdmManager{FailGetInstalledPackages: true}
+
+	_, err := manager.GetInstalledPackages()
+	if err == nil {
+		t.Error("Expected an error due to failure request")
+	}
+}
+
+// Test typical GetStorePackages usage.
+func TestFakeWebdmManager_getStorePackages(t *testing.T) {
+	manager := &FakeWebdmManager{}
+
+	_, err := manager.GetStorePackages("foo", "games")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting store packages: %s", err)
+	}
+
+	if !manager.GetStorePackagesCalled {
+		t.Error("Expected GetStorePackagesCalled to have been set")
+	}
+
+	if manager.StoreSection != "games" {
+		t.Errorf(`Store section was "%s", expected "games"`, manager.StoreSection)
+	}
+}
+
+// Test typical GetStoreSections usage.
+func TestFakeWebdmManager_getStoreSections(t *testing.T) {
+	manager := &FakeWebdmManager{Sections: []string{"games"}}
+
+	sections, err := manager.GetStoreSections()
+	if err != nil {
+		t.Fatalf("Unexpected error while getting store sections: %s", err)
+	}
+
+	if len(sections) != 1 || sections[0] != "games" {
+		t.Errorf(`Sections were %v, expected [games]`, sections)
+	}
+}
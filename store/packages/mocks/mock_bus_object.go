@@ -31,14 +31,39 @@ type MockBusObject struct {
 	DestinationCalled bool
 	PathCalled        bool
 
-	Method string
-	Args   []interface{}
+	Method   string
+	Args     []interface{}
+	Property string
+
+	// Reply to be returned by Call, instead of the default "/foo/1" object
+	// path.
+	CallBody []interface{}
+	CallErr  error
+
+	// Values to be returned by GetProperty, instead of the default "foo".
+	// Key: Property name
+	Properties  map[string]dbus.Variant
+	PropertyErr error
+
+	// Values to be returned in turn by GetProperty, before falling back to
+	// Properties once used up.
+	// Key: Property name
+	PropertySequences map[string][]dbus.Variant
 }
 
 func (mock *MockBusObject) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
 	mock.CallCalled = true
 	mock.Method = method
 	mock.Args = args
+
+	if mock.CallErr != nil {
+		return &dbus.Call{Err: mock.CallErr}
+	}
+
+	if mock.CallBody != nil {
+		return &dbus.Call{Body: mock.CallBody}
+	}
+
 	return &dbus.Call{Body: []interface{}{dbus.ObjectPath("/foo/1")}}
 }
 
@@ -51,6 +76,21 @@ func (mock *MockBusObject) Go(method string, flags dbus.Flags, ch chan *dbus.Cal
 
 func (mock *MockBusObject) GetProperty(p string) (dbus.Variant, error) {
 	mock.GetPropertyCalled = true
+	mock.Property = p
+
+	if mock.PropertyErr != nil {
+		return dbus.Variant{}, mock.PropertyErr
+	}
+
+	if sequence := mock.PropertySequences[p]; len(sequence) > 0 {
+		mock.PropertySequences[p] = sequence[1:]
+		return sequence[0], nil
+	}
+
+	if value, ok := mock.Properties[p]; ok {
+		return value, nil
+	}
+
 	return dbus.MakeVariant("foo"), nil
 }
 
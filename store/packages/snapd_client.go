@@ -20,14 +20,41 @@ package packages
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/policy"
 )
 
+const (
+	// defaultPollPeriod is how often a change is polled while waiting for it
+	// to become ready.
+	defaultPollPeriod = time.Second
+
+	// defaultSection is the store section shown when neither a query nor a
+	// section is given.
+	defaultSection = "featured"
+
+	// defaultChangeTimeout is how long to wait for a change to become ready
+	// before giving up.
+	defaultChangeTimeout = 10 * time.Minute
+)
+
+// changeGetter is the subset of the snapd client needed to follow a change.
+type changeGetter interface {
+	Change(id string) (*client.Change, error)
+}
+
 // Client is the main struct allowing for communication with the webdm API.
 type SnapdClient struct {
 	snapdClientConfig client.Config
 	snapdClient       *client.Client
+
+	pollPeriod    time.Duration
+	changeTimeout time.Duration
+
+	// Policy deciding which store packages are shown
+	policy *policy.Policy
 }
 
 // NewClient creates a new client for communicating with the webdm API
@@ -39,31 +66,41 @@ type SnapdClient struct {
 // - Pointer to new client
 // - Error (nil if none)
 func NewSnapdClient() (*SnapdClient, error) {
-	snapd := &SnapdClient{}
+	snapd := &SnapdClient{
+		pollPeriod:    defaultPollPeriod,
+		changeTimeout: defaultChangeTimeout,
+	}
 	snapd.snapdClient = client.New(&snapd.snapdClientConfig)
 
+	var err error
+	snapd.policy, err = policy.Load(policy.DefaultPath)
+	if err != nil {
+		return nil, fmt.Errorf("snapd: Unable to load policy: %s", err)
+	}
+
 	return snapd, nil
 }
 
 // GetInstalledPackages sends an API request for a list of installed packages.
 //
-// Parameters:
-// query: Search query for list.
-//
 // Returns:
-// - Slice of Packags structs
+// - Slice of installed snaps
 // - Error (nil of none)
-func (snapd *SnapdClient) GetInstalledPackages() (map[string]struct{}) {
+func (snapd *SnapdClient) GetInstalledPackages() ([]client.Snap, error) {
 	snaps, err := snapd.snapdClient.List(nil)
 	if err != nil {
-		fmt.Printf("snapd: Error getting installed packages: %s", err)
+		return nil, fmt.Errorf("snapd: Error getting installed packages: %s", err)
 	}
 
-	packages := make(map[string]struct{}, 0)
+	packages := make([]client.Snap, 0)
 	for _, snap := range snaps {
-		packages[snap.Name] = struct{}{}
+		// Only show snaps that are of the "app" type.
+		if snap.Type != client.TypeApp {
+			continue
+		}
+		packages = append(packages, *snap)
 	}
-	return packages
+	return packages, nil
 }
 
 // GetStorePackages sends an API request for a list of all packages in the
@@ -71,21 +108,29 @@ func (snapd *SnapdClient) GetInstalledPackages() (map[string]struct{}) {
 //
 // Parameters:
 // query: Search query for list.
+// section: Store section to which the list is restricted (empty for all).
 //
 // Returns:
 // - Slice of Packags structs
 // - Error (nil of none)
-func (snapd *SnapdClient) GetStorePackages(query string) ([]client.Snap, error) {
-	if query == "" {
-		query = "."
-	}
-	snaps, _, err := snapd.snapdClient.Find(&client.FindOptions{
-		Query: query,
-	})
+func (snapd *SnapdClient) GetStorePackages(query string, section string) ([]client.Snap, error) {
+	snaps, _, err := snapd.snapdClient.Find(storeFindOptions(query, section))
 	if err != nil {
 		return nil, fmt.Errorf("snapd: Error getting store packages: %s", err)
 	}
 
+	return storePackages(snaps, snapd.policy), nil
+}
+
+// storePackages filters store snaps down to the ones that can be shown.
+//
+// Parameters:
+// snaps: Snaps found in the store.
+// installPolicy: Policy deciding which snaps may be installed (nil for all).
+//
+// Returns:
+// - Slice of snaps to show
+func storePackages(snaps []*client.Snap, installPolicy *policy.Policy) []client.Snap {
 	packages := make([]client.Snap, 0)
 	for _, snap := range snaps {
 		// Only show snaps that are of the "app" type.
@@ -96,9 +141,49 @@ func (snapd *SnapdClient) GetStorePackages(query string) ([]client.Snap, error)
 		if len(snap.Prices) != 0 {
 			continue
 		}
+		// Hide snaps that aren't allowed to be installed anyway
+		if installPolicy.Check(snap.Name, snap.Developer) != nil {
+			continue
+		}
 		packages = append(packages, *snap)
 	}
-	return packages, nil
+	return packages
+}
+
+// GetStoreSections sends an API request for the list of store sections.
+//
+// Returns:
+// - Slice of section names
+// - Error (nil of none)
+func (snapd *SnapdClient) GetStoreSections() ([]string, error) {
+	sections, err := snapd.snapdClient.Sections()
+	if err != nil {
+		return nil, fmt.Errorf("snapd: Error getting store sections: %s", err)
+	}
+
+	return sections, nil
+}
+
+// GetUpdatablePackages sends API requests for the installed packages that have
+// a newer revision available in the store.
+//
+// Returns:
+// - Slice of installed snaps that can be updated
+// - Error (nil if none)
+func (snapd *SnapdClient) GetUpdatablePackages() ([]client.Snap, error) {
+	installed, err := snapd.snapdClient.List(nil)
+	if err != nil {
+		return nil, fmt.Errorf("snapd: Error getting installed packages: %s", err)
+	}
+
+	storeSnaps, _, err := snapd.snapdClient.Find(&client.FindOptions{
+		Refresh: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snapd: Error getting package updates: %s", err)
+	}
+
+	return updatablePackages(installed, storeSnaps), nil
 }
 
 func (snapd *SnapdClient) Query(snapName string) (*client.Snap, error) {
@@ -114,10 +199,181 @@ func (snapd *SnapdClient) Query(snapName string) (*client.Snap, error) {
 	return pkg, nil
 }
 
+// UpdateAvailable checks whether the store has a newer revision of an installed
+// snap.
+//
+// Parameters:
+// snap: Installed snap to check.
+//
+// Returns:
+// - Whether or not a newer revision is available in the store.
+// - Error (nil if none)
+func (snapd *SnapdClient) UpdateAvailable(snap client.Snap) (bool, error) {
+	storeSnap, _, err := snapd.snapdClient.FindOne(snap.Name)
+	if err != nil {
+		return false, fmt.Errorf("snapd: Error getting store package: %s", err)
+	}
+
+	return newerRevision(snap, *storeSnap), nil
+}
+
+// Install requests that snapd install the given package, and waits for the
+// resulting change to complete.
+//
+// Parameters:
+// packageId: ID of the package to install.
+//
+// Returns:
+// - Error (nil if none). This is a *RequestError if snapd refused to start the
+//   change, a *ChangeError if the change failed, or a *TimeoutError if the
+//   change didn't complete in time.
 func (snapd *SnapdClient) Install(packageId string) error {
-	return nil
+	changeId, err := snapd.snapdClient.Install(packageId, &client.SnapOptions{})
+	if err != nil {
+		return newRequestError("install", packageId, err)
+	}
+
+	return waitForChange(snapd.snapdClient, "install", packageId, changeId,
+		snapd.pollPeriod, snapd.changeTimeout)
 }
 
+// Uninstall requests that snapd remove the given package, and waits for the
+// resulting change to complete.
+//
+// Parameters:
+// packageId: ID of the package to uninstall.
+//
+// Returns:
+// - Error (nil if none). See Install for the possible error types.
 func (snapd *SnapdClient) Uninstall(packageId string) error {
-	return nil
+	changeId, err := snapd.snapdClient.Remove(packageId, &client.SnapOptions{})
+	if err != nil {
+		return newRequestError("remove", packageId, err)
+	}
+
+	return waitForChange(snapd.snapdClient, "remove", packageId, changeId,
+		snapd.pollPeriod, snapd.changeTimeout)
+}
+
+// newRequestError creates a RequestError from an error returned by snapd when
+// requesting a change.
+func newRequestError(operation string, packageId string, err error) *RequestError {
+	requestError := &RequestError{
+		Operation: operation,
+		PackageId: packageId,
+		Message:   err.Error(),
+	}
+
+	if snapdError, ok := err.(*client.Error); ok {
+		requestError.Kind = string(snapdError.Kind)
+		requestError.Message = snapdError.Message
+	}
+
+	return requestError
+}
+
+// waitForChange polls snapd until the given change is ready.
+//
+// Errors talking to snapd while polling are not fatal: snapd may be restarting
+// as part of the change. They only cause a failure once the timeout expires.
+//
+// Parameters:
+// getter: Client used to poll the change.
+// operation: Operation being performed ("install", "remove").
+// packageId: ID of the package the change is operating on.
+// changeId: ID of the snapd change to wait for.
+// pollPeriod: Time to sleep between polls.
+// timeout: Maximum time to wait for the change.
+//
+// Returns:
+// - Error (nil if the change completed successfully).
+func waitForChange(getter changeGetter, operation string, packageId string,
+	changeId string, pollPeriod time.Duration, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		change, err := getter.Change(changeId)
+		if err == nil && change.Ready {
+			if change.Status == "Done" {
+				return nil
+			}
+
+			return &ChangeError{
+				Operation: operation,
+				PackageId: packageId,
+				ChangeId:  changeId,
+				Status:    change.Status,
+				Message:   change.Err,
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return &TimeoutError{
+				Operation: operation,
+				PackageId: packageId,
+				ChangeId:  changeId,
+				Timeout:   timeout,
+			}
+		}
+
+		time.Sleep(pollPeriod)
+	}
+}
+
+// newerRevision checks whether a store snap is a newer revision of an installed
+// snap. Snaps installed locally (i.e. with a negative revision) never have
+// updates in the store.
+func newerRevision(installed client.Snap, store client.Snap) bool {
+	return installed.Revision.N > 0 && store.Revision.N > installed.Revision.N
+}
+
+// updatablePackages filters a list of installed snaps down to the app snaps for
+// which the store has a newer revision.
+//
+// Parameters:
+// installed: Installed snaps, as returned by snapd's List.
+// store: Store snaps, as returned by snapd's Find.
+//
+// Returns:
+// - Slice of installed snaps that can be updated
+func updatablePackages(installed []*client.Snap, store []*client.Snap) []client.Snap {
+	storeSnaps := make(map[string]client.Snap)
+	for _, snap := range store {
+		storeSnaps[snap.Name] = *snap
+	}
+
+	packages := make([]client.Snap, 0)
+	for _, snap := range installed {
+		// Only show snaps that are of the "app" type.
+		if snap.Type != client.TypeApp {
+			continue
+		}
+
+		storeSnap, ok := storeSnaps[snap.Name]
+		if ok && newerRevision(*snap, storeSnap) {
+			packages = append(packages, *snap)
+		}
+	}
+
+	return packages
+}
+
+// storeFindOptions creates the options for finding store packages matching a
+// query within a section. If neither is given, the default section is used.
+//
+// Parameters:
+// query: Search query.
+// section: Store section (empty for all).
+//
+// Returns:
+// - Pointer to find options
+func storeFindOptions(query string, section string) *client.FindOptions {
+	if query == "" && section == "" {
+		section = defaultSection
+	}
+
+	return &client.FindOptions{
+		Query:   query,
+		Section: section,
+	}
 }
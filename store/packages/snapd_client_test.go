@@ -0,0 +1,252 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+	"launchpad.net/unity-scope-snappy/policy"
+	"testing"
+	"time"
+)
+
+// fakeChangeGetter returns the given changes in order, one per call, repeating
+// the last one forever.
+type fakeChangeGetter struct {
+	changes []*client.Change
+	calls   int
+}
+
+func (getter *fakeChangeGetter) Change(id string) (*client.Change, error) {
+	index := getter.calls
+	getter.calls++
+
+	if index >= len(getter.changes) {
+		index = len(getter.changes) - 1
+	}
+
+	change := getter.changes[index]
+	if change == nil {
+		return nil, fmt.Errorf("Failed at user request")
+	}
+
+	return change, nil
+}
+
+// Test typical NewSnapdClient usage.
+func TestNewSnapdClient(t *testing.T) {
+	snapd, err := NewSnapdClient()
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+
+	if snapd.pollPeriod != defaultPollPeriod {
+		t.Errorf("Poll period was %s, expected %s", snapd.pollPeriod, defaultPollPeriod)
+	}
+
+	if snapd.changeTimeout != defaultChangeTimeout {
+		t.Errorf("Change timeout was %s, expected %s", snapd.changeTimeout, defaultChangeTimeout)
+	}
+}
+
+// Test that waiting for a change that completes successfully succeeds.
+func TestWaitForChange(t *testing.T) {
+	getter := &fakeChangeGetter{changes: []*client.Change{
+		{Status: "Doing"},
+		nil, // Simulate snapd going away temporarily
+		{Status: "Done", Ready: true},
+	}}
+
+	err := waitForChange(getter, "install", "foo", "1", time.Millisecond, time.Minute)
+	if err != nil {
+		t.Errorf("Unexpected error waiting for change: %s", err)
+	}
+
+	if getter.calls != 3 {
+		t.Errorf("Change was polled %d times, expected 3", getter.calls)
+	}
+}
+
+// Test that a change ending in error results in a ChangeError.
+func TestWaitForChange_changeError(t *testing.T) {
+	getter := &fakeChangeGetter{changes: []*client.Change{
+		{Status: "Error", Ready: true, Err: "bar"},
+	}}
+
+	err := waitForChange(getter, "install", "foo", "1", time.Millisecond, time.Minute)
+
+	changeError, ok := err.(*ChangeError)
+	if !ok {
+		t.Fatalf("Error was %#v, expected a ChangeError", err)
+	}
+
+	if changeError.PackageId != "foo" {
+		t.Errorf(`Package ID was "%s", expected "foo"`, changeError.PackageId)
+	}
+
+	if changeError.Status != "Error" {
+		t.Errorf(`Status was "%s", expected "Error"`, changeError.Status)
+	}
+
+	if changeError.Message != "bar" {
+		t.Errorf(`Message was "%s", expected "bar"`, changeError.Message)
+	}
+}
+
+// Test that a change that never becomes ready results in a TimeoutError.
+func TestWaitForChange_timeout(t *testing.T) {
+	getter := &fakeChangeGetter{changes: []*client.Change{{Status: "Doing"}}}
+
+	err := waitForChange(getter, "remove", "foo", "1", time.Millisecond, 10*time.Millisecond)
+
+	timeoutError, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("Error was %#v, expected a TimeoutError", err)
+	}
+
+	if timeoutError.ChangeId != "1" {
+		t.Errorf(`Change ID was "%s", expected "1"`, timeoutError.ChangeId)
+	}
+}
+
+// Test that snapd request errors are converted into RequestErrors.
+func TestNewRequestError(t *testing.T) {
+	err := newRequestError("install", "foo", &client.Error{Kind: "snap-not-found", Message: "bar"})
+
+	if err.Kind != "snap-not-found" {
+		t.Errorf(`Kind was "%s", expected "snap-not-found"`, err.Kind)
+	}
+
+	if err.Message != "bar" {
+		t.Errorf(`Message was "%s", expected "bar"`, err.Message)
+	}
+
+	err = newRequestError("install", "foo", fmt.Errorf("baz"))
+	if err.Message != "baz" {
+		t.Errorf(`Message was "%s", expected "baz"`, err.Message)
+	}
+}
+
+// Data for TestNewerRevision
+var newerRevisionTests = []struct {
+	installed int
+	store     int
+	expected  bool
+}{
+	{1, 2, true},
+	{2, 2, false},
+	{3, 2, false},
+	{-1, 2, false}, // Locally installed snaps aren't updated from the store
+}
+
+// Test that store revisions are correctly compared to installed revisions.
+func TestNewerRevision(t *testing.T) {
+	for i, test := range newerRevisionTests {
+		installed := client.Snap{Revision: snap.R(test.installed)}
+		store := client.Snap{Revision: snap.R(test.store)}
+
+		if newerRevision(installed, store) != test.expected {
+			t.Errorf("Test case %d: Expected newerRevision to return %t", i, test.expected)
+		}
+	}
+}
+
+// Test that only installed apps with newer store revisions are updatable.
+func TestUpdatablePackages(t *testing.T) {
+	installed := []*client.Snap{
+		{Name: "foo", Type: client.TypeApp, Revision: snap.R(1)},
+		{Name: "bar", Type: client.TypeApp, Revision: snap.R(2)},
+		{Name: "baz", Type: client.TypeApp, Revision: snap.R(1)},
+		{Name: "core", Type: client.TypeOS, Revision: snap.R(1)},
+	}
+
+	store := []*client.Snap{
+		{Name: "foo", Type: client.TypeApp, Revision: snap.R(2)},
+		{Name: "bar", Type: client.TypeApp, Revision: snap.R(2)},
+		{Name: "core", Type: client.TypeOS, Revision: snap.R(2)},
+	}
+
+	packages := updatablePackages(installed, store)
+	if len(packages) != 1 {
+		t.Fatalf("Got %d updatable packages, expected 1", len(packages))
+	}
+
+	if packages[0].Name != "foo" {
+		t.Errorf(`Updatable package was "%s", expected "foo"`, packages[0].Name)
+	}
+
+	if packages[0].Revision.N != 1 {
+		t.Errorf("Updatable package revision was %d, expected the installed revision 1", packages[0].Revision.N)
+	}
+}
+
+// Test that only free apps allowed by the policy are shown.
+func TestStorePackages(t *testing.T) {
+	snaps := []*client.Snap{
+		{Name: "foo", Type: client.TypeApp, Developer: "canonical"},
+		{Name: "bar", Type: client.TypeApp, Developer: "someone"},
+		{Name: "baz", Type: client.TypeApp, Developer: "canonical", Prices: map[string]float64{"USD": 1}},
+		{Name: "core", Type: client.TypeOS, Developer: "canonical"},
+	}
+
+	packages := storePackages(snaps, nil)
+	if len(packages) != 2 {
+		t.Errorf("Got %d packages without policy, expected 2", len(packages))
+	}
+
+	installPolicy := &policy.Policy{Allow: policy.Rules{Publishers: []string{"canonical"}}}
+
+	packages = storePackages(snaps, installPolicy)
+	if len(packages) != 1 {
+		t.Fatalf("Got %d packages, expected 1", len(packages))
+	}
+
+	if packages[0].Name != "foo" {
+		t.Errorf(`Package was "%s", expected "foo"`, packages[0].Name)
+	}
+}
+
+// Data for TestStoreFindOptions
+var storeFindOptionsTests = []struct {
+	query           string
+	section         string
+	expectedQuery   string
+	expectedSection string
+}{
+	{"", "", "", defaultSection},
+	{"", "games", "", "games"},
+	{"foo", "", "foo", ""},
+	{"foo", "games", "foo", "games"},
+}
+
+// Test that find options default to the featured section.
+func TestStoreFindOptions(t *testing.T) {
+	for i, test := range storeFindOptionsTests {
+		options := storeFindOptions(test.query, test.section)
+
+		if options.Query != test.expectedQuery {
+			t.Errorf(`Test case %d: Query was "%s", expected "%s"`, i, options.Query, test.expectedQuery)
+		}
+
+		if options.Section != test.expectedSection {
+			t.Errorf(`Test case %d: Section was "%s", expected "%s"`, i, options.Section, test.expectedSection)
+		}
+	}
+}
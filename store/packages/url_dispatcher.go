@@ -0,0 +1,85 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+)
+
+const (
+	defaultUrlDispatcherObject = "com.canonical.URLDispatcher"
+	defaultUrlDispatcherPath   = "/com/canonical/URLDispatcher"
+	defaultDispatchUrlMethod   = "com.canonical.URLDispatcher.DispatchURL"
+)
+
+// UrlDispatcher is an interface to be implemented by any struct that can open
+// URIs on behalf of the scope, e.g. to launch apps.
+type UrlDispatcher interface {
+	DispatchUrl(uri string) error
+}
+
+// DbusUrlDispatcher is a DBus client for communicating with the URL Dispatcher
+// service.
+type DbusUrlDispatcher struct {
+	connection DbusConnection // Connection to the dbus bus
+
+	dbusObject        string
+	dbusPath          dbus.ObjectPath
+	dispatchUrlMethod string
+}
+
+// NewDbusUrlDispatcher creates a new DbusUrlDispatcher.
+func NewDbusUrlDispatcher() *DbusUrlDispatcher {
+	dispatcher := new(DbusUrlDispatcher)
+
+	dispatcher.dbusObject = defaultUrlDispatcherObject
+	dispatcher.dbusPath = defaultUrlDispatcherPath
+	dispatcher.dispatchUrlMethod = defaultDispatchUrlMethod
+
+	return dispatcher
+}
+
+// Connect simply initializes a connection to the DBus session bus.
+//
+// Returns:
+// - Error (nil if none)
+func (dispatcher *DbusUrlDispatcher) Connect() error {
+	var err error
+	dispatcher.connection, err = dbus.SessionBus()
+	return err
+}
+
+// DispatchUrl requests that the URL Dispatcher open the given URI.
+//
+// Parameters:
+// uri: The URI to open (e.g. "application:///foo.desktop").
+//
+// Returns:
+// - Error (nil if none).
+func (dispatcher *DbusUrlDispatcher) DispatchUrl(uri string) error {
+	if dispatcher.connection == nil {
+		return fmt.Errorf("Dispatcher is not connected")
+	}
+
+	busObject := dispatcher.connection.Object(dispatcher.dbusObject, dispatcher.dbusPath)
+
+	// The second argument restricts the URI to a package, which we don't need
+	return busObject.Call(dispatcher.dispatchUrlMethod, 0, uri, "").Err
+}
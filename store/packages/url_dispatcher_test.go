@@ -0,0 +1,71 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package packages
+
+import (
+	"github.com/godbus/dbus"
+	"launchpad.net/unity-scope-snappy/store/packages/fakes"
+	"launchpad.net/unity-scope-snappy/store/packages/mocks"
+	"testing"
+)
+
+// Test typical DispatchUrl usage.
+func TestDbusUrlDispatcher_dispatchUrl(t *testing.T) {
+	dispatcher := NewDbusUrlDispatcher()
+	mockObject := &mocks.MockBusObject{CallBody: []interface{}{}}
+	dispatcher.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := dispatcher.DispatchUrl("application:///foo.desktop")
+	if err != nil {
+		t.Errorf("Unexpected error dispatching: %s", err)
+	}
+
+	if mockObject.Method != dispatcher.dispatchUrlMethod {
+		t.Errorf(`Dispatcher called method "%s", expected "%s"`, mockObject.Method, dispatcher.dispatchUrlMethod)
+	}
+
+	if len(mockObject.Args) != 2 {
+		t.Fatalf("Got %d arguments, expected 2", len(mockObject.Args))
+	}
+
+	if mockObject.Args[0] != "application:///foo.desktop" {
+		t.Errorf(`DispatchURL was called with "%s", expected "application:///foo.desktop"`, mockObject.Args[0])
+	}
+}
+
+// Test that a failure to dispatch results in an error.
+func TestDbusUrlDispatcher_dispatchUrl_failure(t *testing.T) {
+	dispatcher := NewDbusUrlDispatcher()
+	mockObject := &mocks.MockBusObject{CallErr: dbus.Error{Name: "foo"}}
+	dispatcher.connection = fakes.FakeDbusConnection{DbusObject: mockObject}
+
+	err := dispatcher.DispatchUrl("application:///foo.desktop")
+	if err == nil {
+		t.Error("Expected an error due to failure to dispatch")
+	}
+}
+
+// Test that trying to dispatch before connecting results in an error.
+func TestDbusUrlDispatcher_dispatchUrl_beforeConnect(t *testing.T) {
+	dispatcher := NewDbusUrlDispatcher()
+	err := dispatcher.DispatchUrl("application:///foo.desktop")
+	if err == nil {
+		t.Error("Expected an error due to dispatch before connect")
+	}
+}
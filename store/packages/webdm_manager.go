@@ -25,9 +25,12 @@ import (
 // WebdmManager is an interface to be implemented by any struct that supports
 // the type of package management needed by this scope.
 type WebdmManager interface {
-	GetInstalledPackages() (map[string]struct{})
-	GetStorePackages(query string) ([]client.Snap, error)
+	GetInstalledPackages() ([]client.Snap, error)
+	GetStorePackages(query string, section string) ([]client.Snap, error)
+	GetStoreSections() ([]string, error)
+	GetUpdatablePackages() ([]client.Snap, error)
 	Query(packageId string) (*client.Snap, error)
+	UpdateAvailable(snap client.Snap) (bool, error)
 	Install(packageId string) error
 	Uninstall(packageId string) error
 }
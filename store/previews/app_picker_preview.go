@@ -0,0 +1,89 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package previews
+
+import (
+	"fmt"
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/packages"
+	"launchpad.net/unity-scope-snappy/store/previews/interfaces"
+)
+
+// AppPickerPreview is a PreviewGenerator meant to have the user pick which of
+// a package's apps to open.
+type AppPickerPreview struct {
+	snap client.Snap
+}
+
+// NewAppPickerPreview creates a new AppPickerPreview.
+//
+// Parameters:
+// snap: Package whose apps are to be picked from.
+func NewAppPickerPreview(snap client.Snap) *AppPickerPreview {
+	return &AppPickerPreview{snap: snap}
+}
+
+// Generate pushes the template's preview widgets onto a WidgetReceiver.
+//
+// Parameters:
+// receiver: Implementation of the WidgetReceiver interface.
+//
+// Returns:
+// - Error (nil if none)
+func (preview AppPickerPreview) Generate(receiver interfaces.WidgetReceiver) error {
+	receiver.PushWidgets(preview.textWidget())
+	receiver.PushWidgets(preview.actionsWidget())
+
+	return nil
+}
+
+// textWidget is used to create a text widget asking which app to open.
+//
+// Returns:
+// - Text preview widget for the question.
+func (preview AppPickerPreview) textWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("pick", "text")
+
+	widget.AddAttributeValue("text", fmt.Sprintf("Which app of %s do you want to open?", preview.snap.Name))
+
+	return widget
+}
+
+// actionsWidget is used to create an action widget opening each app. Each
+// action names the app it opens.
+//
+// Returns:
+// - Action preview widget for the apps.
+func (preview AppPickerPreview) actionsWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("apps", "actions")
+
+	previewActions := make([]interface{}, 0)
+	for _, app := range packages.LaunchableApps(preview.snap) {
+		openAction := make(map[string]interface{})
+		openAction["id"] = actions.OpenAppAction(app.Name)
+		openAction["label"] = app.Name
+		previewActions = append(previewActions, openAction)
+	}
+
+	widget.AddAttributeValue("actions", previewActions)
+
+	return widget
+}
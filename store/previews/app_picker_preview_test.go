@@ -0,0 +1,75 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package previews
+
+import (
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/previews/fakes"
+	"testing"
+)
+
+// Test typical Generate usage, and verify that it offers each app.
+func TestAppPickerPreview_generate(t *testing.T) {
+	snap := client.Snap{Name: "package1", Apps: []client.AppInfo{
+		{Name: "foo", DesktopFile: "/var/lib/snapd/desktop/applications/package1_foo.desktop"},
+		{Name: "bar", DesktopFile: "/var/lib/snapd/desktop/applications/package1_bar.desktop"},
+		{Name: "baz", Daemon: "simple"},
+	}}
+	preview := NewAppPickerPreview(snap)
+
+	receiver := new(fakes.FakeWidgetReceiver)
+
+	err := preview.Generate(receiver)
+	if err != nil {
+		t.Errorf("Unexpected error while generating preview: %s", err)
+	}
+
+	if len(receiver.Widgets) != 2 {
+		// Exit here so we don't index out of bounds later
+		t.Fatalf("Got %d widgets, expected 2", len(receiver.Widgets))
+	}
+
+	widget := receiver.Widgets[0]
+	if widget.WidgetType() != "text" {
+		t.Error("Expected text to be first widget")
+	}
+
+	widget = receiver.Widgets[1]
+	if widget.WidgetType() != "actions" {
+		t.Fatal("Expected actions to be second widget")
+	}
+
+	actionsInterfaces := widget["actions"].([]interface{})
+	if len(actionsInterfaces) != 2 {
+		t.Fatalf("Actions widget had %d actions, expected 2", len(actionsInterfaces))
+	}
+
+	expectedLabels := []string{"foo", "bar"}
+	expectedIds := []actions.ActionId{actions.OpenAppAction("foo"), actions.OpenAppAction("bar")}
+	for i, actionInterface := range actionsInterfaces {
+		action := actionInterface.(map[string]interface{})
+		if action["id"] != expectedIds[i] {
+			t.Errorf(`Action %d: ID was "%s", expected "%s"`, i, action["id"], expectedIds[i])
+		}
+		if action["label"] != expectedLabels[i] {
+			t.Errorf(`Action %d: Label was "%s", expected "%s"`, i, action["label"], expectedLabels[i])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package humanize
+
+import (
+	"fmt"
+)
+
+// Duration translates a raw number of seconds into a human-readable
+// representation, rounded up to the minute once longer than a minute.
+//
+// Parameters:
+// seconds: Number of seconds
+//
+// Returns:
+// - Humanized duration in a string ("Unknown" if `seconds` is negative)
+func Duration(seconds int64) string {
+	if seconds < 0 {
+		return "Unknown"
+	}
+
+	if seconds < 60 {
+		return plural(seconds, "second")
+	}
+
+	minutes := (seconds + 59) / 60
+	if minutes < 60 {
+		return plural(minutes, "minute")
+	}
+
+	hours := minutes / 60
+	minutes %= 60
+	if minutes == 0 {
+		return plural(hours, "hour")
+	}
+
+	return plural(hours, "hour") + " " + plural(minutes, "minute")
+}
+
+// Rate translates a raw number of bytes per second into a human-readable
+// representation.
+//
+// Parameters:
+// bytesPerSecond: Number of bytes per second
+//
+// Returns:
+// - Humanized rate in a string ("Unknown" if `bytesPerSecond` is negative)
+func Rate(bytesPerSecond int64) string {
+	if bytesPerSecond < 0 {
+		return "Unknown"
+	}
+
+	return Bytes(bytesPerSecond) + "/s"
+}
+
+// plural formats a count along with its unit, pluralized if necessary.
+func plural(count int64, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, unit)
+	}
+
+	return fmt.Sprintf("%d %ss", count, unit)
+}
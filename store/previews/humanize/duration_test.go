@@ -0,0 +1,67 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package humanize
+
+import (
+	"testing"
+)
+
+// Data for TestDuration
+var durationTests = []struct {
+	seconds           int64
+	expectedHumanized string
+}{
+	{-1, "Unknown"},
+	{0, "0 seconds"},
+	{1, "1 second"},
+	{59, "59 seconds"},
+	{60, "1 minute"},
+	{61, "2 minutes"},
+	{3600, "1 hour"},
+	{3660, "1 hour 1 minute"},
+	{7500, "2 hours 5 minutes"},
+}
+
+func TestDuration(t *testing.T) {
+	for i, test := range durationTests {
+		humanized := Duration(test.seconds)
+		if humanized != test.expectedHumanized {
+			t.Errorf("Test case %d: Got %s, expected %s", i, humanized, test.expectedHumanized)
+		}
+	}
+}
+
+// Data for TestRate
+var rateTests = []struct {
+	bytesPerSecond    int64
+	expectedHumanized string
+}{
+	{-1, "Unknown"},
+	{0, "0 B/s"},
+	{1520435, "1.5 MB/s"},
+}
+
+func TestRate(t *testing.T) {
+	for i, test := range rateTests {
+		humanized := Rate(test.bytesPerSecond)
+		if humanized != test.expectedHumanized {
+			t.Errorf("Test case %d: Got %s, expected %s", i, humanized, test.expectedHumanized)
+		}
+	}
+}
@@ -35,7 +35,10 @@ type Preview struct {
 //
 // Parameters:
 // snap: Package to be represented by the preview.
-func NewPreview(snap client.Snap, result *scopes.Result, metadata operation.Metadata) (*Preview, error) {
+// result: Search result the preview was requested for.
+// metadata: Metadata of any operation in progress on the package.
+// updateAvailable: Whether the store has a newer revision of the package.
+func NewPreview(snap client.Snap, result *scopes.Result, metadata operation.Metadata, updateAvailable bool) (*Preview, error) {
 	preview := new(Preview)
 	var err error
 
@@ -48,17 +51,39 @@ func NewPreview(snap client.Snap, result *scopes.Result, metadata operation.Meta
 		preview.template, err = templates.NewInstallingTemplate(snap, result, metadata.ObjectPath)
 	} else if metadata.UninstallConfirmed && installed {
 		preview.template, err = templates.NewUninstallingTemplate(snap, metadata.ObjectPath)
+	} else if metadata.RefreshRequested && installed {
+		preview.template, err = templates.NewRefreshingTemplate(snap, metadata.ObjectPath)
 	} else {
-		if installed {
+		if installed && updateAvailable {
+			preview.template, err = templates.NewUpdatableTemplate(snap)
+		} else if installed {
 			preview.template, err = templates.NewInstalledTemplate(snap)
 		} else {
 			preview.template, err = templates.NewStoreTemplate(snap, result)
 		}
 	}
 
+	// Explain the failure of the operation on top of the package's state
+	if err == nil && metadata.Failed {
+		preview.template, err = templates.NewFailedTemplate(preview.template,
+			metadata.FailedOperation, metadata.ErrorMessage, metadata.RetryCount)
+	}
+
 	return preview, err
 }
 
+// SetDownloadStatus records the download rate and estimated time remaining,
+// for templates representing a package being downloaded.
+//
+// Parameters:
+// rate: Download rate in bytes per second (0 if unknown).
+// eta: Estimated time remaining in seconds (-1 if unknown).
+func (preview Preview) SetDownloadStatus(rate uint64, eta int64) {
+	if downloading, ok := preview.template.(templates.DownloadStatusTemplate); ok {
+		downloading.SetDownloadStatus(rate, eta)
+	}
+}
+
 // Generate pushes the template's preview widgets onto a WidgetReceiver.
 //
 // Parameters:
@@ -68,7 +93,17 @@ func NewPreview(snap client.Snap, result *scopes.Result, metadata operation.Meta
 // - Error (nil if none)
 func (preview Preview) Generate(receiver interfaces.WidgetReceiver) error {
 	receiver.PushWidgets(preview.template.HeaderWidget())
+	if failed, ok := preview.template.(templates.ErrorTemplate); ok {
+		receiver.PushWidgets(failed.ErrorWidget())
+		receiver.PushWidgets(failed.ErrorActionsWidget())
+	}
 	receiver.PushWidgets(preview.template.ActionsWidget())
+	if downloading, ok := preview.template.(templates.DownloadStatusTemplate); ok {
+		receiver.PushWidgets(downloading.DownloadStatusWidget())
+	}
+	if cancelable, ok := preview.template.(templates.CancelableTemplate); ok {
+		receiver.PushWidgets(cancelable.CancelWidget())
+	}
 	receiver.PushWidgets(preview.template.InfoWidget())
 	receiver.PushWidgets(preview.template.UpdatesWidget())
 
@@ -31,30 +31,47 @@ var (
 	emptyMetadata     = operation.Metadata{}
 	installMetadata   = operation.Metadata{InstallRequested: true, ObjectPath: "/foo/1"}
 	uninstallMetadata = operation.Metadata{UninstallConfirmed: true, ObjectPath: "/foo/1"}
+	refreshMetadata   = operation.Metadata{RefreshRequested: true, ObjectPath: "/foo/1"}
+	failedMetadata    = operation.Metadata{Failed: true, FailedOperation: "install", ErrorMessage: "foo"}
 )
 
 // Data for both TestNewPreview and TestPreview_generate.
 var previewTests = []struct {
 	status           string
 	metadata         operation.Metadata
+	updateAvailable  bool
 	expectedTemplate interface{}
 }{
 	// No metadata
-	{client.StatusInstalled, emptyMetadata, &templates.InstalledTemplate{}},
-	{client.StatusAvailable, emptyMetadata, &templates.StoreTemplate{}},
-	{client.StatusRemoved, emptyMetadata, &templates.StoreTemplate{}},
-	{client.StatusActive, emptyMetadata, &templates.InstalledTemplate{}},
+	{client.StatusInstalled, emptyMetadata, false, &templates.InstalledTemplate{}},
+	{client.StatusAvailable, emptyMetadata, false, &templates.StoreTemplate{}},
+	{client.StatusRemoved, emptyMetadata, false, &templates.StoreTemplate{}},
+	{client.StatusActive, emptyMetadata, false, &templates.InstalledTemplate{}},
 
 	// Metadata requesting install
-	{client.StatusInstalled, installMetadata, &templates.InstalledTemplate{}},
-	{client.StatusAvailable, installMetadata, &templates.InstallingTemplate{}},
-	{client.StatusRemoved, installMetadata, &templates.InstallingTemplate{}},
+	{client.StatusInstalled, installMetadata, false, &templates.InstalledTemplate{}},
+	{client.StatusAvailable, installMetadata, false, &templates.InstallingTemplate{}},
+	{client.StatusRemoved, installMetadata, false, &templates.InstallingTemplate{}},
 
 	// Metadata requesting uninstall
-	{client.StatusInstalled, uninstallMetadata, &templates.UninstallingTemplate{}},
-	{client.StatusActive, uninstallMetadata, &templates.UninstallingTemplate{}},
-	{client.StatusAvailable, uninstallMetadata, &templates.StoreTemplate{}},
-	{client.StatusRemoved, uninstallMetadata, &templates.StoreTemplate{}},
+	{client.StatusInstalled, uninstallMetadata, false, &templates.UninstallingTemplate{}},
+	{client.StatusActive, uninstallMetadata, false, &templates.UninstallingTemplate{}},
+	{client.StatusAvailable, uninstallMetadata, false, &templates.StoreTemplate{}},
+	{client.StatusRemoved, uninstallMetadata, false, &templates.StoreTemplate{}},
+
+	// Metadata requesting refresh
+	{client.StatusInstalled, refreshMetadata, true, &templates.RefreshingTemplate{}},
+	{client.StatusActive, refreshMetadata, true, &templates.RefreshingTemplate{}},
+	{client.StatusAvailable, refreshMetadata, false, &templates.StoreTemplate{}},
+
+	// Update available
+	{client.StatusInstalled, emptyMetadata, true, &templates.UpdatableTemplate{}},
+	{client.StatusActive, emptyMetadata, true, &templates.UpdatableTemplate{}},
+	{client.StatusAvailable, emptyMetadata, true, &templates.StoreTemplate{}},
+
+	// Metadata reporting a failure
+	{client.StatusAvailable, failedMetadata, false, &templates.FailedTemplate{}},
+	{client.StatusInstalled, failedMetadata, false, &templates.FailedTemplate{}},
 }
 
 // Test typical NewPreview usage.
@@ -62,7 +79,7 @@ func TestNewPreview(t *testing.T) {
 	for i, test := range previewTests {
 		snap := client.Snap{Status: test.status}
 
-		preview, err := NewPreview(snap, nil, test.metadata)
+		preview, err := NewPreview(snap, nil, test.metadata, test.updateAvailable)
 		if err != nil {
 			t.Errorf("Test case %d: Unexpected error: %s", i, err)
 			continue
@@ -89,7 +106,7 @@ func TestPreview_generate(t *testing.T) {
 			Status:       test.status,
 			DownloadSize: 123456,
 			Type:         "app",
-		}, nil, test.metadata)
+		}, nil, test.metadata, test.updateAvailable)
 		if err != nil {
 			t.Errorf("Test case %d: Unexpected error while creating package preview: %s", i, err)
 			continue
@@ -102,9 +119,28 @@ func TestPreview_generate(t *testing.T) {
 			t.Errorf("Test case %d: Unexpected error while generating preview: %s", i, err)
 		}
 
-		if len(receiver.Widgets) != 4 {
+		// Operations in progress get an additional widget to cancel them
+		expectedWidgets := 4
+		_, cancelable := test.expectedTemplate.(templates.CancelableTemplate)
+		if cancelable {
+			expectedWidgets++
+		}
+
+		// Downloads get an additional widget showing their status
+		_, downloading := test.expectedTemplate.(templates.DownloadStatusTemplate)
+		if downloading {
+			expectedWidgets++
+		}
+
+		// Failures get two additional widgets explaining them
+		_, failed := test.expectedTemplate.(templates.ErrorTemplate)
+		if failed {
+			expectedWidgets += 2
+		}
+
+		if len(receiver.Widgets) != expectedWidgets {
 			// Exit here so we don't index out of bounds later
-			t.Fatalf("Test case %d: Got %d widgets, expected 4", i, len(receiver.Widgets))
+			t.Fatalf("Test case %d: Got %d widgets, expected %d", i, len(receiver.Widgets), expectedWidgets)
 		}
 
 		widget := receiver.Widgets[0]
@@ -112,11 +148,25 @@ func TestPreview_generate(t *testing.T) {
 			t.Errorf("Test case %d: Expected header to be first widget", i)
 		}
 
+		if failed {
+			widget = receiver.Widgets[1]
+			if widget.WidgetType() != "text" {
+				t.Errorf("Test case %d: Expected error to be the second widget", i)
+			}
+
+			widget = receiver.Widgets[2]
+			if widget.WidgetType() != "actions" {
+				t.Errorf("Test case %d: Expected error actions to be the third widget", i)
+			}
+
+			// Skip the error widgets for the remaining checks
+			receiver.Widgets = append(receiver.Widgets[:1], receiver.Widgets[3:]...)
+		}
+
 		widget = receiver.Widgets[1]
 
 		switch test.expectedTemplate.(type) {
-		case *templates.InstallingTemplate:
-		case *templates.UninstallingTemplate:
+		case *templates.InstallingTemplate, *templates.UninstallingTemplate, *templates.RefreshingTemplate:
 			if widget.WidgetType() != "progress" {
 				t.Errorf("Test case %d: Expected progress to be second widget", i)
 			}
@@ -126,6 +176,26 @@ func TestPreview_generate(t *testing.T) {
 			}
 		}
 
+		if downloading {
+			widget = receiver.Widgets[2]
+			if widget.WidgetType() != "text" {
+				t.Errorf("Test case %d: Expected download status to be the third widget", i)
+			}
+
+			// Skip the download status widget for the remaining checks
+			receiver.Widgets = append(receiver.Widgets[:2], receiver.Widgets[3:]...)
+		}
+
+		if cancelable {
+			widget = receiver.Widgets[2]
+			if widget.WidgetType() != "actions" {
+				t.Errorf("Test case %d: Expected cancel actions to be the third widget", i)
+			}
+
+			// Skip the cancel widget for the remaining checks
+			receiver.Widgets = append(receiver.Widgets[:2], receiver.Widgets[3:]...)
+		}
+
 		widget = receiver.Widgets[2]
 		if widget.WidgetType() != "text" {
 			t.Errorf("Test case %d: Expected info to be the third widget", i)
@@ -137,3 +207,26 @@ func TestPreview_generate(t *testing.T) {
 		}
 	}
 }
+
+// Test that the download status is only recorded by templates showing it.
+func TestPreview_setDownloadStatus(t *testing.T) {
+	preview, err := NewPreview(client.Snap{Status: client.StatusAvailable}, nil, installMetadata, false)
+	if err != nil {
+		t.Fatalf("Unexpected error while creating package preview: %s", err)
+	}
+
+	preview.SetDownloadStatus(1520435, 90)
+
+	widget := preview.template.(templates.DownloadStatusTemplate).DownloadStatusWidget()
+	if widget["text"] != "1.5 MB/s, 2 minutes remaining" {
+		t.Errorf(`Download status was "%s", expected "1.5 MB/s, 2 minutes remaining"`, widget["text"])
+	}
+
+	// Templates without download status simply ignore it
+	preview, err = NewPreview(client.Snap{Status: client.StatusInstalled}, nil, emptyMetadata, false)
+	if err != nil {
+		t.Fatalf("Unexpected error while creating package preview: %s", err)
+	}
+
+	preview.SetDownloadStatus(1520435, 90)
+}
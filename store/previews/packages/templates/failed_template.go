@@ -0,0 +1,115 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package templates
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/actions"
+)
+
+// FailedTemplate is a preview template for a package upon which an operation
+// just failed. It explains the failure on top of the package's usual template.
+type FailedTemplate struct {
+	Template
+	operation string // Kind of the operation that failed (empty if unknown)
+	message   string // Reason for the failure (empty if unknown)
+
+	retryCount int // Number of times the operation was already retried
+}
+
+// NewFailedTemplate creates a new FailedTemplate.
+//
+// Parameters:
+// template: Template representing the package in its current state.
+// operation: Kind of the operation that failed ("install", "remove",
+// "refresh", empty if unknown).
+// message: Reason for the failure (empty if unknown).
+// retryCount: Number of times the operation was already retried.
+//
+// Returns:
+// - Pointer to new FailedTemplate (nil if error)
+// - Error (nil if none)
+func NewFailedTemplate(template Template, operation string, message string, retryCount int) (*FailedTemplate, error) {
+	return &FailedTemplate{template, operation, message, retryCount}, nil
+}
+
+// ErrorWidget is used to create a text widget explaining why the operation
+// failed.
+//
+// Returns:
+// - Text preview widget for the failure.
+func (preview FailedTemplate) ErrorWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("error", "text")
+
+	var title string
+	switch preview.operation {
+	case "install":
+		title = "Installation failed"
+	case "remove":
+		title = "Removal failed"
+	case "refresh":
+		title = "Update failed"
+	default:
+		title = "Operation failed"
+	}
+
+	message := preview.message
+	if message == "" {
+		message = "The reason for the failure is unknown."
+	}
+
+	widget.AddAttributeValue("title", title)
+	widget.AddAttributeValue("text", message)
+
+	return widget
+}
+
+// ErrorActionsWidget is used to create an actions widget to retry the failed
+// operation or dismiss the failure.
+//
+// Returns:
+// - Action preview widget for the failure.
+func (preview FailedTemplate) ErrorActionsWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("error_actions", "actions")
+
+	previewActions := make([]interface{}, 0)
+
+	// Retrying is only possible if we know what failed, and only so many times
+	retryAction := make(map[string]interface{})
+	switch preview.operation {
+	case "install", "remove", "refresh":
+		if preview.retryCount < actions.RetryLimit {
+			retryAction["id"] = actions.ActionRetry
+		}
+	}
+
+	if _, ok := retryAction["id"]; ok {
+		retryAction["label"] = "Retry"
+		previewActions = append(previewActions, retryAction)
+	}
+
+	dismissAction := make(map[string]interface{})
+	dismissAction["id"] = actions.ActionDismiss
+	dismissAction["label"] = "Dismiss"
+	previewActions = append(previewActions, dismissAction)
+
+	widget.AddAttributeValue("actions", previewActions)
+
+	return widget
+}
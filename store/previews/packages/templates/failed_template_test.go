@@ -0,0 +1,118 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package templates
+
+import (
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"testing"
+)
+
+// Data for FailedTemplate tests
+var failedTemplateTests = []struct {
+	operation     string
+	message       string
+	expectedTitle string
+	expectedText  string
+	retryCount    int
+	expectedRetry interface{}
+}{
+	{"install", "foo", "Installation failed", "foo", 0, actions.ActionRetry},
+	{"remove", "foo", "Removal failed", "foo", 0, actions.ActionRetry},
+	{"refresh", "foo", "Update failed", "foo", 0, actions.ActionRetry},
+	{"", "", "Operation failed", "The reason for the failure is unknown.", 0, nil},
+
+	// Retry limit reached
+	{"install", "foo", "Installation failed", "foo", actions.RetryLimit, nil},
+	{"remove", "foo", "Removal failed", "foo", actions.RetryLimit, nil},
+	{"refresh", "foo", "Update failed", "foo", actions.RetryLimit, nil},
+}
+
+// Test that the error widget explains the failure.
+func TestFailedTemplate_errorWidget(t *testing.T) {
+	for i, test := range failedTemplateTests {
+		store, _ := NewStoreTemplate(client.Snap{ID: "package1"}, nil)
+		template, err := NewFailedTemplate(store, test.operation, test.message, test.retryCount)
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		widget := template.ErrorWidget()
+
+		if widget.WidgetType() != "text" {
+			t.Errorf(`Test case %d: Widget type was "%s", expected "text"`, i, widget.WidgetType())
+		}
+		if widget["title"] != test.expectedTitle {
+			t.Errorf(`Test case %d: Widget title was "%s", expected "%s"`, i, widget["title"], test.expectedTitle)
+		}
+		if widget["text"] != test.expectedText {
+			t.Errorf(`Test case %d: Widget text was "%s", expected "%s"`, i, widget["text"], test.expectedText)
+		}
+	}
+}
+
+// Test that the error actions widget offers to retry (when possible) and
+// dismiss.
+func TestFailedTemplate_errorActionsWidget(t *testing.T) {
+	for i, test := range failedTemplateTests {
+		store, _ := NewStoreTemplate(client.Snap{ID: "package1"}, nil)
+		template, err := NewFailedTemplate(store, test.operation, test.message, test.retryCount)
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		widget := template.ErrorActionsWidget()
+
+		if widget.WidgetType() != "actions" {
+			t.Errorf(`Test case %d: Widget type was "%s", expected "actions"`, i, widget.WidgetType())
+		}
+
+		actionsInterfaces := widget["actions"].([]interface{})
+
+		expectedActions := 1
+		if test.expectedRetry != nil {
+			expectedActions++
+		}
+
+		if len(actionsInterfaces) != expectedActions {
+			t.Errorf("Test case %d: Got %d actions, expected %d", i, len(actionsInterfaces), expectedActions)
+			continue
+		}
+
+		if test.expectedRetry != nil {
+			action := actionsInterfaces[0].(map[string]interface{})
+			if action["id"] != test.expectedRetry {
+				t.Errorf(`Test case %d: Retry action's ID was "%s", expected "%s"`, i, action["id"], test.expectedRetry)
+			}
+			if action["label"] != "Retry" {
+				t.Errorf(`Test case %d: Retry action's label was "%s", expected "Retry"`, i, action["label"])
+			}
+		}
+
+		action := actionsInterfaces[len(actionsInterfaces)-1].(map[string]interface{})
+		if action["id"] != actions.ActionDismiss {
+			t.Errorf(`Test case %d: Dismiss action's ID was "%s", expected "%s"`, i, action["id"], actions.ActionDismiss)
+		}
+		if action["label"] != "Dismiss" {
+			t.Errorf(`Test case %d: Dismiss action's label was "%s", expected "Dismiss"`, i, action["label"])
+		}
+	}
+}
@@ -19,11 +19,10 @@
 package templates
 
 import (
-	"fmt"
-
 	"github.com/snapcore/snapd/client"
 	"launchpad.net/go-unityscopes/v2"
 	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/packages"
 	"launchpad.net/unity-scope-snappy/store/previews/humanize"
 )
 
@@ -73,15 +72,12 @@ func (preview InstalledTemplate) ActionsWidget() scopes.PreviewWidget {
 
 	previewActions := make([]interface{}, 0)
 
-	// Only show Open if we can get the URI
-	if len(preview.snap.Apps) != 0 {
-		uri := fmt.Sprintf("appid://%s/%s/current-user-version",
-			preview.snap.Name, preview.snap.Apps[0].Name)
-
+	// Only show Open if there's an app to open. Which one is decided upon
+	// activation.
+	if len(packages.LaunchableApps(preview.snap)) != 0 {
 		openAction := make(map[string]interface{})
 		openAction["id"] = actions.ActionOpen
 		openAction["label"] = "Open"
-		openAction["uri"] = uri
 		previewActions = append(previewActions, openAction)
 	}
 
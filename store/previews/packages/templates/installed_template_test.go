@@ -213,3 +213,32 @@ func TestTestInstalledTemplate_updatesWidget(t *testing.T) {
 		}
 	}
 }
+
+// Test that the Open action is shown when the snap has an app to open.
+func TestInstalledTemplate_actionsWidget_open(t *testing.T) {
+	template, err := NewInstalledTemplate(client.Snap{
+		Name:   "package1",
+		Status: client.StatusActive,
+		Apps:   []client.AppInfo{{Name: "package1"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error creating template: %s", err)
+	}
+
+	widget := template.ActionsWidget()
+
+	actionsInterfaces := widget["actions"].([]interface{})
+	if len(actionsInterfaces) != 2 {
+		t.Fatalf("Actions widget has %d actions, expected 2", len(actionsInterfaces))
+	}
+
+	action := actionsInterfaces[0].(map[string]interface{})
+	if action["id"] != actions.ActionOpen {
+		t.Errorf(`Open action's ID was "%s", expected "%s"`, action["id"], actions.ActionOpen)
+	}
+
+	// The app to open is resolved upon activation
+	if _, ok := action["uri"]; ok {
+		t.Error("Expected open action to not have a URI")
+	}
+}
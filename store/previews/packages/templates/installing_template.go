@@ -23,13 +23,21 @@ import (
 	"github.com/godbus/dbus"
 	"github.com/snapcore/snapd/client"
 	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/previews/humanize"
 )
 
+// DownloadStatusAttribute is the preview attribute the download status widget
+// takes its text from, so it can be updated while the preview is shown.
+const DownloadStatusAttribute = "download_status"
+
 // InstallingTemplate is a preview template for a package that is currently
 // being installed. It's based upon the StoreTemplate.
 type InstallingTemplate struct {
 	*StoreTemplate
 	objectPath dbus.ObjectPath
+
+	downloadRate uint64 // Bytes per second (0 if unknown)
+	eta          int64  // Seconds remaining (-1 if unknown)
 }
 
 // NewInstallingTemplate creates a new InstallingTemplate.
@@ -47,7 +55,7 @@ func NewInstallingTemplate(snap client.Snap, result *scopes.Result, objectPath d
 		return nil, fmt.Errorf(`Invalid object path: "%s"`, objectPath)
 	}
 
-	template := &InstallingTemplate{objectPath: objectPath}
+	template := &InstallingTemplate{objectPath: objectPath, eta: -1}
 
 	var err error
 	template.StoreTemplate, err = NewStoreTemplate(snap, result)
@@ -74,3 +82,57 @@ func (preview InstallingTemplate) ActionsWidget() scopes.PreviewWidget {
 
 	return widget
 }
+
+// CancelWidget is used to create an actions widget to cancel the operation.
+//
+// Returns:
+// - Action preview widget for canceling the operation.
+func (preview InstallingTemplate) CancelWidget() scopes.PreviewWidget {
+	return cancelWidget()
+}
+
+// SetDownloadStatus records the download rate and estimated time remaining to
+// be shown under the progress bar.
+//
+// Parameters:
+// rate: Download rate in bytes per second (0 if unknown).
+// eta: Estimated time remaining in seconds (-1 if unknown).
+func (preview *InstallingTemplate) SetDownloadStatus(rate uint64, eta int64) {
+	preview.downloadRate = rate
+	preview.eta = eta
+}
+
+// DownloadStatusWidget is used to create a text widget showing the download
+// rate and estimated time remaining. Its text is updated whenever the
+// DownloadStatusAttribute is pushed.
+//
+// Returns:
+// - Text preview widget for the download status.
+func (preview InstallingTemplate) DownloadStatusWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("download_status", "text")
+
+	widget.AddAttributeValue("text", DownloadStatusText(preview.downloadRate, preview.eta))
+	widget.AddAttributeMapping("text", DownloadStatusAttribute)
+
+	return widget
+}
+
+// DownloadStatusText describes the download rate and estimated time remaining.
+//
+// Parameters:
+// rate: Download rate in bytes per second (0 if unknown).
+// eta: Estimated time remaining in seconds (-1 if unknown).
+//
+// Returns:
+// - Description of the download status, e.g. "1.5 MB/s, 2 minutes remaining".
+func DownloadStatusText(rate uint64, eta int64) string {
+	switch {
+	case rate == 0:
+		return "Time remaining unknown"
+	case eta < 0:
+		return humanize.Rate(int64(rate))
+	default:
+		return fmt.Sprintf("%s, %s remaining", humanize.Rate(int64(rate)),
+			humanize.Duration(eta))
+	}
+}
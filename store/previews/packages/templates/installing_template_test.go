@@ -21,6 +21,7 @@ package templates
 import (
 	"github.com/godbus/dbus"
 	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
 	"testing"
 )
 
@@ -93,3 +94,83 @@ func TestInstallingTemplate_actionsWidget(t *testing.T) {
 		}
 	}
 }
+
+// Test that the cancel widget conforms to the store design.
+func TestInstallingTemplate_cancelWidget(t *testing.T) {
+	for i, test := range installingTemplateTests {
+		template, err := NewInstallingTemplate(test.snap, nil, "/foo/1")
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		widget := template.CancelWidget()
+
+		if widget.WidgetType() != "actions" {
+			t.Errorf(`Test case %d: Widget type was "%s", expected "actions"`, i, widget.WidgetType())
+		}
+
+		value, ok := widget["actions"]
+		if !ok {
+			t.Errorf("Test case %d: Expected cancel widget to include actions", i)
+			continue
+		}
+
+		actionsInterfaces := value.([]interface{})
+		if len(actionsInterfaces) != 1 {
+			t.Errorf("Test case %d: Cancel widget has %d actions, expected 1", i, len(actionsInterfaces))
+			continue
+		}
+
+		action := actionsInterfaces[0].(map[string]interface{})
+		if action["id"] != actions.ActionCancelOperation {
+			t.Errorf(`Test case %d: Cancel action's ID was "%s", expected "%s"`, i, action["id"], actions.ActionCancelOperation)
+		}
+		if action["label"] != "Cancel" {
+			t.Errorf(`Test case %d: Cancel action's label was "%s", expected "Cancel"`, i, action["label"])
+		}
+	}
+}
+
+// Data for TestInstallingTemplate_downloadStatusWidget
+var downloadStatusTests = []struct {
+	rate         uint64
+	eta          int64
+	expectedText string
+}{
+	{0, -1, "Time remaining unknown"},
+	{1520435, -1, "1.5 MB/s"},
+	{1520435, 90, "1.5 MB/s, 2 minutes remaining"},
+}
+
+// Test that the download status widget shows the rate and time remaining.
+func TestInstallingTemplate_downloadStatusWidget(t *testing.T) {
+	for i, test := range downloadStatusTests {
+		template, err := NewInstallingTemplate(client.Snap{ID: "package1"}, nil, "/foo/1")
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		template.SetDownloadStatus(test.rate, test.eta)
+		widget := template.DownloadStatusWidget()
+
+		if widget.WidgetType() != "text" {
+			t.Errorf(`Test case %d: Widget type was "%s", expected "text"`, i, widget.WidgetType())
+		}
+
+		if widget["text"] != test.expectedText {
+			t.Errorf(`Test case %d: Widget text was "%s", expected "%s"`, i, widget["text"], test.expectedText)
+		}
+
+		// The text must follow the updates pushed while the preview is shown
+		components, _ := widget["components"].(map[string]interface{})
+		if components["text"] != DownloadStatusAttribute {
+			t.Errorf(`Test case %d: Widget text was mapped to "%s", expected "%s"`, i, components["text"], DownloadStatusAttribute)
+		}
+
+		if text := DownloadStatusText(test.rate, test.eta); text != test.expectedText {
+			t.Errorf(`Test case %d: Status text was "%s", expected "%s"`, i, text, test.expectedText)
+		}
+	}
+}
@@ -20,6 +20,7 @@ package templates
 
 import (
 	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/actions"
 )
 
 // Template is an interface to be implemented by structs which
@@ -37,3 +38,55 @@ type Template interface {
 	// UpdatesWidget generates a widget for the preview updates section.
 	UpdatesWidget() scopes.PreviewWidget
 }
+
+// CancelableTemplate is an interface to be implemented by templates
+// representing a package with an operation in progress that can be canceled.
+type CancelableTemplate interface {
+	Template
+
+	// CancelWidget generates a widget for canceling the operation.
+	CancelWidget() scopes.PreviewWidget
+}
+
+// DownloadStatusTemplate is an interface to be implemented by templates
+// representing a package being downloaded.
+type DownloadStatusTemplate interface {
+	Template
+
+	// SetDownloadStatus records the download rate (bytes per second) and
+	// estimated time remaining (seconds, -1 if unknown).
+	SetDownloadStatus(rate uint64, eta int64)
+
+	// DownloadStatusWidget generates a widget showing the download status.
+	DownloadStatusWidget() scopes.PreviewWidget
+}
+
+// ErrorTemplate is an interface to be implemented by templates representing a
+// package upon which an operation failed.
+type ErrorTemplate interface {
+	Template
+
+	// ErrorWidget generates a widget explaining the failure.
+	ErrorWidget() scopes.PreviewWidget
+
+	// ErrorActionsWidget generates a widget for retrying the operation or
+	// dismissing the failure.
+	ErrorActionsWidget() scopes.PreviewWidget
+}
+
+// cancelWidget is used to create an actions widget to cancel the operation in
+// progress.
+//
+// Returns:
+// - Action preview widget for canceling the operation.
+func cancelWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("cancel", "actions")
+
+	cancelAction := make(map[string]interface{})
+	cancelAction["id"] = actions.ActionCancelOperation
+	cancelAction["label"] = "Cancel"
+
+	widget.AddAttributeValue("actions", []interface{}{cancelAction})
+
+	return widget
+}
@@ -74,3 +74,11 @@ func (preview UninstallingTemplate) ActionsWidget() scopes.PreviewWidget {
 
 	return widget
 }
+
+// CancelWidget is used to create an actions widget to cancel the operation.
+//
+// Returns:
+// - Action preview widget for canceling the operation.
+func (preview UninstallingTemplate) CancelWidget() scopes.PreviewWidget {
+	return cancelWidget()
+}
@@ -21,6 +21,7 @@ package templates
 import (
 	"github.com/godbus/dbus"
 	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
 	"testing"
 )
 
@@ -93,3 +94,40 @@ func TestUninstallingTemplate_actionsWidget(t *testing.T) {
 		}
 	}
 }
+
+// Test that the cancel widget conforms to the store design.
+func TestUninstallingTemplate_cancelWidget(t *testing.T) {
+	for i, test := range uninstallingTemplateTests {
+		template, err := NewUninstallingTemplate(test.snap, "/foo/1")
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		widget := template.CancelWidget()
+
+		if widget.WidgetType() != "actions" {
+			t.Errorf(`Test case %d: Widget type was "%s", expected "actions"`, i, widget.WidgetType())
+		}
+
+		value, ok := widget["actions"]
+		if !ok {
+			t.Errorf("Test case %d: Expected cancel widget to include actions", i)
+			continue
+		}
+
+		actionsInterfaces := value.([]interface{})
+		if len(actionsInterfaces) != 1 {
+			t.Errorf("Test case %d: Cancel widget has %d actions, expected 1", i, len(actionsInterfaces))
+			continue
+		}
+
+		action := actionsInterfaces[0].(map[string]interface{})
+		if action["id"] != actions.ActionCancelOperation {
+			t.Errorf(`Test case %d: Cancel action's ID was "%s", expected "%s"`, i, action["id"], actions.ActionCancelOperation)
+		}
+		if action["label"] != "Cancel" {
+			t.Errorf(`Test case %d: Cancel action's label was "%s", expected "Cancel"`, i, action["label"])
+		}
+	}
+}
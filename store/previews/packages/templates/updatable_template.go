@@ -0,0 +1,81 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package templates
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/actions"
+)
+
+// UpdatableTemplate is a preview template for an installed package that has a
+// newer revision available in the store. It's based upon the
+// InstalledTemplate.
+type UpdatableTemplate struct {
+	*InstalledTemplate
+}
+
+// NewUpdatableTemplate creates a new UpdatableTemplate.
+//
+// Parameters:
+// snap: Snap to be represented by this template.
+//
+// Returns:
+// - Pointer to new UpdatableTemplate (nil if error)
+// - Error (nil if none)
+func NewUpdatableTemplate(snap client.Snap) (*UpdatableTemplate, error) {
+	template := new(UpdatableTemplate)
+
+	var err error
+	template.InstalledTemplate, err = NewInstalledTemplate(snap)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create installed template: %s", err)
+	}
+
+	return template, nil
+}
+
+// ActionsWidget is used to create an actions widget to update/open/uninstall
+// the snap.
+//
+// Returns:
+// - Action preview widget for the snap.
+func (preview UpdatableTemplate) ActionsWidget() scopes.PreviewWidget {
+	widget := preview.InstalledTemplate.ActionsWidget()
+
+	value, ok := widget["actions"]
+	if ok {
+		previewActions := value.([]interface{})
+		if previewActions != nil {
+			updateAction := make(map[string]interface{})
+			updateAction["id"] = actions.ActionRefresh
+			updateAction["label"] = "Update"
+
+			// Update goes first, as it's the most likely reason for visiting
+			// this preview.
+			previewActions = append([]interface{}{updateAction}, previewActions...)
+
+			widget.AddAttributeValue("actions", previewActions)
+		}
+	}
+
+	return widget
+}
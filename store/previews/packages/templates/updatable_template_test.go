@@ -0,0 +1,103 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package templates
+
+import (
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"testing"
+)
+
+// Data for UpdatableTemplate tests
+var updatableTemplateTests = []struct {
+	snap client.Snap
+}{
+	{client.Snap{ID: "package1", Status: client.StatusInstalled, Version: "0.1", InstalledSize: 123456}},
+	{client.Snap{ID: "package1", Status: client.StatusActive, Version: "0.1", InstalledSize: 123456}},
+}
+
+// Test typical NewUpdatableTemplate usage.
+func TestNewUpdatableTemplate(t *testing.T) {
+	for i, test := range updatableTemplateTests {
+		template, err := NewUpdatableTemplate(test.snap)
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		if template.snap.ID != test.snap.ID {
+			t.Errorf(`Test case %d: Template snap's ID is "%s", expected "%s"`, i, template.snap.ID, test.snap.ID)
+		}
+	}
+}
+
+// Test that the actions widget conforms to the store design.
+func TestUpdatableTemplate_actionsWidget(t *testing.T) {
+	for i, test := range updatableTemplateTests {
+		template, err := NewUpdatableTemplate(test.snap)
+		if err != nil {
+			t.Errorf("Test case %d: Unexpected error creating template: %s", i, err)
+			continue
+		}
+
+		widget := template.ActionsWidget()
+
+		value, ok := widget["actions"]
+		if !ok {
+			t.Errorf("Test case %d: Expected actions widget to include actions", i)
+			continue
+		}
+
+		actionsInterfaces := value.([]interface{})
+
+		// No apps, so no Open button
+		if len(actionsInterfaces) != 2 {
+			t.Errorf("Test case %d: Actions widget has %d actions, expected 2", i, len(actionsInterfaces))
+			continue
+		}
+
+		// Verify the update action
+		action := actionsInterfaces[0].(map[string]interface{})
+		value, ok = action["id"]
+		if !ok {
+			t.Errorf("Test case %d: Expected update action to have an id", i)
+		}
+		if value != actions.ActionRefresh {
+			t.Errorf(`Test case %d: Update action's ID was "%s", expected "%s"`, i, value, actions.ActionRefresh)
+		}
+
+		value, ok = action["label"]
+		if !ok {
+			t.Errorf("Test case %d: Expected update action to have a label", i)
+		}
+		if value != "Update" {
+			t.Errorf(`Test case %d: Update action's label was "%s", expected "Update"`, i, value)
+		}
+
+		// Verify the uninstall action
+		action = actionsInterfaces[1].(map[string]interface{})
+		value, ok = action["id"]
+		if !ok {
+			t.Errorf("Test case %d: Expected uninstall action to have an id", i)
+		}
+		if value != actions.ActionUninstall {
+			t.Errorf(`Test case %d: Uninstall action's ID was "%s", expected "%s"`, i, value, actions.ActionUninstall)
+		}
+	}
+}
@@ -31,7 +31,8 @@ import (
 // Parameters:
 // snap: Snap to be represented by the preview.
 // metadata: Metadata to be used for informing the preview creation.
-func NewPreview(snap client.Snap, result *scopes.Result, metadata *scopes.ActionMetadata) (interfaces.PreviewGenerator, error) {
+// updateAvailable: Whether the store has a newer revision of the snap.
+func NewPreview(snap client.Snap, result *scopes.Result, metadata *scopes.ActionMetadata, updateAvailable bool) (interfaces.PreviewGenerator, error) {
 	var operationMetadata operation.Metadata
 
 	// This may fail, but the zero-value of OperationMetadata is fine
@@ -43,5 +44,25 @@ func NewPreview(snap client.Snap, result *scopes.Result, metadata *scopes.Action
 		return NewConfirmUninstallPreview(snap), nil
 	}
 
-	return packages.NewPreview(snap, result, operationMetadata)
+	// If the package has several apps, the user picks the one to open
+	if operationMetadata.AppPickerRequested {
+		return NewAppPickerPreview(snap), nil
+	}
+
+	return packages.NewPreview(snap, result, operationMetadata, updateAvailable)
+}
+
+// NewUpdatesPreview is a factory for getting the preview of the installed
+// packages that have updates available.
+//
+// Parameters:
+// snaps: Installed snaps that have updates available.
+// metadata: Metadata to be used for informing the preview creation.
+func NewUpdatesPreview(snaps []client.Snap, metadata *scopes.ActionMetadata) (interfaces.PreviewGenerator, error) {
+	var operationMetadata operation.Metadata
+
+	// This may fail, but the zero-value of OperationMetadata is fine
+	metadata.ScopeData(&operationMetadata)
+
+	return NewUpdateAllPreview(snaps, operationMetadata), nil
 }
@@ -40,6 +40,9 @@ var newPreviewTests = []struct {
 
 	// Uninstallation confirmation test cases
 	{client.StatusInstalled, &operation.Metadata{UninstallRequested: true}, &ConfirmUninstallPreview{}},
+
+	// App picker test cases
+	{client.StatusActive, &operation.Metadata{AppPickerRequested: true}, &AppPickerPreview{}},
 }
 
 // Test typical NewPreview usage.
@@ -50,7 +53,7 @@ func TestNewPreview(t *testing.T) {
 
 		metadata.SetScopeData(test.scopeData)
 
-		preview, err := NewPreview(snap, nil, metadata)
+		preview, err := NewPreview(snap, nil, metadata, false)
 		if err != nil {
 			t.Errorf("Test case %d: Unexpected error: %s", i, err)
 		}
@@ -62,3 +65,23 @@ func TestNewPreview(t *testing.T) {
 		}
 	}
 }
+
+// Test typical NewUpdatesPreview usage.
+func TestNewUpdatesPreview(t *testing.T) {
+	metadata := scopes.NewActionMetadata("us", "phone")
+	metadata.SetScopeData(&operation.Metadata{RefreshRequested: true, ObjectPath: "/foo/1"})
+
+	preview, err := NewUpdatesPreview([]client.Snap{{Name: "foo"}}, metadata)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	updateAllPreview, ok := preview.(*UpdateAllPreview)
+	if !ok {
+		t.Fatalf(`Preview type was "%s", expected "*UpdateAllPreview"`, reflect.TypeOf(preview))
+	}
+
+	if !updateAllPreview.metadata.RefreshRequested {
+		t.Error("Expected preview metadata to indicate that a refresh was requested")
+	}
+}
@@ -0,0 +1,123 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package previews
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/previews/interfaces"
+)
+
+// UpdateAllPreview is a PreviewGenerator listing the installed packages that
+// have updates available, and allowing the user to update them all at once.
+type UpdateAllPreview struct {
+	snaps    []client.Snap
+	metadata operation.Metadata
+}
+
+// NewUpdateAllPreview creates a new UpdateAllPreview.
+//
+// Parameters:
+// snaps: Installed packages that have updates available.
+// metadata: Metadata of the refresh operation (if any).
+func NewUpdateAllPreview(snaps []client.Snap, metadata operation.Metadata) *UpdateAllPreview {
+	return &UpdateAllPreview{snaps: snaps, metadata: metadata}
+}
+
+// Generate pushes the preview widgets onto a WidgetReceiver.
+//
+// Parameters:
+// receiver: Implementation of the WidgetReceiver interface.
+//
+// Returns:
+// - Error (nil if none)
+func (preview UpdateAllPreview) Generate(receiver interfaces.WidgetReceiver) error {
+	receiver.PushWidgets(preview.textWidget())
+
+	if preview.metadata.RefreshRequested && preview.metadata.ObjectPath.IsValid() {
+		receiver.PushWidgets(preview.progressWidget())
+	} else if len(preview.snaps) > 0 {
+		receiver.PushWidgets(preview.actionsWidget())
+	}
+
+	return nil
+}
+
+// textWidget is used to create a text widget listing the packages to update.
+//
+// Returns:
+// - Text preview widget for the packages.
+func (preview UpdateAllPreview) textWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("updates", "text")
+
+	widget.AddAttributeValue("title", "Updates available")
+
+	if len(preview.snaps) == 0 {
+		widget.AddAttributeValue("text", "All snaps are up to date.")
+		return widget
+	}
+
+	names := make([]string, 0, len(preview.snaps))
+	for _, snap := range preview.snaps {
+		names = append(names, snap.Name)
+	}
+
+	widget.AddAttributeValue("text", fmt.Sprintf("Updates are available for: %s",
+		strings.Join(names, ", ")))
+
+	return widget
+}
+
+// actionsWidget is used to create an action widget to update all the packages.
+//
+// Returns:
+// - Action preview widget for the packages.
+func (preview UpdateAllPreview) actionsWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("actions", "actions")
+
+	updateAllAction := make(map[string]interface{})
+	updateAllAction["id"] = actions.ActionRefreshAll
+	updateAllAction["label"] = "Update all"
+
+	widget.AddAttributeValue("actions", []interface{}{updateAllAction})
+
+	return widget
+}
+
+// progressWidget is used to create a progress widget for the refresh
+// operation.
+//
+// Returns:
+// - Progress preview widget for the refresh.
+func (preview UpdateAllPreview) progressWidget() scopes.PreviewWidget {
+	widget := scopes.NewPreviewWidget("refresh_all", "progress")
+
+	source := make(map[string]interface{})
+	source["dbus-name"] = "com.canonical.applications.WebdmPackageManager"
+	source["dbus-object"] = preview.metadata.ObjectPath
+
+	widget.AddAttributeValue("source", source)
+
+	return widget
+}
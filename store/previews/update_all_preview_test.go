@@ -0,0 +1,141 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package previews
+
+import (
+	"github.com/godbus/dbus"
+	"github.com/snapcore/snapd/client"
+	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/operation"
+	"launchpad.net/unity-scope-snappy/store/previews/fakes"
+	"testing"
+)
+
+// Test typical NewUpdateAllPreview usage.
+func TestNewUpdateAllPreview(t *testing.T) {
+	snaps := []client.Snap{{Name: "package1"}}
+
+	preview := NewUpdateAllPreview(snaps, operation.Metadata{})
+	if preview == nil {
+		t.Fatal("Preview was unexpectedly nil")
+	}
+
+	if len(preview.snaps) != 1 {
+		t.Errorf("Preview had %d snaps, expected 1", len(preview.snaps))
+	}
+}
+
+// Test typical Generate usage, and verify that it conforms to store design.
+func TestUpdateAllPreview_generate(t *testing.T) {
+	snaps := []client.Snap{{Name: "package1"}, {Name: "package2"}}
+	preview := NewUpdateAllPreview(snaps, operation.Metadata{})
+
+	receiver := new(fakes.FakeWidgetReceiver)
+
+	err := preview.Generate(receiver)
+	if err != nil {
+		t.Errorf("Unexpected error while generating preview: %s", err)
+	}
+
+	if len(receiver.Widgets) != 2 {
+		// Exit here so we don't index out of bounds later
+		t.Fatalf("Got %d widgets, expected 2", len(receiver.Widgets))
+	}
+
+	// Verify text
+	widget := receiver.Widgets[0]
+	if widget.WidgetType() != "text" {
+		t.Error("Expected text to be first widget")
+	}
+
+	value := widget["text"]
+	expectedText := "Updates are available for: package1, package2"
+	if value != expectedText {
+		t.Errorf(`Text was "%s", expected "%s"`, value, expectedText)
+	}
+
+	// Verify actions
+	widget = receiver.Widgets[1]
+	if widget.WidgetType() != "actions" {
+		t.Fatal("Expected actions to be second widget")
+	}
+
+	actionsInterfaces := widget["actions"].([]interface{})
+	if len(actionsInterfaces) != 1 {
+		t.Fatalf("Actions widget had %d actions, expected 1", len(actionsInterfaces))
+	}
+
+	action := actionsInterfaces[0].(map[string]interface{})
+	if action["id"] != actions.ActionRefreshAll {
+		t.Errorf(`Update all action's ID was "%s", expected "%s"`, action["id"], actions.ActionRefreshAll)
+	}
+	if action["label"] != "Update all" {
+		t.Errorf(`Update all action's label was "%s", expected "Update all"`, action["label"])
+	}
+}
+
+// Test that there are no actions when there is nothing to update.
+func TestUpdateAllPreview_generate_noUpdates(t *testing.T) {
+	preview := NewUpdateAllPreview(nil, operation.Metadata{})
+
+	receiver := new(fakes.FakeWidgetReceiver)
+
+	err := preview.Generate(receiver)
+	if err != nil {
+		t.Errorf("Unexpected error while generating preview: %s", err)
+	}
+
+	if len(receiver.Widgets) != 1 {
+		t.Fatalf("Got %d widgets, expected 1", len(receiver.Widgets))
+	}
+
+	if receiver.Widgets[0].WidgetType() != "text" {
+		t.Error("Expected text to be the only widget")
+	}
+}
+
+// Test that a progress widget is shown while the refresh is running.
+func TestUpdateAllPreview_generate_refreshing(t *testing.T) {
+	snaps := []client.Snap{{Name: "package1"}}
+	preview := NewUpdateAllPreview(snaps, operation.Metadata{
+		RefreshRequested: true,
+		ObjectPath:       "/foo/1",
+	})
+
+	receiver := new(fakes.FakeWidgetReceiver)
+
+	err := preview.Generate(receiver)
+	if err != nil {
+		t.Errorf("Unexpected error while generating preview: %s", err)
+	}
+
+	if len(receiver.Widgets) != 2 {
+		t.Fatalf("Got %d widgets, expected 2", len(receiver.Widgets))
+	}
+
+	widget := receiver.Widgets[1]
+	if widget.WidgetType() != "progress" {
+		t.Fatal("Expected progress to be second widget")
+	}
+
+	source := widget["source"].(map[string]interface{})
+	if source["dbus-object"] != dbus.ObjectPath("/foo/1") {
+		t.Errorf(`Progress widget's dbus-object was "%s", expected "/foo/1"`, source["dbus-object"])
+	}
+}
@@ -0,0 +1,51 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scope
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+)
+
+// FakeSearchReceiver is a fake implementation of the SearchReceiver interface,
+// for use within tests.
+type FakeSearchReceiver struct {
+	// IDs of the registered categories, in order
+	Categories []string
+
+	// Root of the registered departments
+	Department *scopes.Department
+
+	Results []*scopes.CategorisedResult
+}
+
+func (receiver *FakeSearchReceiver) RegisterCategory(id, title, icon, template string) *scopes.Category {
+	receiver.Categories = append(receiver.Categories, id)
+
+	return new(scopes.Category)
+}
+
+func (receiver *FakeSearchReceiver) RegisterDepartments(parent *scopes.Department) {
+	receiver.Department = parent
+}
+
+func (receiver *FakeSearchReceiver) Push(result *scopes.CategorisedResult) error {
+	receiver.Results = append(receiver.Results, result)
+
+	return nil
+}
@@ -21,12 +21,18 @@ package scope
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/snapcore/snapd/client"
 	"launchpad.net/go-unityscopes/v2"
 	"launchpad.net/unity-scope-snappy/store/actions"
+	"launchpad.net/unity-scope-snappy/store/operation"
 	"launchpad.net/unity-scope-snappy/store/packages"
 	"launchpad.net/unity-scope-snappy/store/previews"
+	"launchpad.net/unity-scope-snappy/store/previews/interfaces"
+	"launchpad.net/unity-scope-snappy/store/previews/packages/templates"
 )
 
 // template for the grid layout of the search results.
@@ -48,10 +54,38 @@ const layout = `{
     }
 }`
 
+const (
+	// rootDepartmentId is the ID of the department listing store packages.
+	rootDepartmentId = ""
+
+	// installedDepartmentId is the ID of the department listing installed
+	// packages.
+	installedDepartmentId = "installed"
+
+	// recentDepartmentId is the ID of the department listing the latest
+	// operations.
+	recentDepartmentId = "recent"
+)
+
+// packageQueryPrefix prefixes the queries showing a single package rather than
+// searching for it, e.g. "pkgname:foo" as linked to by the daemon's
+// notifications.
+const packageQueryPrefix = "pkgname:"
+
+// recentActivityLimit is the number of operations listed in the recent activity
+// department.
+const recentActivityLimit = 50
+
+// downloadStatusTimeout is how long the preview of an install in progress keeps
+// its download status up to date, as it holds on to the preview request
+// meanwhile.
+const downloadStatusTimeout = time.Minute
+
 // Scope is the struct representing the scope itself.
 type Scope struct {
-	webdmClient packages.WebdmManager
-	dbusClient  *packages.DbusManagerClient
+	webdmClient   packages.WebdmManager
+	dbusClient    *packages.DbusManagerClient
+	urlDispatcher *packages.DbusUrlDispatcher
 }
 
 // New creates a new Scope using a specific WebDM API URL.
@@ -75,6 +109,12 @@ func New() (*Scope, error) {
 		return nil, fmt.Errorf("Unable to connect to dbus session bus: %s", err)
 	}
 
+	scope.urlDispatcher = packages.NewDbusUrlDispatcher()
+	err = scope.urlDispatcher.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to dbus session bus: %s", err)
+	}
+
 	return scope, nil
 }
 
@@ -83,12 +123,171 @@ func (scope Scope) SetScopeBase(base *scopes.ScopeBase) {
 }
 
 func (scope Scope) Search(query *scopes.CannedQuery, metadata *scopes.SearchMetadata, reply *scopes.SearchReply, cancelled <-chan bool) error {
-	installedApps := scope.webdmClient.GetInstalledPackages()
-	available, err := scope.webdmClient.GetStorePackages(query.QueryString())
+	return scope.search(query, reply)
+}
+
+// search registers the departments and pushes the results of the department
+// targeted by the query.
+//
+// Parameters:
+// query: Query being run.
+// reply: Reply onto which the departments and results will be pushed.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) search(query *scopes.CannedQuery, reply SearchReceiver) error {
+	// Failing to get the installed packages just means none are shown as such.
+	installed, err := scope.webdmClient.GetInstalledPackages()
+	if err != nil {
+		log.Printf("unity-scope-snappy: Unable to get installed package list: %s", err)
+	}
+
+	// Failing to get the sections just means they can't be browsed.
+	sections, err := scope.webdmClient.GetStoreSections()
+	if err != nil {
+		log.Printf("unity-scope-snappy: Unable to get store sections: %s", err)
+	}
+
+	err = registerDepartments(query, reply, sections)
+	if err != nil {
+		return scopeError("unity-scope-snappy: Unable to register departments: %s", err)
+	}
+
+	if strings.HasPrefix(query.QueryString(), packageQueryPrefix) {
+		snapName := strings.TrimPrefix(query.QueryString(), packageQueryPrefix)
+		return scope.searchPackage(snapName, reply, installed)
+	}
+
+	if query.DepartmentID() == installedDepartmentId {
+		return scope.searchInstalled(query, reply, installed)
+	}
+
+	if query.DepartmentID() == recentDepartmentId {
+		return scope.searchRecent(query, reply, installed)
+	}
+
+	return scope.searchStore(query, reply, installed)
+}
+
+// searchInstalled pushes the installed packages matching the query.
+//
+// Parameters:
+// query: Query to be matched.
+// reply: Reply onto which the results will be pushed.
+// installed: Installed packages.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) searchInstalled(query *scopes.CannedQuery, reply SearchReceiver, installed []client.Snap) error {
+	category := reply.RegisterCategory("installed_packages", "Installed Packages", "", layout)
+
+	queryString := strings.ToLower(query.QueryString())
+
+	for _, thisPackage := range installed {
+		if !strings.Contains(strings.ToLower(thisPackage.Name), queryString) {
+			continue
+		}
+
+		if reply.Push(packageResult(category, thisPackage, true)) != nil {
+			// If the push fails, the query was cancelled. No need to continue.
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// searchRecent pushes the latest operations on packages matching the query,
+// along with their outcome.
+//
+// Parameters:
+// query: Query to be matched.
+// reply: Reply onto which the results will be pushed.
+// installed: Installed packages.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) searchRecent(query *scopes.CannedQuery, reply SearchReceiver, installed []client.Snap) error {
+	// Without the daemon there's simply no activity to show.
+	history, err := scope.dbusClient.GetHistory(recentActivityLimit)
+	if err != nil {
+		log.Printf("unity-scope-snappy: Unable to get operation history: %s", err)
+		return nil
+	}
+
+	category := reply.RegisterCategory("recent_activity", "Recent activity", "", layout)
+
+	// The history doesn't know about icons, but installed packages do.
+	icons := make(map[string]string)
+	for _, thisPackage := range installed {
+		icons[thisPackage.Name] = thisPackage.Icon
+	}
+
+	queryString := strings.ToLower(query.QueryString())
+
+	for _, entry := range history {
+		// Refreshing all packages has no single package to preview.
+		for _, snapName := range entry.PackageIds {
+			if !strings.Contains(strings.ToLower(snapName), queryString) {
+				continue
+			}
+
+			if reply.Push(activityResult(category, entry, snapName, icons[snapName])) != nil {
+				// If the push fails, the query was cancelled. No need to continue.
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// searchStore pushes the store packages matching the query within the store
+// section selected by the department, along with the available updates when on
+// the landing page.
+//
+// Parameters:
+// query: Query to be matched.
+// reply: Reply onto which the results will be pushed.
+// installed: Installed packages.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) searchStore(query *scopes.CannedQuery, reply SearchReceiver, installed []client.Snap) error {
+	// Any department other than the root and installed ones is a store section.
+	section := query.DepartmentID()
+
+	available, err := scope.webdmClient.GetStorePackages(query.QueryString(), section)
 	if err != nil {
 		return scopeError("unity-scope-snappy: Unable to get package list: %s", err)
 	}
 
+	// Updates are only shown on the landing page, not in search results.
+	if query.QueryString() == "" && section == rootDepartmentId {
+		updates, err := scope.webdmClient.GetUpdatablePackages()
+		if err != nil {
+			log.Printf("unity-scope-snappy: Unable to get package updates: %s", err)
+		} else if len(updates) > 0 {
+			// Registered first so it shows up at the top.
+			updatesCategory := reply.RegisterCategory("updates", "Updates available", "", layout)
+
+			if reply.Push(updateAllResult(updatesCategory, len(updates))) != nil {
+				return nil
+			}
+
+			for _, thisPackage := range updates {
+				if reply.Push(updateResult(updatesCategory, thisPackage)) != nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	installedApps := make(map[string]struct{})
+	for _, thisPackage := range installed {
+		installedApps[thisPackage.Name] = struct{}{}
+	}
+
 	var category *scopes.Category
 	category = reply.RegisterCategory("store_packages", "Store Packages", "", layout)
 
@@ -105,7 +304,42 @@ func (scope Scope) Search(query *scopes.CannedQuery, metadata *scopes.SearchMeta
 	return nil
 }
 
+// searchPackage pushes a single package, so its preview is one tap away.
+//
+// Parameters:
+// snapName: Name of the package.
+// reply: Reply onto which the result will be pushed.
+// installed: Installed packages.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) searchPackage(snapName string, reply SearchReceiver, installed []client.Snap) error {
+	snap, err := scope.webdmClient.Query(snapName)
+	if err != nil {
+		return scopeError(`unity-scope-snappy: Unable to query API for package "%s": %s`, snapName, err)
+	}
+
+	isInstalled := false
+	for _, thisPackage := range installed {
+		if thisPackage.Name == snap.Name {
+			isInstalled = true
+		}
+	}
+
+	category := reply.RegisterCategory("store_packages", "Store Packages", "", layout)
+
+	// If the push fails, the query was cancelled. Nothing else to push anyway.
+	reply.Push(packageResult(category, *snap, isInstalled))
+
+	return nil
+}
+
 func (scope Scope) Preview(result *scopes.Result, metadata *scopes.ActionMetadata, reply *scopes.PreviewReply, cancelled <-chan bool) error {
+	var updateAll bool
+	if result.Get("update_all", &updateAll) == nil && updateAll {
+		return scope.updatesPreview(metadata, reply)
+	}
+
 	var snapName string
 	err := result.Get("name", &snapName)
 	if err != nil {
@@ -121,16 +355,177 @@ func (scope Scope) Preview(result *scopes.Result, metadata *scopes.ActionMetadat
 		return scopeError(`unity-scope-snappy: Unable to query API for package "%s": %s`, result.Title(), err)
 	}
 
-	preview, err := previews.NewPreview(*snap, result, metadata)
+	// Only installed snaps can be updated. Failing to reach the store just
+	// means the update button won't be shown.
+	updateAvailable := false
+	if snap.Status == client.StatusInstalled || snap.Status == client.StatusActive {
+		updateAvailable, err = scope.webdmClient.UpdateAvailable(*snap)
+		if err != nil {
+			log.Printf(`unity-scope-snappy: Unable to check for updates to package "%s": %s`, result.Title(), err)
+		}
+	}
+
+	scope.followOperation(snapName, metadata)
+
+	preview, err := previews.NewPreview(*snap, result, metadata, updateAvailable)
 	if err != nil {
 		return scopeError(`unity-scope-snappy: Unable to create preview for package "%s": %s`, result.Title(), err)
 	}
 
+	scope.showDownloadStatus(preview, metadata)
+
 	err = preview.Generate(reply)
 	if err != nil {
 		return scopeError(`unity-scope-snappy: Unable to generate preview for package "%s": %s`, result.Title(), err)
 	}
 
+	scope.followDownloadStatus(preview, metadata, reply, cancelled)
+
+	return nil
+}
+
+// followOperation asks the daemon whether an operation is in progress for the
+// given package when the metadata doesn't already refer to one, e.g. when the
+// preview was opened from a fresh search result. If so, the metadata is updated
+// so that the preview shows the operation's progress.
+//
+// Parameters:
+// snapName: Name of the package being previewed.
+// metadata: Metadata to be used for informing the preview creation.
+func (scope Scope) followOperation(snapName string, metadata *scopes.ActionMetadata) {
+	var operationMetadata operation.Metadata
+
+	// This may fail, but the zero-value of OperationMetadata is fine
+	metadata.ScopeData(&operationMetadata)
+
+	if operationMetadata != (operation.Metadata{}) {
+		return
+	}
+
+	objectPath, kind, err := scope.dbusClient.GetOperationForPackage(snapName)
+	if err != nil {
+		log.Printf(`unity-scope-snappy: Unable to get operation for package "%s": %s`, snapName, err)
+		return
+	}
+
+	switch kind {
+	case "install":
+		operationMetadata.InstallRequested = true
+	case "remove":
+		operationMetadata.UninstallConfirmed = true
+	case "refresh":
+		operationMetadata.RefreshRequested = true
+	default:
+		// No operation in progress (or one we don't know how to display)
+		return
+	}
+
+	operationMetadata.ObjectPath = objectPath
+	metadata.SetScopeData(operationMetadata)
+}
+
+// showDownloadStatus asks the daemon for the download rate and estimated time
+// remaining of the install in progress, if any, so the preview can show them.
+//
+// Parameters:
+// preview: Preview to be informed of the download status.
+// metadata: Metadata to be used for informing the preview creation.
+func (scope Scope) showDownloadStatus(preview interfaces.PreviewGenerator, metadata *scopes.ActionMetadata) {
+	receiver, ok := preview.(interfaces.DownloadStatusReceiver)
+	if !ok {
+		return
+	}
+
+	var operationMetadata operation.Metadata
+
+	// This may fail, but the zero-value of OperationMetadata is fine
+	metadata.ScopeData(&operationMetadata)
+
+	if !operationMetadata.InstallRequested || operationMetadata.ObjectPath == "" {
+		return
+	}
+
+	rate, eta, err := scope.dbusClient.GetDownloadStatus(operationMetadata.ObjectPath)
+	if err != nil {
+		log.Printf(`unity-scope-snappy: Unable to get download status of "%s": %s`, operationMetadata.ObjectPath, err)
+		return
+	}
+
+	receiver.SetDownloadStatus(rate, eta)
+}
+
+// followDownloadStatus keeps the download status shown by the preview up to
+// date while the install is in progress, pushing it each time it changes. This
+// returns once the install is over, the preview is closed, or the download
+// status timeout expires.
+//
+// Parameters:
+// preview: Preview that was generated.
+// metadata: Metadata used for informing the preview creation.
+// reply: Reply the preview was generated into.
+// cancelled: Channel notified when the preview is closed.
+func (scope Scope) followDownloadStatus(preview interfaces.PreviewGenerator, metadata *scopes.ActionMetadata, reply *scopes.PreviewReply, cancelled <-chan bool) {
+	if _, ok := preview.(interfaces.DownloadStatusReceiver); !ok {
+		return
+	}
+
+	var operationMetadata operation.Metadata
+
+	// This may fail, but the zero-value of OperationMetadata is fine
+	metadata.ScopeData(&operationMetadata)
+
+	if !operationMetadata.InstallRequested || operationMetadata.ObjectPath == "" {
+		return
+	}
+
+	stop := make(chan bool)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-cancelled:
+		case <-time.After(downloadStatusTimeout):
+		case <-done:
+			return
+		}
+
+		close(stop)
+	}()
+
+	err := scope.dbusClient.FollowDownloadStatus(operationMetadata.ObjectPath, stop,
+		func(rate uint64, eta int64) {
+			reply.PushAttr(templates.DownloadStatusAttribute, templates.DownloadStatusText(rate, eta))
+		})
+	if err != nil {
+		log.Printf(`unity-scope-snappy: Unable to follow download status of "%s": %s`, operationMetadata.ObjectPath, err)
+	}
+}
+
+// updatesPreview generates the preview for the "Update all" result.
+//
+// Parameters:
+// metadata: Metadata to be used for informing the preview creation.
+// reply: Reply onto which the preview widgets will be pushed.
+//
+// Returns:
+// - Error (nil if none)
+func (scope Scope) updatesPreview(metadata *scopes.ActionMetadata, reply *scopes.PreviewReply) error {
+	updates, err := scope.webdmClient.GetUpdatablePackages()
+	if err != nil {
+		return scopeError("unity-scope-snappy: Unable to get package updates: %s", err)
+	}
+
+	preview, err := previews.NewUpdatesPreview(updates, metadata)
+	if err != nil {
+		return scopeError("unity-scope-snappy: Unable to create updates preview: %s", err)
+	}
+
+	err = preview.Generate(reply)
+	if err != nil {
+		return scopeError("unity-scope-snappy: Unable to generate updates preview: %s", err)
+	}
+
 	return nil
 }
 
@@ -148,6 +543,20 @@ func (scope *Scope) PerformAction(result *scopes.Result, metadata *scopes.Action
 		return nil, scopeError(`unity-scope-snappy: Unable to handle action "%s": %s`, actionId, err)
 	}
 
+	// Some actions depend upon the state of the preview they came from
+	if metadataRunner, ok := actionRunner.(actions.MetadataRunner); ok {
+		var operationMetadata operation.Metadata
+
+		// This may fail, but the zero-value of OperationMetadata is fine
+		metadata.ScopeData(&operationMetadata)
+
+		metadataRunner.SetMetadata(operationMetadata)
+	}
+
+	if launcherRunner, ok := actionRunner.(actions.LauncherRunner); ok {
+		launcherRunner.SetLauncher(scope.webdmClient, scope.urlDispatcher)
+	}
+
 	response, err := actionRunner.Run(scope.dbusClient, snapId)
 	if err != nil {
 		err = scopeError(`unity-scope-snappy: Error handling action "%s": %s`, actionId, err)
@@ -156,6 +565,74 @@ func (scope *Scope) PerformAction(result *scopes.Result, metadata *scopes.Action
 	return response, err
 }
 
+// registerDepartments registers the store department and its child departments
+// for each store section, for installed packages and for recent activity.
+//
+// Parameters:
+// query: Query being run.
+// reply: Reply onto which the departments will be registered.
+// sections: Names of the store sections.
+//
+// Returns:
+// - Error (nil if none)
+func registerDepartments(query *scopes.CannedQuery, reply SearchReceiver, sections []string) error {
+	rootDepartment, err := scopes.NewDepartment(rootDepartmentId, query, "All Categories")
+	if err != nil {
+		return fmt.Errorf("Unable to create store department: %s", err)
+	}
+
+	// Our own departments come first, however many sections the store has.
+	installedDepartment, err := scopes.NewDepartment(installedDepartmentId, query, "My Snaps")
+	if err != nil {
+		return fmt.Errorf("Unable to create installed department: %s", err)
+	}
+
+	rootDepartment.AddSubdepartment(installedDepartment)
+
+	recentDepartment, err := scopes.NewDepartment(recentDepartmentId, query, "Recent activity")
+	if err != nil {
+		return fmt.Errorf("Unable to create recent activity department: %s", err)
+	}
+
+	rootDepartment.AddSubdepartment(recentDepartment)
+
+	for _, section := range sections {
+		// Don't let a section hide one of our own departments.
+		if section == rootDepartmentId || section == installedDepartmentId ||
+			section == recentDepartmentId {
+			continue
+		}
+
+		sectionDepartment, err := scopes.NewDepartment(section, query, sectionLabel(section))
+		if err != nil {
+			return fmt.Errorf(`Unable to create department for section "%s": %s`, section, err)
+		}
+
+		rootDepartment.AddSubdepartment(sectionDepartment)
+	}
+
+	reply.RegisterDepartments(rootDepartment)
+
+	return nil
+}
+
+// sectionLabel is used to create a human-readable department label from a store
+// section name (e.g. "art-and-design" becomes "Art and design").
+//
+// Parameters:
+// section: Name of the store section.
+//
+// Returns:
+// - Department label
+func sectionLabel(section string) string {
+	label := strings.Replace(section, "-", " ", -1)
+	if label == "" {
+		return label
+	}
+
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
 // packageResult is used to create a scopes.CategorisedResult from a
 // client.Snap.
 //
@@ -183,18 +660,132 @@ func packageResult(category *scopes.Category, snap client.Snap, installed bool)
 		price = "FREE"
 	}
 	result.Set("price_area", price)
+	result.Set("attributes", cardAttributes(price))
+	return result
+}
+
+// updateResult is used to create a scopes.CategorisedResult from an installed
+// client.Snap that has an update available.
+//
+// Parameters:
+// category: Category in which the result will be created.
+// snap: client.Snap representing the installed snap.
+//
+// Returns:
+// - Pointer to scopes.CategorisedResult
+func updateResult(category *scopes.Category, snap client.Snap) *scopes.CategorisedResult {
+	result := packageResult(category, snap, true)
+
+	status := "UPDATE AVAILABLE"
+	result.Set("price_area", status)
+	result.Set("attributes", cardAttributes(status))
+	return result
+}
+
+// updateAllResult is used to create the result allowing all updatable snaps to
+// be updated at once.
+//
+// Parameters:
+// category: Category in which the result will be created.
+// count: Number of snaps that have updates available.
+//
+// Returns:
+// - Pointer to scopes.CategorisedResult
+func updateAllResult(category *scopes.Category, count int) *scopes.CategorisedResult {
+	result := scopes.NewCategorisedResult(category)
+
+	result.SetTitle("Update all")
+	result.SetURI("snappy:update-all")
+	result.Set("subtitle", fmt.Sprintf("%d updates available", count))
+	result.Set("name", "")
+	result.Set("update_all", true)
+	result.Set("attributes", cardAttributes(""))
+	return result
+}
+
+// activityResult is used to create a scopes.CategorisedResult from a finished
+// operation on a package. It leads to the package's usual preview.
+//
+// Parameters:
+// category: Category in which the result will be created.
+// entry: History entry of the operation.
+// snapName: Name of the package operated upon.
+// icon: Icon of the package (empty if unknown).
+//
+// Returns:
+// - Pointer to scopes.CategorisedResult
+func activityResult(category *scopes.Category, entry operation.HistoryEntry, snapName string, icon string) *scopes.CategorisedResult {
+	result := scopes.NewCategorisedResult(category)
+
+	result.SetTitle(snapName)
+	result.SetArt(icon)
+	result.SetURI("snappy:" + snapName)
+	result.Set("name", snapName)
+
+	if entry.Error != "" {
+		result.Set("subtitle", entry.Error)
+	} else if revision, ok := entry.RevisionsAfter[snapName]; ok {
+		result.Set("subtitle", "Revision "+revision)
+	}
+
+	outcome := activityOutcome(entry)
+	result.Set("price_area", outcome)
+	result.Set("attributes", cardAttributes(outcome,
+		humanize.Time(time.Unix(entry.EndTime, 0))))
+	return result
+}
+
+// activityOutcome is used to describe how an operation went (e.g. "INSTALLED"
+// or "UPDATE FAILED").
+//
+// Parameters:
+// entry: History entry of the operation.
+//
+// Returns:
+// - Outcome of the operation
+func activityOutcome(entry operation.HistoryEntry) string {
+	var done, failed string
+	switch entry.Kind {
+	case "install":
+		done, failed = "INSTALLED", "INSTALL FAILED"
+	case "remove":
+		done, failed = "REMOVED", "REMOVAL FAILED"
+	case "refresh":
+		done, failed = "UPDATED", "UPDATE FAILED"
+	default:
+		done, failed = "DONE", "FAILED"
+	}
+
+	switch entry.Status {
+	case "done":
+		return done
+	case "canceled":
+		return "CANCELED"
+	default:
+		return failed
+	}
+}
+
+// cardAttributes is used to create the attributes shown on a result card, the
+// first of which hold the given values.
+//
+// Parameters:
+// values...: Values of the first attributes.
+//
+// Returns:
+// - Slice of attributes
+func cardAttributes(values ...string) []map[string]string {
 	// This is a bit of a mess at the moment, need a better way to do this
 	attributes := make([]map[string]string, 0)
-	emptyValue := make(map[string]string, 0)
-	emptyValue["value"] = ""
-	priceValue := make(map[string]string, 0)
-	priceValue["value"] = price
-	attributes = append(attributes, priceValue)
-	attributes = append(attributes, emptyValue)
-	attributes = append(attributes, emptyValue)
-	attributes = append(attributes, emptyValue)
-	result.Set("attributes", attributes)
-	return result
+	for i := 0; i < 4; i++ {
+		value := make(map[string]string, 0)
+		value["value"] = ""
+		if i < len(values) {
+			value["value"] = values[i]
+		}
+		attributes = append(attributes, value)
+	}
+	return attributes
 }
 
 // scopeError prints an error to stderr as well as returning an actual error.
@@ -0,0 +1,175 @@
+/* Copyright (C) 2016 Canonical Ltd.
+ *
+ * This file is part of unity-scope-snappy.
+ *
+ * unity-scope-snappy is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU General Public License as published by the Free
+ * Software Foundation, either version 3 of the License, or (at your option) any
+ * later version.
+ *
+ * unity-scope-snappy is distributed in the hope that it will be useful, but
+ * WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * unity-scope-snappy. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scope
+
+import (
+	"launchpad.net/go-unityscopes/v2"
+	"launchpad.net/unity-scope-snappy/store/packages/fakes"
+	"reflect"
+	"testing"
+)
+
+// departmentIds is used to list the IDs of the child departments of the given
+// department.
+func departmentIds(department *scopes.Department) []string {
+	ids := make([]string, 0)
+	for _, child := range department.Subdepartments() {
+		ids = append(ids, child.Id())
+	}
+
+	return ids
+}
+
+// Test that the landing page lists the store's default packages and updates,
+// under departments for our own lists and each store section.
+func TestScopeSearch_landingPage(t *testing.T) {
+	webdmClient := &fakes.FakeWebdmManager{Sections: []string{"games", "installed"}}
+	scope := Scope{webdmClient: webdmClient}
+	receiver := new(FakeSearchReceiver)
+
+	err := scope.search(scopes.NewCannedQuery("snappy-store", "", ""), receiver)
+	if err != nil {
+		t.Fatalf("Unexpected error while searching: %s", err)
+	}
+
+	if receiver.Department == nil {
+		t.Fatal("Expected departments to be registered")
+	}
+
+	if receiver.Department.Id() != rootDepartmentId {
+		t.Errorf(`Root department was "%s", expected "%s"`, receiver.Department.Id(), rootDepartmentId)
+	}
+
+	// The "installed" section must not hide our own department
+	expectedIds := []string{installedDepartmentId, recentDepartmentId, "games"}
+	if ids := departmentIds(receiver.Department); !reflect.DeepEqual(ids, expectedIds) {
+		t.Errorf("Departments were %v, expected %v", ids, expectedIds)
+	}
+
+	if !webdmClient.GetStorePackagesCalled {
+		t.Error("Expected store packages to be listed")
+	}
+
+	if webdmClient.StoreSection != "" {
+		t.Errorf(`Store section was "%s", expected none`, webdmClient.StoreSection)
+	}
+
+	if !webdmClient.GetUpdatablePackagesCalled {
+		t.Error("Expected updates to be listed on the landing page")
+	}
+
+	if !reflect.DeepEqual(receiver.Categories, []string{"store_packages"}) {
+		t.Errorf(`Categories were %v, expected [store_packages]`, receiver.Categories)
+	}
+}
+
+// Test that the installed department only lists installed packages.
+func TestScopeSearch_installedDepartment(t *testing.T) {
+	webdmClient := &fakes.FakeWebdmManager{Sections: []string{"games"}}
+	scope := Scope{webdmClient: webdmClient}
+	receiver := new(FakeSearchReceiver)
+
+	err := scope.search(scopes.NewCannedQuery("snappy-store", installedDepartmentId, ""), receiver)
+	if err != nil {
+		t.Fatalf("Unexpected error while searching: %s", err)
+	}
+
+	if receiver.Department == nil {
+		t.Error("Expected departments to be registered")
+	}
+
+	if webdmClient.GetStorePackagesCalled {
+		t.Error("Expected store packages not to be listed")
+	}
+
+	if !reflect.DeepEqual(receiver.Categories, []string{"installed_packages"}) {
+		t.Errorf(`Categories were %v, expected [installed_packages]`, receiver.Categories)
+	}
+}
+
+// Test that a store section department lists the packages of that section,
+// without updates.
+func TestScopeSearch_storeSection(t *testing.T) {
+	webdmClient := &fakes.FakeWebdmManager{Sections: []string{"games"}}
+	scope := Scope{webdmClient: webdmClient}
+	receiver := new(FakeSearchReceiver)
+
+	err := scope.search(scopes.NewCannedQuery("snappy-store", "games", ""), receiver)
+	if err != nil {
+		t.Fatalf("Unexpected error while searching: %s", err)
+	}
+
+	if receiver.Department == nil {
+		t.Error("Expected departments to be registered")
+	}
+
+	if webdmClient.StoreSection != "games" {
+		t.Errorf(`Store section was "%s", expected "games"`, webdmClient.StoreSection)
+	}
+
+	if webdmClient.GetUpdatablePackagesCalled {
+		t.Error("Expected updates not to be listed outside the landing page")
+	}
+
+	if !reflect.DeepEqual(receiver.Categories, []string{"store_packages"}) {
+		t.Errorf(`Categories were %v, expected [store_packages]`, receiver.Categories)
+	}
+}
+
+// Test that failing to get the installed packages still lists the store ones.
+func TestScopeSearch_installedFailure(t *testing.T) {
+	webdmClient := &fakes.FakeWebdmManager{FailGetInstalledPackages: true}
+	scope := Scope{webdmClient: webdmClient}
+	receiver := new(FakeSearchReceiver)
+
+	err := scope.search(scopes.NewCannedQuery("snappy-store", "", ""), receiver)
+	if err != nil {
+		t.Fatalf("Unexpected error while searching: %s", err)
+	}
+
+	if receiver.Department == nil {
+		t.Error("Expected departments to be registered")
+	}
+
+	if !webdmClient.GetStorePackagesCalled {
+		t.Error("Expected store packages to be listed")
+	}
+}
+
+// Test that a query for a single package looks it up rather than searching the
+// store.
+func TestScopeSearch_packageQuery(t *testing.T) {
+	webdmClient := new(fakes.FakeWebdmManager)
+	scope := Scope{webdmClient: webdmClient}
+	receiver := new(FakeSearchReceiver)
+
+	// Unknown packages can't be shown
+	err := scope.search(scopes.NewCannedQuery("snappy-store", "", "pkgname:foo"), receiver)
+	if err == nil {
+		t.Error("Expected an error due to the package being unknown")
+	}
+
+	if !webdmClient.QueryCalled {
+		t.Error("Expected the package to be looked up")
+	}
+
+	if webdmClient.GetStorePackagesCalled {
+		t.Error("Expected the store not to be searched")
+	}
+}
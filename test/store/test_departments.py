@@ -55,11 +55,12 @@ class TestDepartments(ScopeHarnessTestCase, fixtures.TestWithFixtures):
 	def testStoreDepartment(self):
 		"""Test department for in-store packages.
 
-		Make sure that is has a child for installed packages.
+		Make sure that is has children for installed packages and for recent
+		activity, followed by one child per store section.
 		"""
 
 		self.assertMatchResult(DepartmentMatcher()
-			.has_exactly(1) # One child department
+			.has_at_least(2) # Installed and recent departments, plus store sections
 			.label("All Categories")
 			.is_root(True)
 			.is_hidden(False)
@@ -67,6 +68,10 @@ class TestDepartments(ScopeHarnessTestCase, fixtures.TestWithFixtures):
 				.label("My Snaps")
 				.has_children(False)
 				.is_active(False))
+			.child(ChildDepartmentMatcher("recent")
+				.label("Recent activity")
+				.has_children(False)
+				.is_active(False))
 			.match(self.view.browse_department("")))
 
 	def testInstalledDepartment(self):
@@ -79,3 +84,14 @@ class TestDepartments(ScopeHarnessTestCase, fixtures.TestWithFixtures):
 			.is_root(False)
 			.is_hidden(False)
 			.match(self.view.browse_department("installed")))
+
+	def testRecentDepartment(self):
+		"""Test that the department for recent activity has no children.
+		"""
+
+		self.assertMatchResult(DepartmentMatcher()
+			.has_exactly(0) # No child departments
+			.label("Recent activity")
+			.is_root(False)
+			.is_hidden(False)
+			.match(self.view.browse_department("recent")))